@@ -0,0 +1,207 @@
+// acme_jws.go
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// acmeJWS is a request body in the flattened JSON serialization of JWS,
+// which ACME requires (RFC 8555, section 6.2).
+type acmeJWS struct {
+	Protected string `json:"protected"`
+	Payload   string `json:"payload"`
+	Signature string `json:"signature"`
+}
+
+// acmeProtectedHeader is the protected header of an ACME request. Exactly
+// one of JWK, for new accounts, and KID, the account URL, is set.
+type acmeProtectedHeader struct {
+	Alg   string          `json:"alg"`
+	Nonce string          `json:"nonce"`
+	URL   string          `json:"url"`
+	JWK   json.RawMessage `json:"jwk,omitempty"`
+	KID   string          `json:"kid,omitempty"`
+}
+
+// acmeJWK is a JSON Web Key (RFC 7517) for the key types ACME clients use.
+type acmeJWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+var base64URL = base64.RawURLEncoding
+
+// parseJWS decodes a request body and its protected header. The signature
+// is not checked.
+func parseJWS(body []byte) (*acmeJWS, *acmeProtectedHeader, error) {
+	var jws acmeJWS
+	if err := json.Unmarshal(body, &jws); err != nil {
+		return nil, nil, fmt.Errorf("request is not a flattened JWS: %w", err)
+	}
+	raw, err := base64URL.DecodeString(jws.Protected)
+	if err != nil {
+		return nil, nil, fmt.Errorf("protected header is not base64url: %w", err)
+	}
+	var header acmeProtectedHeader
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return nil, nil, fmt.Errorf("protected header is not JSON: %w", err)
+	}
+	if (len(header.JWK) == 0) == (header.KID == "") {
+		return nil, nil, errors.New("protected header must have exactly one of jwk and kid")
+	}
+	return &jws, &header, nil
+}
+
+// payload returns the decoded payload; an empty one is a POST-as-GET.
+func (j *acmeJWS) payload() ([]byte, error) {
+	payload, err := base64URL.DecodeString(j.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("payload is not base64url: %w", err)
+	}
+	return payload, nil
+}
+
+// verify checks the signature over the protected header and payload with
+// pub, which must match the algorithm the header names.
+func (j *acmeJWS) verify(alg string, pub crypto.PublicKey) error {
+	sig, err := base64URL.DecodeString(j.Signature)
+	if err != nil {
+		return fmt.Errorf("signature is not base64url: %w", err)
+	}
+	signed := []byte(j.Protected + "." + j.Payload)
+
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		if alg != "RS256" {
+			break
+		}
+		digest := sha256.Sum256(signed)
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig)
+	case *ecdsa.PublicKey:
+		var digest []byte
+		switch {
+		case alg == "ES256" && key.Curve == elliptic.P256():
+			sum := sha256.Sum256(signed)
+			digest = sum[:]
+		case alg == "ES384" && key.Curve == elliptic.P384():
+			sum := sha512.Sum384(signed)
+			digest = sum[:]
+		default:
+			return fmt.Errorf("algorithm %s does not match the %s account key", alg, key.Curve.Params().Name)
+		}
+		// JWS encodes ECDSA signatures as r || s, not ASN.1.
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return errors.New("ECDSA signature has the wrong length")
+		}
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return errors.New("signature is invalid")
+		}
+		return nil
+	case ed25519.PublicKey:
+		if alg != "EdDSA" {
+			break
+		}
+		if !ed25519.Verify(key, signed, sig) {
+			return errors.New("signature is invalid")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported signature algorithm %q for the account key", alg)
+}
+
+// parseJWK converts a JWK into a public key.
+func parseJWK(raw json.RawMessage) (*acmeJWK, crypto.PublicKey, error) {
+	var jwk acmeJWK
+	if err := json.Unmarshal(raw, &jwk); err != nil {
+		return nil, nil, fmt.Errorf("jwk is not JSON: %w", err)
+	}
+	decode := func(name, value string) (*big.Int, error) {
+		b, err := base64URL.DecodeString(value)
+		if err != nil || len(b) == 0 {
+			return nil, fmt.Errorf("jwk member %q is missing or not base64url", name)
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decode("n", jwk.N)
+		if err != nil {
+			return nil, nil, err
+		}
+		e, err := decode("e", jwk.E)
+		if err != nil {
+			return nil, nil, err
+		}
+		if n.BitLen() < 2048 || !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, nil, errors.New("RSA account keys must be at least 2048 bits")
+		}
+		return &jwk, &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, nil, fmt.Errorf("unsupported EC curve %q", jwk.Crv)
+		}
+		x, err := decode("x", jwk.X)
+		if err != nil {
+			return nil, nil, err
+		}
+		y, err := decode("y", jwk.Y)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, nil, errors.New("EC point is not on the curve")
+		}
+		return &jwk, &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, nil, fmt.Errorf("unsupported OKP curve %q", jwk.Crv)
+		}
+		x, err := base64URL.DecodeString(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, nil, errors.New("jwk member \"x\" is not an Ed25519 public key")
+		}
+		return &jwk, ed25519.PublicKey(x), nil
+	}
+	return nil, nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+}
+
+// thumbprint returns the base64url SHA-256 JWK thumbprint (RFC 7638), which
+// identifies the account in key authorizations.
+func (k *acmeJWK) thumbprint() string {
+	// The required members in lexicographic order, without whitespace.
+	var canonical string
+	switch k.Kty {
+	case "RSA":
+		canonical = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, k.E, k.N)
+	case "EC":
+		canonical = fmt.Sprintf(`{"crv":%q,"kty":"EC","x":%q,"y":%q}`, k.Crv, k.X, k.Y)
+	case "OKP":
+		canonical = fmt.Sprintf(`{"crv":%q,"kty":"OKP","x":%q}`, k.Crv, k.X)
+	}
+	sum := sha256.Sum256([]byte(canonical))
+	return base64URL.EncodeToString(sum[:])
+}
@@ -0,0 +1,201 @@
+// acme_jws_test.go
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// testJWK returns the JWK for pub.
+func testJWK(t *testing.T, pub crypto.PublicKey) *acmeJWK {
+	t.Helper()
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		return &acmeJWK{Kty: "RSA", N: base64URL.EncodeToString(key.N.Bytes()), E: base64URL.EncodeToString(big.NewInt(int64(key.E)).Bytes())}
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		return &acmeJWK{Kty: "EC", Crv: key.Curve.Params().Name, X: base64URL.EncodeToString(key.X.FillBytes(make([]byte, size))), Y: base64URL.EncodeToString(key.Y.FillBytes(make([]byte, size)))}
+	case ed25519.PublicKey:
+		return &acmeJWK{Kty: "OKP", Crv: "Ed25519", X: base64URL.EncodeToString(key)}
+	}
+	t.Fatalf("unsupported key type %T", pub)
+	return nil
+}
+
+// signTestJWS signs payload as alg requires, the way an ACME client does.
+func signTestJWS(t *testing.T, alg string, key crypto.Signer, payload string) *acmeJWS {
+	t.Helper()
+	jws := &acmeJWS{
+		Protected: base64URL.EncodeToString([]byte(`{"alg":"` + alg + `","nonce":"n","url":"u","kid":"k"}`)),
+		Payload:   base64URL.EncodeToString([]byte(payload)),
+	}
+	signed := []byte(jws.Protected + "." + jws.Payload)
+	var sig []byte
+	var err error
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256(signed)
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		var digest []byte
+		if k.Curve == elliptic.P384() {
+			sum := sha512.Sum384(signed)
+			digest = sum[:]
+		} else {
+			sum := sha256.Sum256(signed)
+			digest = sum[:]
+		}
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k, digest)
+		size := (k.Curve.Params().BitSize + 7) / 8
+		sig = append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...)
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(k, signed)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	jws.Signature = base64URL.EncodeToString(sig)
+	return jws
+}
+
+// testKeys holds one key of each kind ACME clients use, generated once.
+type testKeys struct {
+	rsa2048, rsa1024 *rsa.PrivateKey
+	p256, p384       *ecdsa.PrivateKey
+	ed25519          ed25519.PrivateKey
+}
+
+func newTestKeys(t *testing.T) testKeys {
+	t.Helper()
+	var keys testKeys
+	var err error
+	if keys.rsa2048, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		t.Fatal(err)
+	}
+	if keys.rsa1024, err = rsa.GenerateKey(rand.Reader, 1024); err != nil {
+		t.Fatal(err)
+	}
+	if keys.p256, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+	if keys.p384, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+	if _, keys.ed25519, err = ed25519.GenerateKey(rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestParseJWK(t *testing.T) {
+	keys := newTestKeys(t)
+	offCurve := testJWK(t, keys.p256.Public())
+	y, _ := base64URL.DecodeString(offCurve.Y)
+	y[len(y)-1] ^= 1
+	offCurve.Y = base64URL.EncodeToString(y)
+
+	tests := []struct {
+		name    string
+		jwk     any
+		want    crypto.PublicKey
+		wantErr string
+	}{
+		{name: "RSA 2048", jwk: testJWK(t, keys.rsa2048.Public()), want: keys.rsa2048.Public()},
+		{name: "P-256", jwk: testJWK(t, keys.p256.Public()), want: keys.p256.Public()},
+		{name: "P-384", jwk: testJWK(t, keys.p384.Public()), want: keys.p384.Public()},
+		{name: "Ed25519", jwk: testJWK(t, keys.ed25519.Public()), want: keys.ed25519.Public()},
+		{name: "short RSA key", jwk: testJWK(t, keys.rsa1024.Public()), wantErr: "at least 2048 bits"},
+		{name: "RSA without exponent", jwk: &acmeJWK{Kty: "RSA", N: testJWK(t, keys.rsa2048.Public()).N}, wantErr: `"e" is missing`},
+		{name: "off-curve point", jwk: offCurve, wantErr: "not on the curve"},
+		{name: "unsupported curve", jwk: &acmeJWK{Kty: "EC", Crv: "P-521", X: "AA", Y: "AA"}, wantErr: "unsupported EC curve"},
+		{name: "EC without y", jwk: &acmeJWK{Kty: "EC", Crv: "P-256", X: offCurve.X}, wantErr: `"y" is missing`},
+		{name: "short Ed25519 key", jwk: &acmeJWK{Kty: "OKP", Crv: "Ed25519", X: "AAAA"}, wantErr: "not an Ed25519 public key"},
+		{name: "unsupported OKP curve", jwk: &acmeJWK{Kty: "OKP", Crv: "X25519", X: "AAAA"}, wantErr: "unsupported OKP curve"},
+		{name: "unsupported key type", jwk: &acmeJWK{Kty: "oct"}, wantErr: "unsupported key type"},
+		{name: "not JSON", jwk: "not an object", wantErr: "not JSON"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := json.Marshal(tt.jwk)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, got, err := parseJWK(raw)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseJWK() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseJWK() error = %v", err)
+			}
+			if !got.(interface{ Equal(crypto.PublicKey) bool }).Equal(tt.want) {
+				t.Errorf("parseJWK() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJWSVerify(t *testing.T) {
+	keys := newTestKeys(t)
+	otherP256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := signTestJWS(t, "ES256", keys.p256, `{"a":1}`)
+	tampered.Payload = base64URL.EncodeToString([]byte(`{"a":2}`))
+	truncated := signTestJWS(t, "ES256", keys.p256, "{}")
+	truncated.Signature = truncated.Signature[:20]
+
+	tests := []struct {
+		name    string
+		jws     *acmeJWS
+		alg     string
+		key     crypto.PublicKey
+		wantErr string
+	}{
+		{name: "RS256", jws: signTestJWS(t, "RS256", keys.rsa2048, "{}"), alg: "RS256", key: keys.rsa2048.Public()},
+		{name: "ES256", jws: signTestJWS(t, "ES256", keys.p256, "{}"), alg: "ES256", key: keys.p256.Public()},
+		{name: "ES384", jws: signTestJWS(t, "ES384", keys.p384, "{}"), alg: "ES384", key: keys.p384.Public()},
+		{name: "EdDSA", jws: signTestJWS(t, "EdDSA", keys.ed25519, "{}"), alg: "EdDSA", key: keys.ed25519.Public()},
+		{name: "POST-as-GET", jws: signTestJWS(t, "EdDSA", keys.ed25519, ""), alg: "EdDSA", key: keys.ed25519.Public()},
+
+		{name: "RSA key with RS384", jws: signTestJWS(t, "RS256", keys.rsa2048, "{}"), alg: "RS384", key: keys.rsa2048.Public(), wantErr: "unsupported signature algorithm"},
+		{name: "P-256 key with ES384", jws: signTestJWS(t, "ES256", keys.p256, "{}"), alg: "ES384", key: keys.p256.Public(), wantErr: "does not match"},
+		{name: "P-384 key with ES256", jws: signTestJWS(t, "ES384", keys.p384, "{}"), alg: "ES256", key: keys.p384.Public(), wantErr: "does not match"},
+		{name: "Ed25519 key with ES256", jws: signTestJWS(t, "EdDSA", keys.ed25519, "{}"), alg: "ES256", key: keys.ed25519.Public(), wantErr: "unsupported signature algorithm"},
+		{name: "alg none", jws: signTestJWS(t, "EdDSA", keys.ed25519, "{}"), alg: "none", key: keys.ed25519.Public(), wantErr: "unsupported signature algorithm"},
+
+		{name: "tampered payload", jws: tampered, alg: "ES256", key: keys.p256.Public(), wantErr: "invalid"},
+		{name: "truncated signature", jws: truncated, alg: "ES256", key: keys.p256.Public(), wantErr: "wrong length"},
+		{name: "other key", jws: signTestJWS(t, "ES256", otherP256, "{}"), alg: "ES256", key: keys.p256.Public(), wantErr: "invalid"},
+		{name: "other RSA key", jws: signTestJWS(t, "RS256", keys.rsa1024, "{}"), alg: "RS256", key: keys.rsa2048.Public(), wantErr: "verification error"},
+		{name: "signature not base64url", jws: &acmeJWS{Signature: "%%"}, alg: "ES256", key: keys.p256.Public(), wantErr: "not base64url"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.jws.verify(tt.alg, tt.key)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("verify() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("verify() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+// api_policy.go
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/prtk1729/certA/pkg/ca"
+	"gopkg.in/yaml.v3"
+)
+
+// apiDefaultProfile stands for issuance without a profile in the profiles
+// list of a policy entry.
+const apiDefaultProfile = "default"
+
+// apiPolicyFile is the -policy file of the serve command. Clients are keyed
+// by name, which is the name of their token; client certificates are matched
+// by key, through the SPKI pins each entry lists, never by their Common Name.
+// Clients without an entry may not use the API at all.
+type apiPolicyFile struct {
+	Clients map[string]*apiClientPolicy `yaml:"clients"`
+
+	pins map[string]string // SPKI pin to client name
+}
+
+// apiClientPolicy lists the names and profiles one client may request. SAN
+// entries use the name constraint syntax (see -permit-dns): a domain matches
+// itself and its subdomains, ".example.com" only its subdomains, and IP
+// entries are CIDR ranges. A SAN type without entries is not allowed.
+type apiClientPolicy struct {
+	DNS      []string `yaml:"dns"`
+	IP       []string `yaml:"ip"`
+	Email    []string `yaml:"email"`
+	URI      []string `yaml:"uri"`
+	Profiles []string `yaml:"profiles"` // Allowed profiles, "default" for issuance without one; empty allows only the default
+	SPKI     []string `yaml:"spki"`     // SPKI pins (sha256/<base64>, as inspect prints) of the client's certificate keys
+
+	constraints ca.NameConstraints
+}
+
+// loadAPIPolicy reads and checks a policy file.
+func loadAPIPolicy(path string) (*apiPolicyFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file %q: %w", path, err)
+	}
+	policy := &apiPolicyFile{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(policy); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse policy file %q: %w", path, err)
+	}
+	if len(policy.Clients) == 0 {
+		return nil, fmt.Errorf("policy file %q lists no clients", path)
+	}
+
+	var errs []error
+	policy.pins = map[string]string{}
+	for name, client := range policy.Clients {
+		if client == nil {
+			client = &apiClientPolicy{}
+			policy.Clients[name] = client
+		}
+		client.constraints = ca.NameConstraints{
+			PermittedDNSDomains:     client.DNS,
+			PermittedEmailAddresses: client.Email,
+			PermittedURIDomains:     client.URI,
+		}
+		for _, value := range client.IP {
+			ip, r, err := net.ParseCIDR(value)
+			if err != nil || !ip.Equal(r.IP) {
+				errs = append(errs, fmt.Errorf("client %q: ip entry %q is not a CIDR range such as 10.0.0.0/8", name, value))
+				continue
+			}
+			client.constraints.PermittedIPRanges = append(client.constraints.PermittedIPRanges, r)
+		}
+		if err := client.constraints.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("client %q: %w", name, err))
+		}
+		for _, profile := range client.Profiles {
+			if profile == apiDefaultProfile {
+				continue
+			}
+			if _, err := ca.LookupProfile(profile); err != nil {
+				errs = append(errs, fmt.Errorf("client %q: %w", name, err))
+			}
+		}
+		for _, value := range client.SPKI {
+			pin, err := ca.NormalizePin(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("client %q: spki entry %w", name, err))
+				continue
+			}
+			if other, ok := policy.pins[pin]; ok && other != name {
+				errs = append(errs, fmt.Errorf("client %q: spki entry %q is also listed for client %q", name, value, other))
+			}
+			policy.pins[pin] = name
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("invalid policy file %q:\n%w", path, err)
+	}
+	return policy, nil
+}
+
+// clientForKey returns the name of the client whose spki entries list pin.
+func (f *apiPolicyFile) clientForKey(pin string) (string, bool) {
+	name, ok := f.pins[pin]
+	return name, ok
+}
+
+// check reports every way a request for the names in opts under profile
+// ("" for the default) falls outside the policy.
+func (p *apiClientPolicy) check(opts ca.IssueOptions, profile string) error {
+	var errs []error
+	if !p.allowsProfile(profile) {
+		if profile == "" {
+			errs = append(errs, errors.New("issuance without a profile is not allowed"))
+		} else {
+			errs = append(errs, fmt.Errorf("profile %q is not allowed", profile))
+		}
+	}
+	if err := p.checkNames(opts); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// checkNames reports every name in opts the policy does not allow.
+func (p *apiClientPolicy) checkNames(opts ca.IssueOptions) error {
+	var errs []error
+	none := func(kind string, requested, allowed int) {
+		if requested > 0 && allowed == 0 {
+			errs = append(errs, fmt.Errorf("no %s SANs are allowed", kind))
+		}
+	}
+	none("dns", len(opts.DNSNames), len(p.DNS))
+	none("ip", len(opts.IPAddresses), len(p.IP))
+	none("email", len(opts.EmailAddresses), len(p.Email))
+	none("uri", len(opts.URIs), len(p.URI))
+	if err := p.constraints.Check(opts); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// allowsProfile reports whether the client may request profile, "" being
+// issuance without one. The profiles list is exhaustive; without one only
+// the default is allowed.
+func (p *apiClientPolicy) allowsProfile(profile string) bool {
+	if profile == "" {
+		return len(p.Profiles) == 0 || slices.Contains(p.Profiles, apiDefaultProfile)
+	}
+	return slices.Contains(p.Profiles, profile)
+}
+
+// csrNames returns the names a CSR asks for. A Common Name that is not also
+// a SAN is checked as a DNS name, so it cannot smuggle in a name the policy
+// does not allow.
+func csrNames(commonName string, opts ca.IssueOptions) ca.IssueOptions {
+	if commonName == "" {
+		return opts
+	}
+	if slices.Contains(opts.DNSNames, commonName) || slices.Contains(opts.EmailAddresses, commonName) {
+		return opts
+	}
+	for _, ip := range opts.IPAddresses {
+		if ip.String() == commonName {
+			return opts
+		}
+	}
+	opts.DNSNames = append(slices.Clone(opts.DNSNames), commonName)
+	return opts
+}
+
+// allowsRecord reports whether the client may see and revoke the
+// certificate of a database record. The record keeps only the SANs, so
+// certificates without any, and CA certificates, are never allowed.
+func (p *apiClientPolicy) allowsRecord(record *certRecord) bool {
+	names := recordNames(record)
+	return !record.IsCA && names.HasSANs() && p.checkNames(names) == nil
+}
+
+// recordNames converts the SANs of a database record back into the names
+// the certificate was issued for.
+func recordNames(record *certRecord) ca.IssueOptions {
+	var opts ca.IssueOptions
+	for _, san := range record.SANs {
+		kind, value, _ := strings.Cut(san, ":")
+		switch kind {
+		case "DNS":
+			opts.DNSNames = append(opts.DNSNames, value)
+		case "IP":
+			opts.IPAddresses = append(opts.IPAddresses, net.ParseIP(value))
+		case "email":
+			opts.EmailAddresses = append(opts.EmailAddresses, value)
+		case "URI":
+			if u, err := url.Parse(value); err == nil {
+				opts.URIs = append(opts.URIs, u)
+			}
+		}
+	}
+	return opts
+}
@@ -0,0 +1,161 @@
+// bench.go
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/prtk1729/certA/pkg/ca"
+)
+
+// benchAlgorithm is one key type/size the bench command can measure.
+type benchAlgorithm struct {
+	Name     string
+	Generate func() (crypto.Signer, error)
+}
+
+// benchAlgorithms lists every key type the tool can generate, in the order
+// they are reported.
+var benchAlgorithms = []benchAlgorithm{
+	{"rsa-2048", func() (crypto.Signer, error) { return rsa.GenerateKey(rand.Reader, 2048) }},
+	{"rsa-3072", func() (crypto.Signer, error) { return rsa.GenerateKey(rand.Reader, 3072) }},
+	{"rsa-4096", func() (crypto.Signer, error) { return rsa.GenerateKey(rand.Reader, 4096) }},
+	{ca.KeyTypeECDSAP256, func() (crypto.Signer, error) { return ca.GenerateKey(rand.Reader, ca.KeyTypeECDSAP256, 0) }},
+	{ca.KeyTypeECDSAP384, func() (crypto.Signer, error) { return ca.GenerateKey(rand.Reader, ca.KeyTypeECDSAP384, 0) }},
+	{ca.KeyTypeECDSAP521, func() (crypto.Signer, error) { return ca.GenerateKey(rand.Reader, ca.KeyTypeECDSAP521, 0) }},
+	{ca.KeyTypeEd25519, func() (crypto.Signer, error) { return ca.GenerateKey(rand.Reader, ca.KeyTypeEd25519, 0) }},
+}
+
+// benchResult summarizes repeated runs of one operation.
+type benchResult struct {
+	Ops     int
+	Elapsed time.Duration
+}
+
+func (r benchResult) perSecond() float64 {
+	return float64(r.Ops) / r.Elapsed.Seconds()
+}
+
+func (r benchResult) average() time.Duration {
+	return r.Elapsed / time.Duration(r.Ops)
+}
+
+// runBench implements the bench command: it measures key generation and
+// certificate signing throughput for each supported algorithm on this machine.
+func runBench(args []string) {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	duration := fs.Duration("duration", 2*time.Second, "Minimum time spent measuring each operation")
+	only := fs.String("alg", "", "Optional: comma-separated algorithms to measure (default: all)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s bench [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Measures key generation and signing throughput on this machine.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+		var names []string
+		for _, alg := range benchAlgorithms {
+			names = append(names, alg.Name)
+		}
+		fmt.Fprintf(os.Stderr, "\nAlgorithms: %s\n", strings.Join(names, ", "))
+	}
+	fs.Parse(args)
+	if err := checkFlags(fs); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	selected := benchAlgorithms
+	if *only != "" {
+		selected = nil
+		for _, name := range strings.Split(*only, ",") {
+			alg, ok := findBenchAlgorithm(strings.TrimSpace(name))
+			if !ok {
+				log.Fatalf("Error: unknown algorithm %q. Run '%s bench -h' for the list.", name, os.Args[0])
+			}
+			selected = append(selected, alg)
+		}
+	}
+
+	fmt.Printf("Benchmarking on %s/%s with %d CPUs (%s per operation)\n\n", runtime.GOOS, runtime.GOARCH, runtime.NumCPU(), *duration)
+	fmt.Printf("%-10s  %14s  %14s  %14s  %14s\n", "ALGORITHM", "KEYGEN/s", "KEYGEN AVG", "SIGN/s", "SIGN AVG")
+
+	for _, alg := range selected {
+		var key crypto.Signer
+		keygen, err := measure(*duration, func() error {
+			var err error
+			key, err = alg.Generate()
+			return err
+		})
+		if err != nil {
+			log.Fatalf("Error generating %s key: %v", alg.Name, err)
+		}
+
+		template, err := benchTemplate()
+		if err != nil {
+			log.Fatalf("Error building template: %v", err)
+		}
+		sign, err := measure(*duration, func() error {
+			_, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+			return err
+		})
+		if err != nil {
+			log.Fatalf("Error signing with %s key: %v", alg.Name, err)
+		}
+
+		fmt.Printf("%-10s  %14.1f  %14s  %14.1f  %14s\n", alg.Name,
+			keygen.perSecond(), keygen.average().Round(time.Microsecond),
+			sign.perSecond(), sign.average().Round(time.Microsecond))
+	}
+}
+
+// findBenchAlgorithm looks up an algorithm by name.
+func findBenchAlgorithm(name string) (benchAlgorithm, bool) {
+	for _, alg := range benchAlgorithms {
+		if alg.Name == name {
+			return alg, true
+		}
+	}
+	return benchAlgorithm{}, false
+}
+
+// measure runs op repeatedly for at least the given duration (and at least
+// once) and reports how many runs completed.
+func measure(duration time.Duration, op func() error) (benchResult, error) {
+	start := time.Now()
+	ops := 0
+	for ops == 0 || time.Since(start) < duration {
+		if err := op(); err != nil {
+			return benchResult{}, err
+		}
+		ops++
+	}
+	return benchResult{Ops: ops, Elapsed: time.Since(start)}, nil
+}
+
+// benchTemplate returns a representative leaf template, so signing cost
+// includes the certificate encoding the CA does for real issuance.
+func benchTemplate() (*x509.Certificate, error) {
+	serialNumber, err := ca.GenerateSerialNumber(rand.Reader, ca.DefaultSerialBits)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject:      pkix.Name{CommonName: "bench.example.internal"},
+		DNSNames:     []string{"bench.example.internal"},
+		NotBefore:    now,
+		NotAfter:     now.AddDate(0, 0, 90),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, nil
+}
@@ -0,0 +1,389 @@
+// certdb.go
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/prtk1729/certA/pkg/ca"
+)
+
+const (
+	certDBFileName = "certdb.json"
+
+	// Legacy file holding only revocations, imported on first use.
+	revocationDBFileName = "revocations.json"
+)
+
+// Certificate statuses. Expiry is not stored: a valid certificate is
+// reported as expired once its notAfter has passed.
+const (
+	statusValid   = "valid"
+	statusRevoked = "revoked"
+	statusExpired = "expired"
+)
+
+// certDB is the on-disk record of every certificate a CA has issued, its
+// revocation status, and the number of the last CRL generated from it.
+type certDB struct {
+	CRLNumber    int64         `json:"crl_number"`
+	Certificates []*certRecord `json:"certificates"`
+
+	// Decommissioned is set by the decommission command; nothing may be
+	// recorded as issued afterwards.
+	Decommissioned *time.Time `json:"decommissioned,omitempty"`
+}
+
+// certRecord is one certificate in the database. Certificates revoked by
+// serial before the database existed have only Serial and the revocation
+// fields set.
+type certRecord struct {
+	Serial    string     `json:"serial"`             // Upper-case hex, as printed elsewhere
+	Sequence  uint64     `json:"sequence,omitempty"` // Set for sequential serials
+	Subject   string     `json:"subject,omitempty"`
+	SANs      []string   `json:"sans,omitempty"`
+	IsCA      bool       `json:"is_ca,omitempty"`
+	NotBefore time.Time  `json:"not_before"`
+	NotAfter  time.Time  `json:"not_after"`
+	SHA256    string     `json:"sha256_fingerprint,omitempty"`
+	Path      string     `json:"path,omitempty"` // Where the certificate was written
+	Status    string     `json:"status"`         // statusValid or statusRevoked
+	Reason    string     `json:"revocation_reason,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// newCertRecord describes cert for the database.
+func newCertRecord(cert *x509.Certificate, path string) *certRecord {
+	sum := sha256.Sum256(cert.Raw)
+	record := &certRecord{
+		Serial:    fmt.Sprintf("%X", cert.SerialNumber),
+		Subject:   cert.Subject.String(),
+		IsCA:      cert.IsCA,
+		NotBefore: cert.NotBefore.UTC(),
+		NotAfter:  cert.NotAfter.UTC(),
+		SHA256:    colonHex(sum[:]),
+		Path:      path,
+		Status:    statusValid,
+	}
+	for _, name := range cert.DNSNames {
+		record.SANs = append(record.SANs, "DNS:"+name)
+	}
+	for _, ip := range cert.IPAddresses {
+		record.SANs = append(record.SANs, "IP:"+ip.String())
+	}
+	for _, email := range cert.EmailAddresses {
+		record.SANs = append(record.SANs, "email:"+email)
+	}
+	for _, u := range cert.URIs {
+		record.SANs = append(record.SANs, "URI:"+u.String())
+	}
+	return record
+}
+
+// status returns the record's status as of now.
+func (r *certRecord) status(now time.Time) string {
+	if r.Status == statusValid && !r.NotAfter.IsZero() && now.After(r.NotAfter) {
+		return statusExpired
+	}
+	return r.Status
+}
+
+// defaultCertDB returns the certificate database path for a CA: a file next
+// to its certificate, so each CA directory keeps its own state.
+func defaultCertDB(caCertFile string) string {
+	return filepath.Join(filepath.Dir(caCertFile), certDBFileName)
+}
+
+// certDBFlags are the flags shared by commands that read or write a CA's
+// certificate database.
+type certDBFlags struct {
+	path string
+}
+
+func (f *certDBFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.path, "db", "", "Certificate database file (default: "+certDBFileName+" next to the CA certificate)")
+}
+
+// resolve returns the database path for the CA at caCertFile.
+func (f *certDBFlags) resolve(caCertFile string) string {
+	if f.path != "" {
+		return f.path
+	}
+	return defaultCertDB(caCertFile)
+}
+
+// loadCertDB reads a certificate database. A missing file yields an empty
+// database, seeded from a legacy revocations.json in the same directory.
+func loadCertDB(path string) (*certDB, error) {
+	db := &certDB{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return db, db.importRevocations(filepath.Join(filepath.Dir(path), revocationDBFileName))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate database %q: %w", path, err)
+	}
+	if err := json.Unmarshal(data, db); err != nil {
+		return nil, fmt.Errorf("failed to parse certificate database %q: %w", path, err)
+	}
+	return db, nil
+}
+
+// importRevocations adds the entries of a legacy revocation database, which
+// recorded only revoked serials and the CRL number. A missing file is ignored.
+func (db *certDB) importRevocations(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read revocation database %q: %w", path, err)
+	}
+	var legacy struct {
+		CRLNumber int64 `json:"crl_number"`
+		Revoked   []struct {
+			Serial    string    `json:"serial"`
+			Subject   string    `json:"subject"`
+			Reason    string    `json:"reason"`
+			RevokedAt time.Time `json:"revoked_at"`
+		} `json:"revoked"`
+	}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return fmt.Errorf("failed to parse revocation database %q: %w", path, err)
+	}
+	db.CRLNumber = legacy.CRLNumber
+	for _, entry := range legacy.Revoked {
+		revokedAt := entry.RevokedAt
+		db.Certificates = append(db.Certificates, &certRecord{
+			Serial:    entry.Serial,
+			Subject:   entry.Subject,
+			Status:    statusRevoked,
+			Reason:    entry.Reason,
+			RevokedAt: &revokedAt,
+		})
+	}
+	return nil
+}
+
+// lockCertDB takes an exclusive lock on the database at path, held until
+// unlock is called or the process exits. Every read-modify-write of the
+// database holds it, so that serve, serve-acme and the CLI commands can
+// share one database without losing each other's updates.
+func lockCertDB(path string) (unlock func(), err error) {
+	f, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock certificate database %q: %w", path, err)
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock certificate database %q: %w", path, err)
+	}
+	return func() { f.Close() }, nil
+}
+
+// save writes the database back to disk. The file is replaced atomically so
+// an interrupted write cannot lose the CA's records.
+func (db *certDB) save(path string) error {
+	data, err := db.encode()
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write certificate database %q: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write certificate database %q: %w", path, err)
+	}
+	return nil
+}
+
+// encode returns the file contents save writes.
+func (db *certDB) encode() ([]byte, error) {
+	data, err := json.MarshalIndent(db, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode certificate database: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// find returns the record for serial, or nil if there is none.
+func (db *certDB) find(serial string) *certRecord {
+	for _, record := range db.Certificates {
+		if record.Serial == serial {
+			return record
+		}
+	}
+	return nil
+}
+
+// lastSequence returns the highest sequence number used for a serial.
+func (db *certDB) lastSequence() uint64 {
+	var last uint64
+	for _, record := range db.Certificates {
+		if record.Sequence > last {
+			last = record.Sequence
+		}
+	}
+	return last
+}
+
+// revoked returns the revoked records.
+func (db *certDB) revoked() []*certRecord {
+	var revoked []*certRecord
+	for _, record := range db.Certificates {
+		if record.Status == statusRevoked {
+			revoked = append(revoked, record)
+		}
+	}
+	return revoked
+}
+
+// revocations converts the revoked records for ca.CA.CreateCRL.
+func (db *certDB) revocations() ([]ca.Revocation, error) {
+	var revoked []ca.Revocation
+	for _, record := range db.revoked() {
+		serial, err := parseSerial(record.Serial)
+		if err != nil {
+			return nil, err
+		}
+		reason, ok := ca.RevocationReasonNames[record.Reason]
+		if !ok {
+			return nil, fmt.Errorf("serial %s has unknown revocation reason %q", record.Serial, record.Reason)
+		}
+		revoked = append(revoked, ca.Revocation{Serial: serial, RevokedAt: *record.RevokedAt, Reason: reason})
+	}
+	return revoked, nil
+}
+
+// signCRL signs a CRL of the revoked records under the next CRL number. The
+// caller saves db once the CRL has been published, so that a CRL that was
+// never handed out does not use up a number.
+func (db *certDB) signCRL(issuer *ca.CA, nextUpdate time.Duration, clock ca.Clock) ([]byte, error) {
+	revoked, err := db.revocations()
+	if err != nil {
+		return nil, err
+	}
+	db.CRLNumber++
+	return issuer.CreateCRL(revoked, ca.CRLOptions{
+		Number:     big.NewInt(db.CRLNumber),
+		NextUpdate: nextUpdate,
+		Clock:      clock,
+	})
+}
+
+// recordIssued adds a newly issued certificate to the database at dbPath,
+// along with the sequence number its serial was allocated from, if any.
+// Callers record the certificate before writing it out, so nothing is handed
+// out that the CA has no record of and no serial is handed out twice.
+func recordIssued(dbPath string, cert *x509.Certificate, certPath string, seq uint64) error {
+	unlock, err := lockCertDB(dbPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	db, err := loadCertDB(dbPath)
+	if err != nil {
+		return err
+	}
+	// The database outlives the working directory it was written from.
+	// Certificates handed out without being written, e.g. over ACME, have
+	// no path.
+	if abs, err := filepath.Abs(certPath); err == nil && certPath != "" {
+		certPath = abs
+	}
+	if db.Decommissioned != nil {
+		return fmt.Errorf("the CA was decommissioned at %s", db.Decommissioned.Format(time.RFC3339))
+	}
+	record := newCertRecord(cert, certPath)
+	if existing := db.find(record.Serial); existing != nil {
+		return fmt.Errorf("serial %s is already recorded in %q for %s", record.Serial, dbPath, existing.Subject)
+	}
+	if seq != 0 && seq <= db.lastSequence() {
+		// Another issuance took this sequence number since it was allocated.
+		return fmt.Errorf("serial sequence number %d was used concurrently; retry", seq)
+	}
+	record.Sequence = seq
+	db.Certificates = append(db.Certificates, record)
+	return db.save(dbPath)
+}
+
+// Serial number modes for -serial-mode.
+const (
+	serialModeRandom     = "random"
+	serialModeSequential = "sequential"
+)
+
+// serialFlags are the flags shared by commands that assign serial numbers.
+type serialFlags struct {
+	bits int
+	mode string
+}
+
+func (f *serialFlags) register(fs *flag.FlagSet) {
+	fs.IntVar(&f.bits, "serial-bits", ca.DefaultSerialBits, fmt.Sprintf("Serial number length in bits (%d-%d) for -serial-mode random", ca.MinSerialBits, ca.MaxSerialBits))
+	fs.StringVar(&f.mode, "serial-mode", serialModeRandom, fmt.Sprintf("Serial number scheme: '%s', or '%s' for an increasing sequence number followed by %d random bits; either way serials are checked against the certificate database", serialModeRandom, serialModeSequential, ca.SequentialRandomBits))
+}
+
+// check validates the flags before anything is loaded.
+func (f *serialFlags) check() error {
+	if f.mode != serialModeRandom && f.mode != serialModeSequential {
+		return fmt.Errorf("unknown -serial-mode %q (use %s or %s)", f.mode, serialModeRandom, serialModeSequential)
+	}
+	return nil
+}
+
+// maxSerialAttempts bounds the retries on a serial collision, which for
+// random serials of at least 64 bits points at a broken random source.
+const maxSerialAttempts = 10
+
+// allocate returns a serial number not yet recorded in the database at
+// dbPath and, in sequential mode, its sequence number. The serial is only
+// reserved once recordIssued stores it; if another process records it
+// first, recordIssued refuses the second certificate.
+func (f *serialFlags) allocate(dbPath string, random io.Reader) (*big.Int, uint64, error) {
+	if err := f.check(); err != nil {
+		return nil, 0, err
+	}
+	if random == nil {
+		random = rand.Reader
+	}
+	unlock, err := lockCertDB(dbPath)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer unlock()
+	db, err := loadCertDB(dbPath)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for attempt := 0; attempt < maxSerialAttempts; attempt++ {
+		var serial *big.Int
+		var seq uint64
+		if f.mode == serialModeSequential {
+			seq = db.lastSequence() + 1
+			serial, err = ca.SequentialSerial(random, seq)
+		} else {
+			serial, err = ca.GenerateSerialNumber(random, f.bits)
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to generate serial number: %w", err)
+		}
+		if db.find(fmt.Sprintf("%X", serial)) == nil {
+			return serial, seq, nil
+		}
+	}
+	return nil, 0, fmt.Errorf("no unused serial number found after %d attempts", maxSerialAttempts)
+}
@@ -0,0 +1,112 @@
+// certdb_test.go
+package main
+
+import (
+	"crypto/x509"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prtk1729/certA/pkg/ca"
+)
+
+// testIssuer returns a function issuing leaves with a given serial number
+// from a new root CA.
+func testIssuer(t *testing.T) func(serial *big.Int) *x509.Certificate {
+	t.Helper()
+	root, err := ca.NewRootCA(ca.Config{CommonName: "Test Root", ValidityDays: 365, KeyType: ca.KeyTypeEd25519})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return func(serial *big.Int) *x509.Certificate {
+		t.Helper()
+		cert, _, err := root.Issue(ca.IssueOptions{CommonName: "app.example.com", ValidityDays: 30, KeyType: ca.KeyTypeEd25519, SerialNumber: serial})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+}
+
+func TestRecordIssued(t *testing.T) {
+	issue := testIssuer(t)
+	dbPath := filepath.Join(t.TempDir(), certDBFileName)
+
+	first := issue(big.NewInt(0x1001))
+	if err := recordIssued(dbPath, first, "server.crt", 0); err != nil {
+		t.Fatalf("recordIssued: %v", err)
+	}
+	// A second certificate with the same serial is refused, whatever it is.
+	err := recordIssued(dbPath, issue(big.NewInt(0x1001)), "other.crt", 0)
+	if err == nil || !strings.Contains(err.Error(), "already recorded") {
+		t.Errorf("recordIssued of a duplicate serial: error = %v, want a refusal", err)
+	}
+	if err := recordIssued(dbPath, issue(big.NewInt(0x1002)), "", 0); err != nil {
+		t.Fatalf("recordIssued: %v", err)
+	}
+
+	db, err := loadCertDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(db.Certificates) != 2 {
+		t.Fatalf("database has %d records, want 2", len(db.Certificates))
+	}
+	record := db.find("1001")
+	if record == nil || record.Subject != first.Subject.String() {
+		t.Fatalf("record for serial 1001 = %+v", record)
+	}
+	if !filepath.IsAbs(record.Path) || filepath.Base(record.Path) != "server.crt" {
+		t.Errorf("record path = %q, want server.crt made absolute", record.Path)
+	}
+	if record := db.find("1002"); record == nil || record.Path != "" {
+		t.Errorf("record for a certificate without a file = %+v, want no path", record)
+	}
+}
+
+func TestRecordIssuedSequence(t *testing.T) {
+	issue := testIssuer(t)
+	dbPath := filepath.Join(t.TempDir(), certDBFileName)
+	serials := serialFlags{mode: serialModeSequential}
+
+	// Two issuances allocate before either records: both get sequence 1.
+	serialA, seqA, err := serials.allocate(dbPath, nil)
+	if err != nil {
+		t.Fatalf("allocate: %v", err)
+	}
+	serialB, seqB, err := serials.allocate(dbPath, nil)
+	if err != nil {
+		t.Fatalf("allocate: %v", err)
+	}
+	if seqA != 1 || seqB != 1 || ca.SerialSequence(serialA) != 1 {
+		t.Fatalf("allocated sequences %d and %d, want 1 for both", seqA, seqB)
+	}
+	if err := recordIssued(dbPath, issue(serialA), "", seqA); err != nil {
+		t.Fatalf("recordIssued: %v", err)
+	}
+	// The second has a different random part, so only the stale sequence
+	// number gives it away.
+	err = recordIssued(dbPath, issue(serialB), "", seqB)
+	if err == nil || !strings.Contains(err.Error(), "used concurrently") {
+		t.Errorf("recordIssued with a stale sequence number: error = %v, want a refusal", err)
+	}
+
+	serial, seq, err := serials.allocate(dbPath, nil)
+	if err != nil {
+		t.Fatalf("allocate: %v", err)
+	}
+	if seq != 2 {
+		t.Errorf("allocated sequence %d after recording 1, want 2", seq)
+	}
+	if err := recordIssued(dbPath, issue(serial), "", seq); err != nil {
+		t.Fatalf("recordIssued: %v", err)
+	}
+	db, err := loadCertDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.lastSequence() != 2 || len(db.Certificates) != 2 {
+		t.Errorf("database has %d records up to sequence %d, want 2 up to 2", len(db.Certificates), db.lastSequence())
+	}
+}
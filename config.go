@@ -0,0 +1,247 @@
+// config.go
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/prtk1729/certA/pkg/ca"
+	"gopkg.in/yaml.v3"
+)
+
+// configEnvVar names the environment variable that selects a configuration
+// file when -config is not given.
+const configEnvVar = "GO_CA_CONFIG"
+
+// configFile is the -config file format. It is YAML; JSON files work too, as
+// JSON is a subset of YAML. Each setting supplies the default for the
+// corresponding flag of the commands it applies to, so flags given on the
+// command line (and, for init, presets) always win. Zero values are unset.
+type configFile struct {
+	CA struct {
+		Cert       string `yaml:"cert,omitempty"`        // -ca; where init writes the root certificate
+		Key        string `yaml:"key,omitempty"`         // -ca-key; where init writes the root key
+		CommonName string `yaml:"common_name,omitempty"` // init -cn
+	} `yaml:"ca"`
+	Subject struct {
+		// Defaults for -org, -country, -state, -locality and -ou for new
+		// certificates and CSRs. Use the flags to give several OUs.
+		Organization       string `yaml:"organization,omitempty"`
+		Country            string `yaml:"country,omitempty"`
+		State              string `yaml:"state,omitempty"`
+		Locality           string `yaml:"locality,omitempty"`
+		OrganizationalUnit string `yaml:"organizational_unit,omitempty"`
+	} `yaml:"subject"`
+	Key struct {
+		Type     string `yaml:"type,omitempty"`      // -key-type
+		CABits   int    `yaml:"ca_bits,omitempty"`   // -bits for init and intermediate
+		LeafBits int    `yaml:"leaf_bits,omitempty"` // -bits for issue and csr
+	} `yaml:"key"`
+	Validity struct {
+		RootDays         int `yaml:"root_days,omitempty"`
+		IntermediateDays int `yaml:"intermediate_days,omitempty"`
+		LeafDays         int `yaml:"leaf_days,omitempty"` // issue and sign-csr
+	} `yaml:"validity"`
+	Serial struct {
+		Mode string `yaml:"mode,omitempty"` // -serial-mode
+		Bits int    `yaml:"bits,omitempty"` // -serial-bits
+	} `yaml:"serial"`
+	Output struct {
+		Dir string `yaml:"dir,omitempty"` // -out for intermediate, issue and csr
+	} `yaml:"output"`
+	Database string `yaml:"database,omitempty"` // -db
+	Denylist string `yaml:"denylist,omitempty"` // -denylist
+}
+
+// loadConfigFile reads a configuration file, rejecting unknown settings so
+// that typos do not go unnoticed.
+func loadConfigFile(path string) (*configFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %q: %w", path, err)
+	}
+	config := &configFile{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse config file %q: %w", path, err)
+	}
+	return config, nil
+}
+
+// flagValues returns the flag values the configuration supplies for a
+// command, keyed by flag name as they would be typed.
+func (c *configFile) flagValues(command string) (map[string]string, error) {
+	values := map[string]string{}
+	set := func(name, value string) {
+		if value != "" {
+			values[name] = value
+		}
+	}
+	setInt := func(name string, value int) {
+		if value != 0 {
+			values[name] = strconv.Itoa(value)
+		}
+	}
+
+	set("ca", c.CA.Cert)
+	set("ca-key", c.CA.Key)
+	set("db", c.Database)
+	set("denylist", c.Denylist)
+
+	switch command {
+	case "init":
+		if c.CA.Cert != "" {
+			dir := filepath.Dir(c.CA.Cert)
+			set("out", dir)
+			set("cert-name", filepath.Base(c.CA.Cert))
+			if c.CA.Key != "" {
+				if filepath.Dir(c.CA.Key) != dir {
+					return nil, fmt.Errorf("ca.key must be in the same directory as ca.cert")
+				}
+				set("key-name", filepath.Base(c.CA.Key))
+			}
+		}
+		set("cn", c.CA.CommonName)
+		c.setSubject(set)
+		set("key-type", c.Key.Type)
+		setInt("bits", c.Key.CABits)
+		setInt("days", c.Validity.RootDays)
+	case "intermediate":
+		c.setSubject(set)
+		set("key-type", c.Key.Type)
+		setInt("bits", c.Key.CABits)
+		setInt("days", c.Validity.IntermediateDays)
+		set("out", c.Output.Dir)
+	case "issue", "csr":
+		// sign-csr -org and friends override the CSR, so they are not
+		// defaults there.
+		c.setSubject(set)
+		set("key-type", c.Key.Type)
+		setInt("bits", c.Key.LeafBits)
+		set("out", c.Output.Dir)
+		if command == "issue" {
+			setInt("days", c.Validity.LeafDays)
+		}
+	case "sign-csr":
+		setInt("days", c.Validity.LeafDays)
+	}
+	set("serial-mode", c.Serial.Mode)
+	setInt("serial-bits", c.Serial.Bits)
+	return values, nil
+}
+
+// setSubject supplies the subject attribute flags.
+func (c *configFile) setSubject(set func(name, value string)) {
+	set("org", c.Subject.Organization)
+	set("country", c.Subject.Country)
+	set("state", c.Subject.State)
+	set("locality", c.Subject.Locality)
+	set("ou", c.Subject.OrganizationalUnit)
+}
+
+// configFlags adds -config to a command.
+type configFlags struct {
+	path string
+}
+
+func (f *configFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.path, "config", os.Getenv(configEnvVar), "Optional: YAML or JSON configuration file supplying flag defaults (default: $"+configEnvVar+")")
+}
+
+// apply sets the flags of fs the configuration file supplies and that were
+// not given explicitly.
+func (f *configFlags) apply(fs *flag.FlagSet) {
+	if f.path == "" {
+		return
+	}
+	config, err := loadConfigFile(f.path)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	values, err := config.flagValues(fs.Name())
+	if err != nil {
+		log.Fatalf("Error in config file %q: %v", f.path, err)
+	}
+	for name := range values {
+		// Only some commands have each flag, e.g. -serial-mode.
+		if fs.Lookup(name) == nil {
+			delete(values, name)
+		}
+	}
+	if err := applyPreset(fs, values); err != nil {
+		log.Fatalf("Error in config file %q: %v", f.path, err)
+	}
+}
+
+// defaultConfigFile returns a configuration holding the built-in defaults,
+// as a starting point for a new file.
+func defaultConfigFile() *configFile {
+	config := &configFile{}
+	config.CA.Cert = filepath.Join(defaultOutputDir, defaultCertFileName)
+	config.CA.Key = filepath.Join(defaultOutputDir, defaultKeyFileName)
+	config.Key.Type = defaultKeyType
+	config.Key.CABits = defaultKeyBitSize
+	config.Key.LeafBits = defaultLeafKeyBitSize
+	config.Validity.RootDays = defaultValidityDays
+	config.Validity.IntermediateDays = defaultIntermediateValidityDays
+	config.Validity.LeafDays = defaultLeafValidityDays
+	config.Serial.Mode = serialModeRandom
+	config.Serial.Bits = ca.DefaultSerialBits
+	config.Output.Dir = defaultOutputDir
+	return config
+}
+
+// runConfig implements the config command: it writes a configuration file
+// holding the built-in defaults, or the effective settings of an existing
+// file, for editing.
+func runConfig(args []string) {
+	fs := flag.NewFlagSet("config", flag.ExitOnError)
+	var cfg configFlags
+	cfg.register(fs)
+	outFile := fs.String("out", "", "Optional: write the configuration to this file instead of standard output")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s config [-config ca.yaml] [-out ca.yaml]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Prints a configuration file holding the built-in defaults, or the settings of\n")
+		fmt.Fprintf(os.Stderr, "-config, as a starting point. Pass it to other commands with -config or $%s.\n\n", configEnvVar)
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	config := defaultConfigFile()
+	if cfg.path != "" {
+		var err error
+		if config, err = loadConfigFile(cfg.path); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	}
+	var buf bytes.Buffer
+	buf.WriteString("# go-CA configuration; flags given on the command line take precedence.\n")
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(config); err != nil {
+		log.Fatalf("Error encoding configuration: %v", err)
+	}
+	data := buf.Bytes()
+
+	if *outFile == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if _, err := os.Stat(*outFile); err == nil {
+		log.Fatalf("Error: %s already exists; not overwriting it.", *outFile)
+	}
+	if err := os.WriteFile(*outFile, data, 0644); err != nil {
+		log.Fatalf("Error writing configuration: %v", err)
+	}
+	fmt.Printf("Configuration saved to: %s\n", *outFile)
+}
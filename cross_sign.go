@@ -0,0 +1,114 @@
+// cross_sign.go
+package main
+
+import (
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/prtk1729/certA/pkg/ca"
+)
+
+// runCrossSign implements the cross-sign command: it certifies another CA's
+// key under a CA loaded from disk.
+func runCrossSign(args []string) {
+	fs := flag.NewFlagSet("cross-sign", flag.ExitOnError)
+	targetFile := fs.String("target", "", "Required: certificate PEM file of the CA to cross-sign")
+	caCertFile := fs.String("ca", defaultCertFileName, "Signing CA certificate PEM file")
+	caKeyFile := fs.String("ca-key", defaultKeyFileName, "Signing CA private key PEM file")
+	validityDays := fs.Int("days", 0, "Validity period in days (default: until the target certificate expires)")
+	pathLen := fs.Int("path-len", 0, "Maximum number of CAs allowed below the target in this chain (default: the target's own; -1: unlimited)")
+	outFile := fs.String("out", "", "Path to write the cross-signed certificate (default: -target with a -cross-signed suffix)")
+	var serials serialFlags
+	serials.register(fs)
+	var db certDBFlags
+	db.register(fs)
+	var denylist denylistFlags
+	denylist.register(fs)
+	var caPassphrase passphraseFlags
+	caPassphrase.register(fs, "ca-", "decrypt the signing CA private key with")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s cross-sign -target other-ca.crt -ca root.crt -ca-key root.key [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Signs another CA's key with this one. The certificate keeps the target's subject,\n")
+		fmt.Fprintf(os.Stderr, "key, subject key identifier and constraints, so certificates issued by the target\n")
+		fmt.Fprintf(os.Stderr, "also chain to this CA, e.g. while moving relying parties from an old root to a new one.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if err := checkFlags(fs); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := serials.check(); err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+	if *targetFile == "" {
+		fs.Usage()
+		log.Fatal("Error: -target is required.")
+	}
+	if *outFile == "" {
+		*outFile = strings.TrimSuffix(*targetFile, filepath.Ext(*targetFile)) + "-cross-signed.crt"
+	}
+	if _, err := os.Stat(*outFile); err == nil {
+		log.Fatalf("Error: %s already exists; not overwriting it.", *outFile)
+	}
+
+	targets, err := ca.LoadCertificates(*targetFile)
+	if err != nil {
+		log.Fatalf("Error loading target certificate: %v", err)
+	}
+	target := targets[0]
+	signer, err := ca.LoadCA(*caCertFile, *caKeyFile, caPassphrase.source())
+	if err != nil {
+		log.Fatalf("Error loading CA: %v", err)
+	}
+	list, err := denylist.load(*caCertFile)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := list.Check(target.PublicKey); err != nil {
+		log.Fatalf("Error: refusing to cross-sign: %v", err)
+	}
+
+	dbFile := db.resolve(*caCertFile)
+	opts := ca.CrossSignOptions{ValidityDays: *validityDays, SerialBits: serials.bits}
+	if isFlagSet(fs, "path-len") {
+		opts.MaxPathLen = pathLen
+	}
+	var seq uint64
+	if opts.SerialNumber, seq, err = serials.allocate(dbFile, nil); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	cert, err := signer.CrossSign(target, opts)
+	if err != nil {
+		log.Fatalf("Error cross-signing certificate: %v", err)
+	}
+	perms := DefaultOutputPermissions()
+	if err := prepareOutputDir(filepath.Dir(*outFile), perms, false); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := recordIssued(dbFile, cert, *outFile, seq); err != nil {
+		log.Fatalf("Error recording certificate: %v", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	if err := writeFileWithPermissions(*outFile, certPEM, perms.CertMode, perms); err != nil {
+		log.Fatalf("Error writing certificate: %v", err)
+	}
+
+	fmt.Println("Cross-Signed Certificate")
+	fmt.Printf("  Subject: %s\n", cert.Subject)
+	fmt.Printf("  Issuer: %s\n", cert.Issuer)
+	fmt.Printf("  Serial: %X\n", cert.SerialNumber)
+	fmt.Printf("  Validity: %s to %s\n", cert.NotBefore.Format(time.RFC3339), cert.NotAfter.Format(time.RFC3339))
+	fmt.Printf("  Subject Key Identifier: %s\n", colonHex(cert.SubjectKeyId))
+	fmt.Printf("  Path Length: %s\n", describePathLen(cert.MaxPathLen))
+	fmt.Printf("\nCross-signed certificate saved to: %s\n", *outFile)
+	fmt.Printf("Serve it as an intermediate after the certificates issued by %s, so clients that\n", target.Subject)
+	fmt.Printf("only trust %s can build a chain.\n", signer.Certificate.Subject)
+}
@@ -0,0 +1,114 @@
+// csr.go
+package main
+
+import (
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/prtk1729/certA/pkg/ca"
+)
+
+const (
+	defaultCSRFileName    = "request.csr"
+	defaultCSRKeyFileName = "request.key"
+)
+
+// runCSR implements the csr command: it generates a key pair and a PKCS#10
+// certificate request for submission to this or any other CA.
+func runCSR(args []string) {
+	fs := flag.NewFlagSet("csr", flag.ExitOnError)
+	commonName := fs.String("cn", "", "Required: Common Name (CN) for the request")
+	organization := fs.String("org", "", "Optional: Organization (O) for the request")
+	var subject subjectFlags
+	subject.register(fs, "Repeatable: ")
+	var sans sanFlags
+	sans.register(fs, func(kind string) string {
+		return "Repeatable: " + kind + " to request as a SAN"
+	})
+	var sanPolicy sanPolicyFlags
+	sanPolicy.register(fs)
+	keyType := fs.String("key-type", defaultKeyType, "Key algorithm: "+strings.Join(ca.SupportedKeyTypes, ", "))
+	keyBitSize := fs.Int("bits", defaultLeafKeyBitSize, "RSA key size in bits; ignored for other key types")
+	outputDir := fs.String("out", defaultOutputDir, "Directory to save the request and key files")
+	csrFileName := fs.String("csr-name", defaultCSRFileName, "Filename for the certificate request PEM file")
+	keyFileName := fs.String("key-name", defaultCSRKeyFileName, "Filename for the private key PEM file")
+
+	var cfg configFlags
+	cfg.register(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s csr -cn <name> [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Generates a private key and a PKCS#10 certificate signing request.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	cfg.apply(fs)
+	if err := checkFlags(fs); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	if *commonName == "" {
+		fs.Usage()
+		log.Fatal("Error: -cn is required.")
+	}
+	if !ca.IsValidKeyType(*keyType) {
+		log.Fatalf("Error: unsupported -key-type %q. Supported: %s.", *keyType, strings.Join(ca.SupportedKeyTypes, ", "))
+	}
+
+	opts := ca.IssueOptions{
+		CommonName:   *commonName,
+		Organization: *organization,
+		KeyType:      *keyType,
+		KeyBitSize:   *keyBitSize,
+	}
+	if err := sans.apply(&opts); err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+	var err error
+	if opts.Subject, err = subject.attributes(); err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+	if opts.SANPolicy, err = sanPolicy.policy(); err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+	if err := opts.SANPolicy.Validate(opts); err != nil {
+		log.Fatalf("Error: rejected by SAN policy:\n%v", err)
+	}
+
+	perms := DefaultOutputPermissions()
+	if err := prepareOutputDir(*outputDir, perms, false); err != nil {
+		log.Fatalf("Error preparing output directory: %v", err)
+	}
+	csrPath := filepath.Join(*outputDir, *csrFileName)
+	keyPath := filepath.Join(*outputDir, *keyFileName)
+
+	fmt.Println("Generating Certificate Signing Request...")
+	fmt.Printf("  Common Name: %s\n", opts.CommonName)
+	if attrs := describeSubjectAttributes(opts.Subject); attrs != "" {
+		fmt.Printf("  Subject Attributes: %s\n", attrs)
+	}
+	fmt.Printf("  Key: %s\n", ca.DescribeKeyType(opts.KeyType, opts.KeyBitSize))
+
+	csrBytes, privateKey, err := ca.NewCSR(opts)
+	if err != nil {
+		log.Fatalf("Error generating CSR: %v", err)
+	}
+
+	csrPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrBytes})
+	if err := writeFileWithPermissions(csrPath, csrPEM, perms.CertMode, perms); err != nil {
+		log.Fatalf("Error writing CSR: %v", err)
+	}
+	if err := writePrivateKeyPEM(privateKey, keyPath, perms, nil); err != nil {
+		log.Fatalf("Error writing private key: %v", err)
+	}
+
+	fmt.Printf("\nSuccess!\n")
+	fmt.Printf("  Certificate Request saved to: %s\n", csrPath)
+	fmt.Printf("  Private Key saved to: %s (Keep this file secure!)\n", keyPath)
+}
@@ -0,0 +1,391 @@
+// ct_monitor.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/prtk1729/certA/pkg/ca"
+)
+
+const (
+	ctEntryTypeX509    = 0 // RFC 6962 LogEntryType x509_entry
+	ctEntryTypePrecert = 1 // RFC 6962 LogEntryType precert_entry
+	maxCTResponseSize  = 64 << 20
+)
+
+// ctMonitorState records, per log URL, the index of the next entry to fetch.
+type ctMonitorState map[string]int64
+
+// ctAlert is the JSON payload posted to the webhook for unexpected issuance.
+type ctAlert struct {
+	Log        string    `json:"log"`
+	Index      int64     `json:"index"`
+	Serial     string    `json:"serial"`
+	Issuer     string    `json:"issuer"`
+	Names      []string  `json:"names"`
+	NotBefore  time.Time `json:"not_before"`
+	NotAfter   time.Time `json:"not_after"`
+	SHA256     string    `json:"sha256"`
+	Precert    bool      `json:"precertificate"`
+	ObservedAt time.Time `json:"observed_at"`
+}
+
+// runCTMonitor implements the ct-monitor command: it follows public
+// Certificate Transparency logs and reports certificates for the configured
+// domains that were not issued by one of the expected CAs.
+func runCTMonitor(args []string) {
+	fs := flag.NewFlagSet("ct-monitor", flag.ExitOnError)
+	var logURLs, domains, caFiles stringList
+	fs.Var(&logURLs, "log", "Required, repeatable: CT log base URL (e.g. https://ct.example.com/2025h1)")
+	fs.Var(&domains, "domain", "Required, repeatable: domain to watch; subdomains match too")
+	fs.Var(&caFiles, "ca", "Optional, repeatable: CA PEM file whose issuance is expected (not alerted)")
+	stateFile := fs.String("state", "ct-monitor-state.json", "File recording the last processed position in each log")
+	interval := fs.Duration("interval", 0, "Poll interval; 0 performs a single pass and exits")
+	batchSize := fs.Int("batch", 256, "Maximum entries requested per get-entries call")
+	webhook := fs.String("webhook", "", "Optional: URL to POST a JSON alert to for each unexpected certificate")
+	fromStart := fs.Bool("from-start", false, "For logs without saved state, scan from index 0 instead of the current tree head")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s ct-monitor -log <url> -domain <domain> [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Watches Certificate Transparency logs for unexpected certificates covering your domains.\n")
+		fmt.Fprintf(os.Stderr, "A single pass (-interval 0) exits with status 1 if any alert was raised.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if err := checkFlags(fs); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	if len(logURLs) == 0 || len(domains) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	var expectedCAs []*x509.Certificate
+	for _, path := range caFiles {
+		cas, err := ca.LoadCertificates(path)
+		if err != nil {
+			log.Fatalf("Error loading expected CA: %v", err)
+		}
+		expectedCAs = append(expectedCAs, cas...)
+	}
+
+	state, err := loadCTMonitorState(*stateFile)
+	if err != nil {
+		log.Fatalf("Error loading state: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	monitor := &ctMonitor{
+		client:      &http.Client{Timeout: 30 * time.Second},
+		domains:     normalizeDomains(domains),
+		expectedCAs: expectedCAs,
+		batchSize:   int64(*batchSize),
+		webhook:     *webhook,
+		fromStart:   *fromStart,
+	}
+
+	for {
+		for _, logURL := range logURLs {
+			logURL = strings.TrimSuffix(logURL, "/")
+			next, err := monitor.scanLog(ctx, logURL, state)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("Error scanning %s: %v", logURL, err)
+			}
+			if next >= 0 {
+				state[logURL] = next
+			}
+		}
+		if err := state.save(*stateFile); err != nil {
+			log.Printf("Error saving state: %v", err)
+		}
+
+		if *interval <= 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			fmt.Println("CT monitor stopped.")
+			return
+		case <-time.After(*interval):
+		}
+	}
+
+	if monitor.alerts > 0 {
+		os.Exit(1)
+	}
+}
+
+// ctMonitor holds the settings shared by every log scan.
+type ctMonitor struct {
+	client      *http.Client
+	domains     []string
+	expectedCAs []*x509.Certificate
+	batchSize   int64
+	webhook     string
+	fromStart   bool
+	alerts      int
+}
+
+// scanLog processes all new entries in one log and returns the next index to
+// resume from, or -1 if no progress could be recorded.
+func (m *ctMonitor) scanLog(ctx context.Context, logURL string, state ctMonitorState) (int64, error) {
+	var sth struct {
+		TreeSize int64 `json:"tree_size"`
+	}
+	if err := m.getJSON(ctx, logURL+"/ct/v1/get-sth", &sth); err != nil {
+		return -1, fmt.Errorf("get-sth: %w", err)
+	}
+
+	next, known := state[logURL]
+	if !known {
+		if m.fromStart {
+			next = 0
+		} else {
+			// Without history, start watching from now on.
+			fmt.Printf("%s: starting at tree head %d\n", logURL, sth.TreeSize)
+			return sth.TreeSize, nil
+		}
+	}
+
+	for next < sth.TreeSize {
+		end := next + m.batchSize - 1
+		if end >= sth.TreeSize {
+			end = sth.TreeSize - 1
+		}
+		var batch struct {
+			Entries []struct {
+				LeafInput []byte `json:"leaf_input"`
+				ExtraData []byte `json:"extra_data"`
+			} `json:"entries"`
+		}
+		url := fmt.Sprintf("%s/ct/v1/get-entries?start=%d&end=%d", logURL, next, end)
+		if err := m.getJSON(ctx, url, &batch); err != nil {
+			return next, fmt.Errorf("get-entries: %w", err)
+		}
+		if len(batch.Entries) == 0 {
+			return next, fmt.Errorf("get-entries returned no entries for [%d, %d]", next, end)
+		}
+
+		// Logs may return fewer entries than requested; advance by what we got.
+		for i, entry := range batch.Entries {
+			index := next + int64(i)
+			cert, precert, err := parseCTEntry(entry.LeafInput, entry.ExtraData)
+			if err != nil {
+				log.Printf("%s: skipping entry %d: %v", logURL, index, err)
+				continue
+			}
+			m.check(logURL, index, cert, precert)
+		}
+		next += int64(len(batch.Entries))
+	}
+
+	fmt.Printf("%s: processed up to index %d\n", logURL, next)
+	return next, nil
+}
+
+// check reports a certificate if it covers a watched domain and was not issued
+// by one of the expected CAs.
+func (m *ctMonitor) check(logURL string, index int64, cert *x509.Certificate, precert bool) {
+	names := matchingNames(cert, m.domains)
+	if len(names) == 0 {
+		return
+	}
+	if issuedByAny(cert, m.expectedCAs) {
+		fmt.Printf("[ok] %s #%d: expected certificate for %s\n", logURL, index, strings.Join(names, ", "))
+		return
+	}
+
+	m.alerts++
+	fingerprint := sha256.Sum256(cert.Raw)
+	alert := ctAlert{
+		Log:        logURL,
+		Index:      index,
+		Serial:     fmt.Sprintf("%X", cert.SerialNumber),
+		Issuer:     cert.Issuer.String(),
+		Names:      names,
+		NotBefore:  cert.NotBefore,
+		NotAfter:   cert.NotAfter,
+		SHA256:     fmt.Sprintf("%X", fingerprint[:]),
+		Precert:    precert,
+		ObservedAt: time.Now().UTC(),
+	}
+	fmt.Printf("[ALERT] %s #%d: unexpected certificate for %s issued by %s (serial %s)\n",
+		logURL, index, strings.Join(names, ", "), alert.Issuer, alert.Serial)
+
+	if m.webhook != "" {
+		body, _ := json.Marshal(alert)
+		resp, err := m.client.Post(m.webhook, "application/json", bytes.NewReader(body))
+		if err != nil {
+			log.Printf("Error posting alert to webhook: %v", err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			log.Printf("Webhook returned HTTP %s", resp.Status)
+		}
+	}
+}
+
+// getJSON fetches url and decodes the JSON response into v.
+func (m *ctMonitor) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %s", resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxCTResponseSize)).Decode(v)
+}
+
+// parseCTEntry extracts the certificate from an RFC 6962 log entry. For
+// precertificate entries the full precertificate is taken from extra_data,
+// since leaf_input only carries the TBSCertificate.
+func parseCTEntry(leafInput, extraData []byte) (*x509.Certificate, bool, error) {
+	// MerkleTreeLeaf: version(1) leaf_type(1) timestamp(8) entry_type(2) ...
+	if len(leafInput) < 12 {
+		return nil, false, errors.New("leaf_input too short")
+	}
+	if leafInput[0] != 0 || leafInput[1] != 0 {
+		return nil, false, fmt.Errorf("unsupported leaf version %d / type %d", leafInput[0], leafInput[1])
+	}
+
+	var der []byte
+	var err error
+	precert := false
+	switch entryType := binary.BigEndian.Uint16(leafInput[10:12]); entryType {
+	case ctEntryTypeX509:
+		der, err = readUint24Prefixed(leafInput[12:])
+	case ctEntryTypePrecert:
+		precert = true
+		der, err = readUint24Prefixed(extraData)
+	default:
+		return nil, false, fmt.Errorf("unknown entry type %d", entryType)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	return cert, precert, nil
+}
+
+// readUint24Prefixed reads a TLS-style opaque<0..2^24-1> value.
+func readUint24Prefixed(data []byte) ([]byte, error) {
+	if len(data) < 3 {
+		return nil, errors.New("truncated length prefix")
+	}
+	n := int(data[0])<<16 | int(data[1])<<8 | int(data[2])
+	if len(data) < 3+n {
+		return nil, errors.New("truncated certificate")
+	}
+	return data[3 : 3+n], nil
+}
+
+// normalizeDomains lowercases domains and strips leading dots and wildcards.
+func normalizeDomains(domains []string) []string {
+	normalized := make([]string, 0, len(domains))
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimSpace(d))
+		d = strings.TrimPrefix(d, "*.")
+		d = strings.TrimPrefix(d, ".")
+		if d != "" {
+			normalized = append(normalized, d)
+		}
+	}
+	return normalized
+}
+
+// matchingNames returns the certificate's DNS names (and CN) that fall under
+// any watched domain.
+func matchingNames(cert *x509.Certificate, domains []string) []string {
+	candidates := append([]string{cert.Subject.CommonName}, cert.DNSNames...)
+	seen := map[string]bool{}
+	var matches []string
+	for _, name := range candidates {
+		name = strings.ToLower(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		for _, d := range domains {
+			if name == d || strings.HasSuffix(name, "."+d) {
+				matches = append(matches, name)
+				break
+			}
+		}
+	}
+	return matches
+}
+
+// issuedByAny reports whether cert names one of cas as its issuer, matching on
+// the issuer DN and, when both are present, the key identifier.
+func issuedByAny(cert *x509.Certificate, cas []*x509.Certificate) bool {
+	for _, issuer := range cas {
+		if !bytes.Equal(cert.RawIssuer, issuer.RawSubject) {
+			continue
+		}
+		if len(cert.AuthorityKeyId) > 0 && len(issuer.SubjectKeyId) > 0 && !bytes.Equal(cert.AuthorityKeyId, issuer.SubjectKeyId) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// loadCTMonitorState reads the state file; a missing file yields empty state.
+func loadCTMonitorState(path string) (ctMonitorState, error) {
+	state := ctMonitorState{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse %q: %w", path, err)
+	}
+	return state, nil
+}
+
+// save writes the state file atomically so an interrupted run never leaves a
+// truncated file behind.
+func (s ctMonitorState) save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
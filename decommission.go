@@ -0,0 +1,446 @@
+// decommission.go
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/prtk1729/certA/pkg/ca"
+)
+
+const (
+	// A decommissioned CA publishes no further CRLs, so its final CRL must
+	// stay current for as long as anything could still check it.
+	defaultFinalCRLNextUpdate = 10 * 365 * 24 * time.Hour
+	defaultDecommissionReason = "cessation-of-operation"
+
+	decommissionCRLFileName     = "final.crl"
+	decommissionArchiveFileName = "ca-archive.tar.gz"
+	decommissionEscrowFileName  = "ca-key.escrow.pem"
+	decommissionReportFileName  = "decommission-report.json"
+	decommissionSignatureSuffix = ".sig"
+)
+
+// Key actions for -key-action.
+const (
+	keyActionDestroy = "destroy" // Overwrite and delete the key file
+	keyActionEscrow  = "escrow"  // Re-encrypt the key into the output directory, then destroy the original
+)
+
+// decommissionReport is the chain-of-custody record of a decommissioning. It
+// is signed with the CA key as the key's last use, so it can be attributed
+// to the CA with nothing but the CA certificate.
+type decommissionReport struct {
+	Version            int                  `json:"version"`
+	Decommissioned     time.Time            `json:"decommissioned"`
+	CASubject          string               `json:"ca_subject"`
+	CASPKIPin          string               `json:"ca_spki_pin"`
+	CACertificateSHA   string               `json:"ca_certificate_sha256"`
+	SignatureAlgorithm string               `json:"signature_algorithm"`
+	Revoked            []string             `json:"revoked,omitempty"`     // Serials revoked by the decommissioning
+	Outstanding        []string             `json:"outstanding,omitempty"` // Serials left valid with -revoke=false
+	AlreadyRevoked     int                  `json:"already_revoked"`
+	Expired            int                  `json:"expired"`
+	FinalCRL           decommissionArtifact `json:"final_crl"`
+	CRLNumber          int64                `json:"crl_number"`
+	CRLNextUpdate      time.Time            `json:"crl_next_update"`
+	Archive            decommissionArtifact `json:"archive"`
+	Key                decommissionKey      `json:"key"`
+}
+
+// decommissionArtifact is a file written by the decommissioning.
+type decommissionArtifact struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// decommissionKey records what happened to the CA key.
+type decommissionKey struct {
+	Path      string                `json:"path"`
+	Action    string                `json:"action"`
+	Destroyed bool                  `json:"destroyed"`
+	Error     string                `json:"error,omitempty"` // Why the key file could not be destroyed
+	Escrow    *decommissionArtifact `json:"escrow,omitempty"`
+}
+
+// runDecommission implements the decommission command: it retires a CA for
+// good, leaving behind a final CRL, an archive of its records and a signed
+// report of what was done with its certificates and key.
+func runDecommission(args []string) {
+	fs := flag.NewFlagSet("decommission", flag.ExitOnError)
+	caCertFile := fs.String("ca", defaultCertFileName, "CA certificate PEM file")
+	caKeyFile := fs.String("ca-key", defaultKeyFileName, "CA private key PEM file; destroyed by the decommissioning")
+	var db certDBFlags
+	db.register(fs)
+	outputDir := fs.String("out", "", "Required: new directory, outside the CA directory, for the final CRL, archive, escrowed key and report")
+	confirm := fs.String("confirm", "", "Required: the CA's Common Name, to confirm which CA is being decommissioned")
+	revoke := fs.Bool("revoke", true, "Revoke every active certificate; with -revoke=false they stay valid and are listed in the report as outstanding")
+	reason := fs.String("reason", defaultDecommissionReason, "Revocation reason for active certificates: "+revocationReasonList())
+	nextUpdate := fs.Duration("next-update", defaultFinalCRLNextUpdate, "How long the final CRL stays current; no CRL is published after it")
+	keyAction := fs.String("key-action", keyActionDestroy, "What to do with the CA key: '"+keyActionDestroy+"', or '"+keyActionEscrow+"' to keep an encrypted copy in -out first")
+	var caPassphrase passphraseFlags
+	caPassphrase.register(fs, "ca-", "decrypt the CA private key with")
+	var escrowPassphrase passphraseFlags
+	escrowPassphrase.register(fs, "escrow-", "encrypt the escrowed CA key with")
+	verifyReport := fs.String("verify-report", "", "Instead of decommissioning, check the signature of a decommission report against -ca")
+	var at clockFlags
+	at.register(fs, "Test support: use this RFC 3339 timestamp as the decommissioning time instead of now")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s decommission -ca ca.crt -ca-key ca.key -out <dir> -confirm <CA common name> [options]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s decommission -ca ca.crt -verify-report <report.json>\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Retires a CA: revokes its active certificates, issues a final CRL, archives the\n")
+		fmt.Fprintf(os.Stderr, "CA directory, destroys (or escrows) the key and writes a report signed by the CA.\n")
+		fmt.Fprintf(os.Stderr, "This cannot be undone.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if err := checkFlags(fs); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	caCerts, err := ca.LoadCertificates(*caCertFile)
+	if err != nil {
+		log.Fatalf("Error loading CA certificate: %v", err)
+	}
+	if *verifyReport != "" {
+		if err := checkDecommissionReport(*verifyReport, caCerts[0]); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		fmt.Printf("Report %s is signed by %s.\n", *verifyReport, caCerts[0].Subject)
+		return
+	}
+
+	if *outputDir == "" || *confirm == "" {
+		fs.Usage()
+		log.Fatal("Error: -out and -confirm are required.")
+	}
+	if *confirm != caCerts[0].Subject.CommonName {
+		log.Fatalf("Error: -confirm %q does not match the CA's Common Name %q.", *confirm, caCerts[0].Subject.CommonName)
+	}
+	if _, ok := ca.RevocationReasonNames[*reason]; !ok {
+		log.Fatalf("Error: unknown -reason %q. Supported: %s.", *reason, revocationReasonList())
+	}
+	var escrowSecret []byte
+	switch *keyAction {
+	case keyActionDestroy:
+	case keyActionEscrow:
+		if escrowSecret, err = escrowPassphrase.read(); err != nil {
+			log.Fatalf("Error: %v.", err)
+		}
+		if escrowSecret == nil {
+			log.Fatal("Error: -key-action escrow requires -escrow-passphrase or -escrow-passphrase-file.")
+		}
+	default:
+		log.Fatalf("Error: unknown -key-action %q (use %s or %s).", *keyAction, keyActionDestroy, keyActionEscrow)
+	}
+	clock, err := at.clock()
+	if err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+
+	caDir := filepath.Dir(*caCertFile)
+	if inside, err := isWithinDir(caDir, *outputDir); err != nil {
+		log.Fatalf("Error: %v", err)
+	} else if inside {
+		log.Fatal("Error: -out must be outside the CA directory, which is archived.")
+	}
+	if _, err := os.Stat(*outputDir); err == nil {
+		log.Fatalf("Error: %s already exists; -out must be a new directory.", *outputDir)
+	}
+
+	issuer, err := ca.LoadCA(*caCertFile, *caKeyFile, caPassphrase.source())
+	if err != nil {
+		log.Fatalf("Error loading CA: %v", err)
+	}
+	dbFile := db.resolve(*caCertFile)
+	unlock, err := lockCertDB(dbFile)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	defer unlock()
+	certs, err := loadCertDB(dbFile)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if certs.Decommissioned != nil {
+		log.Fatalf("Error: the CA was already decommissioned at %s.", certs.Decommissioned.Format(time.RFC3339))
+	}
+	caPin, err := ca.SPKIPin(issuer.Certificate.PublicKey)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	signatureAlgorithm, err := reportSignatureAlgorithm(issuer.Key.Public())
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := os.MkdirAll(*outputDir, 0700); err != nil {
+		log.Fatalf("Error creating output directory: %v", err)
+	}
+
+	now := clock.Now().UTC()
+	caSum := sha256.Sum256(issuer.Certificate.Raw)
+	report := decommissionReport{
+		Version:            1,
+		Decommissioned:     now,
+		CASubject:          issuer.Certificate.Subject.String(),
+		CASPKIPin:          caPin,
+		CACertificateSHA:   hex.EncodeToString(caSum[:]),
+		SignatureAlgorithm: signatureAlgorithm.String(),
+		Key:                decommissionKey{Path: *caKeyFile, Action: *keyAction},
+	}
+
+	fmt.Printf("Decommissioning %s...\n", report.CASubject)
+	for _, record := range certs.Certificates {
+		switch record.status(now) {
+		case statusRevoked:
+			report.AlreadyRevoked++
+		case statusExpired:
+			report.Expired++
+		case statusValid:
+			if !*revoke {
+				report.Outstanding = append(report.Outstanding, record.Serial)
+				continue
+			}
+			revokedAt := now
+			record.Status = statusRevoked
+			record.Reason = *reason
+			record.RevokedAt = &revokedAt
+			report.Revoked = append(report.Revoked, record.Serial)
+		}
+	}
+	if *revoke {
+		fmt.Printf("  Revoked: %d active certificates (%s)\n", len(report.Revoked), *reason)
+	} else {
+		fmt.Printf("  Outstanding: %d active certificates left valid\n", len(report.Outstanding))
+	}
+
+	// The final CRL.
+	crlBytes, err := certs.signCRL(issuer, *nextUpdate, clock)
+	if err != nil {
+		log.Fatalf("Error generating final CRL: %v", err)
+	}
+	crlFile := filepath.Join(*outputDir, decommissionCRLFileName)
+	if report.FinalCRL, err = writeDecommissionArtifact(crlFile, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crlBytes}), defaultCertFileMode); err != nil {
+		log.Fatalf("Error writing final CRL: %v", err)
+	}
+	report.CRLNumber = certs.CRLNumber
+	report.CRLNextUpdate = now.Add(*nextUpdate)
+	fmt.Printf("  Final CRL: number %d, current until %s\n", report.CRLNumber, report.CRLNextUpdate.Format(time.RFC3339))
+
+	// The database is only saved once everything else has succeeded, so a
+	// failed decommissioning leaves the CA as it was and can be run again.
+	// The archive already holds the database as it will be saved.
+	certs.Decommissioned = &now
+	finalDB, err := certs.encode()
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	// The archive holds the CA directory as it will stand, without keys.
+	manifest := snapshotManifest{
+		Version:       1,
+		Created:       now,
+		CACertificate: filepath.Base(*caCertFile),
+		CASubject:     report.CASubject,
+		CASPKIPin:     caPin,
+		Certificates:  len(certs.Certificates),
+		CRLNumber:     certs.CRLNumber,
+	}
+	contents, err := collectSnapshotFiles(caDir, &manifest)
+	if err != nil {
+		log.Fatalf("Error reading CA directory %q: %v", caDir, err)
+	}
+	if rel, ok := relativeWithinDir(caDir, dbFile); ok {
+		setSnapshotFile(&manifest, contents, rel, finalDB)
+	}
+	archive, err := writeSnapshotArchive(manifest, contents)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	archiveFile := filepath.Join(*outputDir, decommissionArchiveFileName)
+	if report.Archive, err = writeDecommissionArtifact(archiveFile, archive, defaultCertFileMode); err != nil {
+		log.Fatalf("Error writing archive: %v", err)
+	}
+	fmt.Printf("  Archive: %d files\n", len(manifest.Files))
+
+	if escrowSecret != nil {
+		keyPEM, err := ca.MarshalPrivateKeyPEM(issuer.Key, escrowSecret)
+		if err != nil {
+			log.Fatalf("Error encrypting escrowed key: %v", err)
+		}
+		escrow, err := writeDecommissionArtifact(filepath.Join(*outputDir, decommissionEscrowFileName), keyPEM, defaultKeyFileMode)
+		if err != nil {
+			log.Fatalf("Error writing escrowed key: %v", err)
+		}
+		report.Key.Escrow = &escrow
+		fmt.Printf("  Key escrowed to: %s\n", escrow.Path)
+	}
+	// The key stays in memory to sign the report, which can then record
+	// whether the file was really destroyed.
+	if err := destroyFile(*caKeyFile); err != nil {
+		report.Key.Error = err.Error()
+	} else {
+		report.Key.Destroyed = true
+		fmt.Printf("  Key file destroyed: %s\n", *caKeyFile)
+	}
+
+	reportFile := filepath.Join(*outputDir, decommissionReportFileName)
+	if err := writeDecommissionReport(reportFile, report, issuer.Key); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	fmt.Printf("\nDecommission report saved to: %s (signature: %s)\n", reportFile, reportFile+decommissionSignatureSuffix)
+	fmt.Printf("Check it with '%s decommission -ca %s -verify-report %s'.\n", os.Args[0], *caCertFile, reportFile)
+	if report.Key.Error != "" {
+		log.Fatalf("Error: the CA key was NOT destroyed: %s. The CA is not marked decommissioned; fix this and run decommission again with a new -out.", report.Key.Error)
+	}
+
+	if err := certs.save(dbFile); err != nil {
+		log.Fatalf("Error: %v. The CA key is destroyed, but %s does not record the decommissioning.", err, dbFile)
+	}
+	fmt.Printf("Certificate database marked decommissioned: %s\n", dbFile)
+}
+
+// setSnapshotFile puts data in the snapshot at rel, replacing what was read
+// from disk.
+func setSnapshotFile(manifest *snapshotManifest, contents map[string][]byte, rel string, data []byte) {
+	sum := sha256.Sum256(data)
+	file := snapshotFile{Path: rel, Mode: 0644, Size: int64(len(data)), SHA256: hex.EncodeToString(sum[:])}
+	contents[rel] = data
+	for i := range manifest.Files {
+		if manifest.Files[i].Path == rel {
+			file.Mode = manifest.Files[i].Mode
+			manifest.Files[i] = file
+			return
+		}
+	}
+	manifest.Files = append(manifest.Files, file)
+}
+
+// writeDecommissionArtifact writes data to path and describes it for the
+// report.
+func writeDecommissionArtifact(path string, data []byte, mode os.FileMode) (decommissionArtifact, error) {
+	if err := os.WriteFile(path, data, mode); err != nil {
+		return decommissionArtifact{}, err
+	}
+	sum := sha256.Sum256(data)
+	return decommissionArtifact{Path: path, SHA256: hex.EncodeToString(sum[:])}, nil
+}
+
+// destroyFile overwrites a file with random data, flushes it to disk and
+// removes it. On copy-on-write filesystems and flash storage the old blocks
+// may survive the overwrite, so keys that must never be recovered belong on
+// storage that can be physically destroyed.
+func destroyFile(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err == nil {
+		_, err = io.CopyN(f, rand.Reader, info.Size())
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to overwrite %s: %w", path, err)
+	}
+	return os.Remove(path)
+}
+
+// reportSignatureAlgorithm returns the algorithm of report signatures by
+// the key pub.
+func reportSignatureAlgorithm(pub crypto.PublicKey) (x509.SignatureAlgorithm, error) {
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		return x509.SHA256WithRSA, nil
+	case *ecdsa.PublicKey:
+		return x509.ECDSAWithSHA256, nil
+	case ed25519.PublicKey:
+		return x509.PureEd25519, nil
+	default:
+		return x509.UnknownSignatureAlgorithm, fmt.Errorf("unsupported CA key type %T", pub)
+	}
+}
+
+// writeDecommissionReport writes the report and, next to it, its detached
+// signature by key.
+func writeDecommissionReport(path string, report decommissionReport, key crypto.Signer) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode decommission report: %w", err)
+	}
+	data = append(data, '\n')
+
+	var signature []byte
+	if _, ok := key.Public().(ed25519.PublicKey); ok {
+		signature, err = key.Sign(rand.Reader, data, crypto.Hash(0))
+	} else {
+		digest := sha256.Sum256(data)
+		signature, err = key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to sign decommission report: %w", err)
+	}
+	if err := os.WriteFile(path, data, defaultCertFileMode); err != nil {
+		return fmt.Errorf("failed to write decommission report: %w", err)
+	}
+	if err := os.WriteFile(path+decommissionSignatureSuffix, signature, defaultCertFileMode); err != nil {
+		return fmt.Errorf("failed to write decommission report signature: %w", err)
+	}
+	return nil
+}
+
+// checkDecommissionReport verifies a report's detached signature against the
+// CA certificate.
+func checkDecommissionReport(path string, caCert *x509.Certificate) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read decommission report: %w", err)
+	}
+	signature, err := os.ReadFile(path + decommissionSignatureSuffix)
+	if err != nil {
+		return fmt.Errorf("failed to read decommission report signature: %w", err)
+	}
+	var report decommissionReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return fmt.Errorf("failed to parse decommission report: %w", err)
+	}
+	algorithm, err := reportSignatureAlgorithm(caCert.PublicKey)
+	if err != nil {
+		return err
+	}
+	if err := caCert.CheckSignature(algorithm, data, signature); err != nil {
+		return fmt.Errorf("decommission report %s is not signed by %s: %w", path, caCert.Subject, err)
+	}
+	// A valid signature from the right key on a report about another CA
+	// would mean the key was shared; flag it rather than accept it.
+	pin, err := ca.SPKIPin(caCert.PublicKey)
+	if err != nil {
+		return err
+	}
+	if report.CASPKIPin != pin {
+		return fmt.Errorf("decommission report %s describes a CA with SPKI pin %s, not %s", path, report.CASPKIPin, pin)
+	}
+	return nil
+}
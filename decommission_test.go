@@ -0,0 +1,94 @@
+// decommission_test.go
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prtk1729/certA/pkg/ca"
+)
+
+func TestDecommission(t *testing.T) {
+	t.Setenv(configEnvVar, "")
+	caDir, leafDir, outDir := t.TempDir(), t.TempDir(), filepath.Join(t.TempDir(), "decommissioned")
+	caCert, caKey := filepath.Join(caDir, defaultCertFileName), filepath.Join(caDir, defaultKeyFileName)
+	dbFile := filepath.Join(caDir, certDBFileName)
+	runInit([]string{"-cn", "Retiring Root CA", "-key-type", ca.KeyTypeEd25519, "-out", caDir,
+		"-non-interactive", "-preset-file", filepath.Join(leafDir, "presets.json")})
+	runIssue([]string{"-ca", caCert, "-ca-key", caKey, "-cn", "app.example.com", "-key-type", ca.KeyTypeEd25519, "-out", leafDir})
+	issue := testIssuer(t)
+
+	runDecommission([]string{"-ca", caCert, "-ca-key", caKey, "-out", outDir, "-confirm", "Retiring Root CA"})
+
+	if _, err := os.Stat(caKey); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("CA key after decommissioning: %v, want it removed", err)
+	}
+	db, err := loadCertDB(dbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.Decommissioned == nil {
+		t.Fatal("certificate database is not marked decommissioned")
+	}
+	if len(db.Certificates) != 1 || db.Certificates[0].Status != statusRevoked || db.Certificates[0].Reason != defaultDecommissionReason {
+		t.Errorf("certificate records after decommissioning = %+v, want one revoked for %s", db.Certificates, defaultDecommissionReason)
+	}
+	if err := recordIssued(dbFile, issue(big.NewInt(2)), "", 0); err == nil || !strings.Contains(err.Error(), "decommissioned") {
+		t.Errorf("recordIssued after decommissioning: error = %v, want a refusal", err)
+	}
+
+	caCerts, err := ca.LoadCertificates(caCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reportFile := filepath.Join(outDir, decommissionReportFileName)
+	if err := checkDecommissionReport(reportFile, caCerts[0]); err != nil {
+		t.Errorf("checkDecommissionReport: %v", err)
+	}
+	report, err := os.ReadFile(reportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded decommissionReport
+	if err := json.Unmarshal(report, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Key.Destroyed || len(decoded.Revoked) != 1 {
+		t.Errorf("report = %+v, want the key destroyed and one certificate revoked", decoded)
+	}
+	tampered := filepath.Join(outDir, "tampered.json")
+	if err := os.WriteFile(tampered, []byte(strings.Replace(string(report), `"destroyed": true`, `"destroyed": false`, 1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(reportFile+decommissionSignatureSuffix, tampered+decommissionSignatureSuffix); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkDecommissionReport(tampered, caCerts[0]); err == nil {
+		t.Error("checkDecommissionReport accepted a modified report")
+	}
+
+	// The archive holds the database as saved, and no keys.
+	archive, err := os.ReadFile(filepath.Join(outDir, decommissionArchiveFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, contents, err := readSnapshotArchive(archive)
+	if err != nil {
+		t.Fatalf("readSnapshotArchive: %v", err)
+	}
+	saved, err := os.ReadFile(dbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents[certDBFileName]) != string(saved) {
+		t.Errorf("archived %s differs from the saved database", certDBFileName)
+	}
+	if _, archived := contents[defaultKeyFileName]; archived {
+		t.Errorf("archive contains %s", defaultKeyFileName)
+	}
+}
@@ -0,0 +1,122 @@
+// denylist.go
+package main
+
+import (
+	"crypto"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/prtk1729/certA/pkg/ca"
+)
+
+const keyDenylistFileName = "key-denylist.txt"
+
+// defaultKeyDenylist returns the key denylist path for a CA: a file next to
+// its certificate, like the certificate database.
+func defaultKeyDenylist(caCertFile string) string {
+	return filepath.Join(filepath.Dir(caCertFile), keyDenylistFileName)
+}
+
+// denylistFlags are the flags shared by commands that certify keys.
+type denylistFlags struct {
+	path string
+}
+
+func (f *denylistFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.path, "denylist", "", "Key denylist file; keys listed in it are never certified (default: "+keyDenylistFileName+" next to the CA certificate, if present)")
+}
+
+// file returns the denylist path for the CA at caCertFile.
+func (f *denylistFlags) file(caCertFile string) string {
+	if f.path == "" {
+		return defaultKeyDenylist(caCertFile)
+	}
+	return f.path
+}
+
+// load reads the denylist for the CA at caCertFile.
+func (f *denylistFlags) load(caCertFile string) (*ca.KeyDenylist, error) {
+	path := f.file(caCertFile)
+	if f.path == "" {
+		return ca.LoadKeyDenylist(path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		// An explicitly named list that cannot be read must not silently
+		// turn into an empty one.
+		return nil, fmt.Errorf("key denylist: %w", err)
+	}
+	return ca.LoadKeyDenylist(path)
+}
+
+// denyCompromised adds the key of the certificate with the given serial,
+// revoked for key compromise, to the denylist of the CA at caCertFile, so it
+// is not certified again under a new serial. It returns the key's SPKI pin
+// and the path of the list.
+func (f *denylistFlags) denyCompromised(caCertFile string, key crypto.PublicKey, serial string) (pin, path string, err error) {
+	path = f.file(caCertFile)
+	pin, err = ca.AppendKeyDenylist(path, key, "key-compromise, serial "+serial)
+	return pin, path, err
+}
+
+// runDenylist implements the denylist command: it lists a key denylist and
+// appends keys to it, e.g. after a key compromise or to import known weak keys.
+func runDenylist(args []string) {
+	fs := flag.NewFlagSet("denylist", flag.ExitOnError)
+	caCertFile := fs.String("ca", defaultCertFileName, "CA certificate PEM file whose denylist to use")
+	path := fs.String("denylist", "", "Key denylist file (default: "+keyDenylistFileName+" next to the CA certificate)")
+	var add stringList
+	fs.Var(&add, "add", "Repeatable: deny the key of a private key, public key, certificate or CSR PEM file")
+	comment := fs.String("comment", "", "Optional: comment recorded with the keys added by -add")
+
+	var cfg configFlags
+	cfg.register(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s denylist [-ca ca.crt | -denylist file] [-add compromised.crt]... [-comment text]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Lists and extends the key denylist checked by issue and sign-csr.\n")
+		fmt.Fprintf(os.Stderr, "The file holds one SPKI SHA-256 hash per line (base64 with an optional 'sha256/'\n")
+		fmt.Fprintf(os.Stderr, "prefix, or hex), optionally followed by '# comment', so external lists can be appended.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	cfg.apply(fs)
+
+	if *path == "" {
+		*path = defaultKeyDenylist(*caCertFile)
+	}
+	for _, file := range add {
+		pub, err := loadPinnedPublicKey(file)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		note := *comment
+		if note == "" {
+			note = "from " + filepath.Base(file)
+		}
+		pin, err := ca.AppendKeyDenylist(*path, pub, note)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		fmt.Printf("Denied key sha256/%s from %s\n", pin, file)
+	}
+	if len(add) > 0 {
+		fmt.Println()
+	}
+
+	denylist, err := ca.LoadKeyDenylist(*path)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	fmt.Printf("Key denylist %s (%d keys):\n", *path, denylist.Len())
+	for _, pin := range denylist.Pins() {
+		if c := denylist.Comment(pin); c != "" {
+			fmt.Printf("  sha256/%s  %s\n", pin, c)
+		} else {
+			fmt.Printf("  sha256/%s\n", pin)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// deterministic_test.go
+package main
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prtk1729/certA/pkg/ca"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// reproducibleArgs are the flags that make init, issue and selfsign output
+// byte-identical across runs.
+var reproducibleArgs = []string{"-deterministic-seed", "golden", "-fixed-time", "2025-01-01T00:00:00Z"}
+
+// runReproducible runs init, issue and selfsign in dir with reproducibleArgs
+// and returns the contents of the files they write, by name.
+func runReproducible(t *testing.T, dir string) map[string][]byte {
+	t.Helper()
+	args := func(extra ...string) []string {
+		return append(append([]string{}, reproducibleArgs...), extra...)
+	}
+	runInit(args("-cn", "Golden Root CA", "-org", "Example Corp", "-key-type", ca.KeyTypeEd25519,
+		"-out", dir, "-non-interactive", "-preset-file", filepath.Join(dir, "presets.json")))
+	caCert, caKey := filepath.Join(dir, defaultCertFileName), filepath.Join(dir, defaultKeyFileName)
+	runIssue(args("-ca", caCert, "-ca-key", caKey, "-cn", "app.example.com", "-key-type", ca.KeyTypeEd25519,
+		"-out", dir, "-db", filepath.Join(dir, certDBFileName)))
+
+	// selfsign signs a CSR with the key issue generated.
+	key, err := ca.LoadPrivateKey(filepath.Join(dir, defaultLeafKeyFileName), nil)
+	if err != nil {
+		t.Fatalf("loading the issued key: %v", err)
+	}
+	csrDER, err := x509.CreateCertificateRequest(nil, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "app.example.com"},
+		DNSNames: []string{"app.example.com"},
+	}, key)
+	if err != nil {
+		t.Fatalf("creating the CSR: %v", err)
+	}
+	csrFile := filepath.Join(dir, "app.csr")
+	if err := os.WriteFile(csrFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	selfSigned := filepath.Join(dir, "selfsigned.crt")
+	runSelfSign(args("-csr", csrFile, "-key", filepath.Join(dir, defaultLeafKeyFileName), "-out", selfSigned))
+
+	files := map[string][]byte{}
+	for _, name := range []string{defaultCertFileName, defaultKeyFileName, defaultLeafCertFileName, defaultLeafKeyFileName, "selfsigned.crt"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[name] = data
+	}
+	return files
+}
+
+func TestReproducibleOutput(t *testing.T) {
+	t.Setenv(configEnvVar, "")
+	first := runReproducible(t, t.TempDir())
+	second := runReproducible(t, t.TempDir())
+	for name, data := range first {
+		if !bytes.Equal(data, second[name]) {
+			t.Errorf("%s differs between two runs with the same -deterministic-seed and -fixed-time", name)
+		}
+	}
+
+	// Certificates are compared against the golden files too, so template
+	// changes show up in review. Keys are covered through their public half.
+	for _, name := range []string{defaultCertFileName, defaultLeafCertFileName, "selfsigned.crt"} {
+		golden := filepath.Join("testdata", "deterministic", name)
+		if *updateGolden {
+			if err := os.MkdirAll(filepath.Dir(golden), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(golden, first[name], 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("%v (run go test -update to create it)", err)
+		}
+		if !bytes.Equal(first[name], want) {
+			t.Errorf("%s differs from %s; if the change is intended, run go test -update", name, golden)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+// dr_test.go
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testManifest returns a manifest listing files, with correct checksums.
+func testManifest(caCert string, files map[string]string) snapshotManifest {
+	manifest := snapshotManifest{Version: 1, Created: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), CACertificate: caCert}
+	for name, data := range files {
+		sum := sha256.Sum256([]byte(data))
+		manifest.Files = append(manifest.Files, snapshotFile{Path: name, Mode: 0644, Size: int64(len(data)), SHA256: hex.EncodeToString(sum[:])})
+	}
+	return manifest
+}
+
+// testArchive writes a snapshot archive of manifest and files.
+func testArchive(t *testing.T, manifest snapshotManifest, files map[string]string) []byte {
+	t.Helper()
+	contents := map[string][]byte{}
+	for name, data := range files {
+		contents[name] = []byte(data)
+	}
+	archive, err := writeSnapshotArchive(manifest, contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return archive
+}
+
+// rewriteArchive returns archive without the entry named drop and, unless
+// add is empty, with an entry add holding data appended.
+func rewriteArchive(t *testing.T, archive []byte, drop, add, data string) []byte {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	var buf bytes.Buffer
+	out := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(out)
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			break
+		}
+		if header.Name == drop {
+			continue
+		}
+		tw.WriteHeader(header)
+		var entry bytes.Buffer
+		entry.ReadFrom(tr)
+		tw.Write(entry.Bytes())
+	}
+	if add != "" {
+		tw.WriteHeader(&tar.Header{Name: add, Mode: 0644, Size: int64(len(data))})
+		tw.Write([]byte(data))
+	}
+	tw.Close()
+	out.Close()
+	return buf.Bytes()
+}
+
+func TestReadSnapshotArchive(t *testing.T) {
+	files := map[string]string{"ca.crt": "certificate", "certdb.json": "{}", "issued/server.crt": "leaf"}
+	valid := testArchive(t, testManifest("ca.crt", files), files)
+
+	manifest, contents, err := readSnapshotArchive(valid)
+	if err != nil {
+		t.Fatalf("readSnapshotArchive: %v", err)
+	}
+	if manifest.CACertificate != "ca.crt" || len(contents) != len(files) {
+		t.Fatalf("readSnapshotArchive = %+v, %d files", manifest, len(contents))
+	}
+	for name, data := range files {
+		if string(contents[name]) != data {
+			t.Errorf("%s = %q, want %q", name, contents[name], data)
+		}
+	}
+
+	tampered := testManifest("ca.crt", files)
+	tampered.Files[0].SHA256 = strings.Repeat("0", 64)
+	badVersion := testManifest("ca.crt", files)
+	badVersion.Version = 2
+
+	unsafe := func(name string) []byte {
+		files := map[string]string{"ca.crt": "certificate", name: "evil"}
+		return testArchive(t, testManifest("ca.crt", files), files)
+	}
+	unsafeCA := func(caCert string) []byte {
+		return testArchive(t, testManifest(caCert, files), files)
+	}
+	keyOutside := testManifest("ca.crt", files)
+	keyOutside.CAKey = "../ca.key"
+
+	var noManifest bytes.Buffer
+	gz := gzip.NewWriter(&noManifest)
+	tar.NewWriter(gz).Close()
+	gz.Close()
+
+	tests := []struct {
+		name    string
+		archive []byte
+		wantErr string
+	}{
+		{"checksum mismatch", testArchive(t, tampered, files), "does not match its checksum"},
+		{"unsupported version", testArchive(t, badVersion, files), "unsupported snapshot version"},
+		{"file missing", rewriteArchive(t, valid, "certdb.json", "", ""), "missing certdb.json"},
+		{"file not in manifest", rewriteArchive(t, valid, "", "extra.crt", "x"), "not in its manifest: extra.crt"},
+		{"unlisted unsafe path", rewriteArchive(t, valid, "", "../extra.crt", "x"), "not in its manifest: ../extra.crt"},
+		{"no manifest", noManifest.Bytes(), "no manifest"},
+		{"not gzip", []byte("plain text"), "failed to read snapshot archive"},
+		{"parent directory", unsafe("../outside.crt"), "unsafe path"},
+		{"absolute path", unsafe("/etc/cron.d/evil"), "unsafe path"},
+		{"unclean path", unsafe("issued/../../outside.crt"), "unsafe path"},
+		{"dot segment", unsafe("./ca.key"), "unsafe path"},
+		{"CA certificate outside", unsafeCA("../ca.crt"), "unsafe path"},
+		{"CA key outside", testArchive(t, keyOutside, files), "unsafe path"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := readSnapshotArchive(tt.archive)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("readSnapshotArchive() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSealOpenSnapshot(t *testing.T) {
+	archive := []byte("a snapshot archive")
+	passphrase := []byte("correct horse battery staple")
+	sealed, err := sealSnapshot(archive, passphrase)
+	if err != nil {
+		t.Fatalf("sealSnapshot: %v", err)
+	}
+	if bytes.Contains(sealed, archive) {
+		t.Fatal("sealed snapshot contains the archive in clear text")
+	}
+	opened, err := openSnapshot(sealed, passphrase)
+	if err != nil {
+		t.Fatalf("openSnapshot: %v", err)
+	}
+	if !bytes.Equal(opened, archive) {
+		t.Fatalf("openSnapshot = %q, want %q", opened, archive)
+	}
+
+	if _, err := openSnapshot(sealed, []byte("wrong passphrase")); err == nil {
+		t.Error("openSnapshot with the wrong passphrase succeeded")
+	}
+	// Every byte is authenticated: the magic, salt and nonce of the header
+	// as well as the ciphertext and tag.
+	regions := map[string]int{
+		"magic":      0,
+		"salt":       len(snapshotMagic),
+		"nonce":      len(snapshotMagic) + snapshotSaltSize,
+		"ciphertext": len(sealed) - 20,
+		"tag":        len(sealed) - 1,
+	}
+	for region, offset := range regions {
+		modified := bytes.Clone(sealed)
+		modified[offset] ^= 1
+		if _, err := openSnapshot(modified, passphrase); err == nil {
+			t.Errorf("openSnapshot with a modified %s succeeded", region)
+		}
+	}
+	for _, size := range []int{0, len(snapshotMagic), len(snapshotMagic) + snapshotSaltSize + 4, len(sealed) - 1} {
+		if _, err := openSnapshot(sealed[:size], passphrase); err == nil {
+			t.Errorf("openSnapshot of the first %d bytes succeeded", size)
+		}
+	}
+}
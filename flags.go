@@ -0,0 +1,268 @@
+// flags.go
+package main
+
+import (
+	"crypto/x509/pkix"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/mail"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/prtk1729/certA/pkg/ca"
+)
+
+// stringList is a flag.Value that collects every occurrence of a repeatable
+// flag, e.g. -domain a.example -domain b.example.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// sanFlags collects the repeatable Subject Alternative Name flags shared by
+// the issuing commands.
+type sanFlags struct {
+	dns, ip, email, uri stringList
+}
+
+// register adds -dns, -ip, -email and -uri to fs. describe turns a SAN kind
+// such as "DNS name" into that flag's usage text.
+func (s *sanFlags) register(fs *flag.FlagSet, describe func(kind string) string) {
+	fs.Var(&s.dns, "dns", describe("DNS name"))
+	fs.Var(&s.ip, "ip", describe("IP address"))
+	fs.Var(&s.email, "email", describe("email address"))
+	fs.Var(&s.uri, "uri", describe("URI"))
+}
+
+// apply parses the collected values into the SAN fields of opts.
+func (s *sanFlags) apply(opts *ca.IssueOptions) error {
+	opts.DNSNames = append(opts.DNSNames, s.dns...)
+	for _, value := range s.ip {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return fmt.Errorf("invalid -ip %q", value)
+		}
+		opts.IPAddresses = append(opts.IPAddresses, ip)
+	}
+	for _, value := range s.email {
+		if _, err := mail.ParseAddress(value); err != nil || strings.ContainsAny(value, "<> ") {
+			return fmt.Errorf("invalid -email %q: expected a bare address like user@example.com", value)
+		}
+		opts.EmailAddresses = append(opts.EmailAddresses, value)
+	}
+	for _, value := range s.uri {
+		u, err := url.Parse(value)
+		if err != nil || u.Scheme == "" {
+			return fmt.Errorf("invalid -uri %q: expected an absolute URI like spiffe://example.org/service", value)
+		}
+		opts.URIs = append(opts.URIs, u)
+	}
+	return nil
+}
+
+// sanPolicyFlags holds the flags that configure SAN validation.
+type sanPolicyFlags struct {
+	rules      string
+	uriSchemes stringList
+}
+
+func (f *sanPolicyFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.rules, "san-rules", strings.Join(ca.SANRuleNames(), ","), "Comma-separated SAN validation rules to apply ('none' to disable)")
+	fs.Var(&f.uriSchemes, "allow-uri-scheme", "Repeatable: URI scheme allowed in URI SANs (default: any)")
+}
+
+// policy builds the SANPolicy selected by the flags.
+func (f *sanPolicyFlags) policy() (ca.SANPolicy, error) {
+	policy := ca.SANPolicy{URISchemes: f.uriSchemes}
+	if f.rules == "none" {
+		return policy, nil
+	}
+	for _, name := range strings.Split(f.rules, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !slices.Contains(ca.SANRuleNames(), name) {
+			return ca.SANPolicy{}, fmt.Errorf("unknown -san-rules entry %q (available: %s)", name, strings.Join(ca.SANRuleNames(), ", "))
+		}
+		policy.Rules = append(policy.Rules, name)
+	}
+	return policy, nil
+}
+
+// profileFlags holds the -profile flag of the commands that issue
+// end-entity certificates.
+type profileFlags struct {
+	name string
+}
+
+func (f *profileFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.name, "profile", "", "Optional: issuance profile setting key usages, EKUs, the validity cap and allowed SAN types: "+strings.Join(ca.ProfileNames(), ", "))
+}
+
+// profile returns the selected profile, or nil if none was given.
+func (f *profileFlags) profile() (*ca.Profile, error) {
+	if f.name == "" {
+		return nil, nil
+	}
+	return ca.LookupProfile(f.name)
+}
+
+// printProfiles lists the available profiles for a command's usage text.
+func printProfiles(w io.Writer) {
+	fmt.Fprintf(w, "\nProfiles:\n")
+	for _, name := range ca.ProfileNames() {
+		p, _ := ca.LookupProfile(name)
+		fmt.Fprintf(w, "  %-13s %s\n", p.Name, p.Description)
+	}
+}
+
+// subjectFlags collects the optional subject attribute flags shared by the
+// commands that name a certificate or request.
+type subjectFlags struct {
+	country, province, locality, ou, rdn stringList
+}
+
+// register adds -country, -state, -locality, -ou and -rdn to fs. prefix
+// starts each usage text, e.g. "Repeatable: ".
+func (s *subjectFlags) register(fs *flag.FlagSet, prefix string) {
+	fs.Var(&s.country, "country", prefix+"Country (C) as a two-letter ISO 3166 code")
+	fs.Var(&s.province, "state", prefix+"State or Province (ST)")
+	fs.Var(&s.locality, "locality", prefix+"Locality (L)")
+	fs.Var(&s.ou, "ou", prefix+"Organizational Unit (OU)")
+	fs.Var(&s.rdn, "rdn", prefix+"further subject attribute as OID=value or name=value (street, postalCode, serialNumber, title, DC, emailAddress)")
+}
+
+// attributes parses the collected values.
+func (s *subjectFlags) attributes() (ca.SubjectAttributes, error) {
+	attrs := ca.SubjectAttributes{
+		Country:            s.country,
+		Province:           s.province,
+		Locality:           s.locality,
+		OrganizationalUnit: s.ou,
+	}
+	for _, value := range s.rdn {
+		rdn, err := ca.ParseRDN(value)
+		if err != nil {
+			return ca.SubjectAttributes{}, fmt.Errorf("invalid -rdn: %w", err)
+		}
+		attrs.ExtraNames = append(attrs.ExtraNames, rdn)
+	}
+	// Validate names the offending attribute itself.
+	if err := attrs.Validate(); err != nil {
+		return ca.SubjectAttributes{}, err
+	}
+	return attrs, nil
+}
+
+// nameConstraintFlags collects the repeatable name constraint flags of the
+// commands that create CA certificates.
+type nameConstraintFlags struct {
+	permitDNS, excludeDNS, permitIP, excludeIP       stringList
+	permitEmail, excludeEmail, permitURI, excludeURI stringList
+	critical                                         bool
+}
+
+func (f *nameConstraintFlags) register(fs *flag.FlagSet) {
+	fs.Var(&f.permitDNS, "permit-dns", "Repeatable: name constraint permitting a DNS domain and its subdomains (leading dot: subdomains only), e.g. .internal.example.com")
+	fs.Var(&f.excludeDNS, "exclude-dns", "Repeatable: name constraint excluding a DNS domain, as for -permit-dns")
+	fs.Var(&f.permitIP, "permit-ip", "Repeatable: name constraint permitting an IP range in CIDR notation, e.g. 10.0.0.0/8")
+	fs.Var(&f.excludeIP, "exclude-ip", "Repeatable: name constraint excluding an IP range in CIDR notation")
+	fs.Var(&f.permitEmail, "permit-email", "Repeatable: name constraint permitting a mailbox, a host (example.com) or a domain (.example.com)")
+	fs.Var(&f.excludeEmail, "exclude-email", "Repeatable: name constraint excluding a mailbox, host or domain, as for -permit-email")
+	fs.Var(&f.permitURI, "permit-uri", "Repeatable: name constraint permitting URIs on a host (example.com) or a domain (.example.com)")
+	fs.Var(&f.excludeURI, "exclude-uri", "Repeatable: name constraint excluding URIs on a host or domain, as for -permit-uri")
+	fs.BoolVar(&f.critical, "name-constraints-critical", true, "Mark the name constraints extension critical, as RFC 5280 requires")
+}
+
+// constraints parses the collected values.
+func (f *nameConstraintFlags) constraints() (ca.NameConstraints, error) {
+	constraints := ca.NameConstraints{
+		PermittedDNSDomains:     f.permitDNS,
+		ExcludedDNSDomains:      f.excludeDNS,
+		PermittedEmailAddresses: f.permitEmail,
+		ExcludedEmailAddresses:  f.excludeEmail,
+		PermittedURIDomains:     f.permitURI,
+		ExcludedURIDomains:      f.excludeURI,
+		NotCritical:             !f.critical,
+	}
+	var err error
+	if constraints.PermittedIPRanges, err = parseIPRanges("permit-ip", f.permitIP); err != nil {
+		return ca.NameConstraints{}, err
+	}
+	if constraints.ExcludedIPRanges, err = parseIPRanges("exclude-ip", f.excludeIP); err != nil {
+		return ca.NameConstraints{}, err
+	}
+	if err := constraints.Validate(); err != nil {
+		return ca.NameConstraints{}, fmt.Errorf("invalid name constraints:\n%w", err)
+	}
+	return constraints, nil
+}
+
+// parseIPRanges parses the CIDR values of the flag name.
+func parseIPRanges(name string, values []string) ([]*net.IPNet, error) {
+	var ranges []*net.IPNet
+	for _, value := range values {
+		ip, r, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -%s %q: expected CIDR notation such as 10.0.0.0/8", name, value)
+		}
+		if !ip.Equal(r.IP) {
+			return nil, fmt.Errorf("invalid -%s %q: host bits are set (did you mean %s?)", name, value, r)
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// describeNameConstraints renders constraints for display, or "" if there
+// are none.
+func describeNameConstraints(constraints ca.NameConstraints) string {
+	var parts []string
+	add := func(label string, values []string) {
+		if len(values) > 0 {
+			parts = append(parts, label+" "+strings.Join(values, ", "))
+		}
+	}
+	ipStrings := func(ranges []*net.IPNet) []string {
+		var values []string
+		for _, r := range ranges {
+			values = append(values, r.String())
+		}
+		return values
+	}
+	add("permit DNS", constraints.PermittedDNSDomains)
+	add("exclude DNS", constraints.ExcludedDNSDomains)
+	add("permit IP", ipStrings(constraints.PermittedIPRanges))
+	add("exclude IP", ipStrings(constraints.ExcludedIPRanges))
+	add("permit email", constraints.PermittedEmailAddresses)
+	add("exclude email", constraints.ExcludedEmailAddresses)
+	add("permit URI", constraints.PermittedURIDomains)
+	add("exclude URI", constraints.ExcludedURIDomains)
+	if len(parts) > 0 && constraints.NotCritical {
+		parts = append(parts, "non-critical")
+	}
+	return strings.Join(parts, "; ")
+}
+
+// describeSubjectAttributes renders attrs for display, or "" if there are
+// none.
+func describeSubjectAttributes(attrs ca.SubjectAttributes) string {
+	name := pkix.Name{
+		Country:            attrs.Country,
+		Province:           attrs.Province,
+		Locality:           attrs.Locality,
+		OrganizationalUnit: attrs.OrganizationalUnit,
+		ExtraNames:         attrs.ExtraNames,
+	}
+	return name.String()
+}
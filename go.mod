@@ -1,3 +1,9 @@
 module github.com/prtk1729/certA
 
 go 1.21.4
+
+require (
+	golang.org/x/crypto v0.33.0
+	golang.org/x/net v0.21.0
+	gopkg.in/yaml.v3 v3.0.1
+)
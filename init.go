@@ -0,0 +1,312 @@
+// init.go
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/prtk1729/certA/pkg/ca"
+)
+
+// runInit implements the init command: it generates a self-signed root CA
+// certificate and private key, prompting for required values not given as
+// flags.
+func runInit(args []string) {
+	// --- CLI Setup ---
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	fmt.Println("Minimal Go Certificate Authority Generator")
+	fmt.Println("----------------------------------------")
+
+	// Define flags
+	commonName := fs.String("cn", "", "Required: Common Name (CN) for the CA (e.g., 'My Corp Root CA')")
+	organization := fs.String("org", "", "Optional: Organization (O) for the CA (e.g., 'My Corp')")
+	var subject subjectFlags
+	subject.register(fs, "Repeatable: ")
+	validityDays := fs.Int("days", defaultValidityDays, "Validity period in days")
+	notBefore := fs.String("not-before", "", "Optional: absolute start of validity (RFC 3339, e.g. '2025-01-01T00:00:00Z')")
+	notAfter := fs.String("not-after", "", "Optional: absolute end of validity (RFC 3339); overrides -days")
+	keyType := fs.String("key-type", defaultKeyType, "Key algorithm: "+strings.Join(ca.SupportedKeyTypes, ", "))
+	keyBitSize := fs.Int("bits", defaultKeyBitSize, "RSA key size in bits (e.g., 2048, 4096); ignored for other key types")
+	skidMethod := fs.String("skid-method", ca.SKIDMethodSHA1, "Subject key identifier method: 'sha1' or 'sha256' (truncated, RFC 7093)")
+	serialBits := fs.Int("serial-bits", ca.DefaultSerialBits, fmt.Sprintf("Serial number length in bits (%d-%d)", ca.MinSerialBits, ca.MaxSerialBits))
+	outputDir := fs.String("out", defaultOutputDir, "Directory to save the certificate and key files")
+	certFileName := fs.String("cert-name", defaultCertFileName, "Filename for the CA certificate PEM file")
+	keyFileName := fs.String("key-name", defaultKeyFileName, "Filename for the CA private key PEM file")
+	requireExplicitPolicy := fs.Int("require-explicit-policy", -1, "Optional: policyConstraints requireExplicitPolicy skip count (-1 to omit)")
+	inhibitPolicyMapping := fs.Int("inhibit-policy-mapping", -1, "Optional: policyConstraints inhibitPolicyMapping skip count (-1 to omit)")
+	certMode := fs.String("cert-mode", fmt.Sprintf("%04o", defaultCertFileMode), "Octal file mode for the certificate file")
+	keyMode := fs.String("key-mode", fmt.Sprintf("%04o", defaultKeyFileMode), "Octal file mode for the private key file")
+	dirMode := fs.String("dir-mode", fmt.Sprintf("%04o", defaultDirMode), "Octal mode for the output directory if it is created")
+	owner := fs.String("owner", "", "Optional: user name or UID to own the output files")
+	group := fs.String("group", "", "Optional: group name or GID to own the output files")
+	strictPerms := fs.Bool("strict-perms", false, "Refuse to write into a group- or world-writable output directory")
+	answersFile := fs.String("answers-file", "", "Optional: JSON file answering interactive prompts (e.g. {\"cn\": \"My CA\"})")
+	lang := fs.String("lang", detectLocale(), "Language for interactive prompts (en, de, es, fr)")
+	nonInteractive := fs.Bool("non-interactive", !stdinIsTerminal(), "Never prompt; fail if a required value is missing (default: true when stdin is not a terminal)")
+	presetName := fs.String("preset", "", "Optional: load flag values saved earlier with -save-preset")
+	savePresetName := fs.String("save-preset", "", "Optional: save this run's flag values under the given preset name; presets are only written with this flag")
+	presetFile := fs.String("preset-file", defaultPresetFile(), "Path to the preset state file")
+	inhibitAnyPolicy := fs.Int("inhibit-any-policy", -1, "Optional: inhibitAnyPolicy skip count (-1 to omit)")
+	pathLen := fs.Int("path-len", ca.DefaultRootPathLen, "Maximum number of intermediate CA levels below the root (0: may only issue leaves, -1: unlimited)")
+	var nameConstraints nameConstraintFlags
+	nameConstraints.register(fs)
+	var reproducible reproducibleFlags
+	reproducible.register(fs)
+	var keyPassphrase passphraseFlags
+	keyPassphrase.register(fs, "", "encrypt the CA private key with")
+	var cfg configFlags
+	cfg.register(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s init [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Generates a self-signed root CA certificate and private key.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nExample:\n")
+		fmt.Fprintf(os.Stderr, "  %s init -cn=\"My Test CA\" -org=\"Test Org\" -days=730 -bits=4096 -out=./my_ca\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s init -cn=\"My Test CA\" -key-type=ecdsa-p384 -out=./my_ca\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s init -cn=\"My Test CA\" -key-type=ecdsa-p384 -save-preset=corp\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s init -preset=corp -cn=\"My Other CA\" -days=365   # reuse a saved preset, overriding some values\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nIf required flags are omitted, you will be prompted interactively, unless stdin is\n")
+		fmt.Fprintf(os.Stderr, "not a terminal or -non-interactive is given. To answer prompts from a pipe, pass\n")
+		fmt.Fprintf(os.Stderr, "-non-interactive=false.\n")
+	}
+
+	fs.Parse(args)
+
+	// --- Presets ---
+	presets, err := loadPresetStore(*presetFile)
+	if err != nil {
+		log.Fatalf("Error loading presets: %v", err)
+	}
+	if *presetName != "" {
+		values, ok := presets.Presets[*presetName]
+		if !ok {
+			log.Fatalf("Error: unknown preset %q (available: %v)", *presetName, presets.names())
+		}
+		if err := applyPreset(fs, values); err != nil {
+			log.Fatalf("Error applying preset %q: %v", *presetName, err)
+		}
+		fmt.Printf("Using preset %q from %s\n", *presetName, *presetFile)
+	}
+	// The configuration file only fills in what flags and the preset left unset.
+	cfg.apply(fs)
+	if err := checkFlags(fs); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	// --- Configuration Gathering & Validation ---
+	config := ca.Config{
+		ValidityDays: *validityDays,
+		KeyType:      *keyType,
+		KeyBitSize:   *keyBitSize,
+		SerialBits:   *serialBits,
+		SKIDMethod:   *skidMethod,
+		Organization: *organization,
+		CommonName:   *commonName,
+	}
+
+	if config.Subject, err = subject.attributes(); err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+	if config.NameConstraints, err = nameConstraints.constraints(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	// Interactive prompts if required flags are missing
+	prompter := NewPrompter(os.Stdin, os.Stdout, *lang)
+	prompter.NonInteractive = *nonInteractive
+	if *answersFile != "" {
+		if err := prompter.LoadAnswers(*answersFile); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	}
+
+	if config.CommonName == "" {
+		config.CommonName, err = prompter.Ask(Prompt{ID: promptCommonName, Validate: prompter.requiredValue})
+		if errors.Is(err, errNonInteractive) {
+			log.Fatalf("Error: %v.", err)
+		} else if err != nil {
+			log.Fatalf("Error: Common Name cannot be empty (%v).", err)
+		}
+	}
+
+	if config.Organization == "" {
+		// Organization is optional, but prompt for consistency
+		config.Organization, err = prompter.Ask(Prompt{ID: promptOrganization})
+		if err != nil {
+			log.Fatalf("Error reading Organization: %v", err)
+		}
+	}
+
+	// Validate Key Type
+	if !ca.IsValidKeyType(config.KeyType) {
+		log.Fatalf("Error: unsupported -key-type %q. Supported: %s.", config.KeyType, strings.Join(ca.SupportedKeyTypes, ", "))
+	}
+
+	// Validate Key Bit Size (RSA only)
+	if config.KeyType == ca.KeyTypeRSA && config.KeyBitSize != 2048 && config.KeyBitSize != 4096 {
+		fmt.Printf("Warning: Recommended key sizes are 2048 or 4096. Using %d bits.\n", config.KeyBitSize)
+		// Allow other sizes but warn
+		if config.KeyBitSize < 2048 {
+			fmt.Println("Warning: Key size less than 2048 bits is considered insecure.")
+		}
+	}
+
+	// Validate SKID Method
+	if config.SKIDMethod != ca.SKIDMethodSHA1 && config.SKIDMethod != ca.SKIDMethodSHA256 {
+		log.Fatalf("Error: -skid-method must be %q or %q. Got %q.", ca.SKIDMethodSHA1, ca.SKIDMethodSHA256, config.SKIDMethod)
+	}
+
+	if config.Rand, config.Clock, err = reproducible.apply(config.KeyType); err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+
+	// Resolve absolute validity window
+	if *notBefore != "" {
+		t, err := parseTimestamp(*notBefore)
+		if err != nil {
+			log.Fatalf("Error: invalid -not-before: %v", err)
+		}
+		config.NotBefore = t
+	}
+	if *notAfter != "" {
+		if isFlagSet(fs, "days") {
+			log.Fatal("Error: -days and -not-after are mutually exclusive.")
+		}
+		t, err := parseTimestamp(*notAfter)
+		if err != nil {
+			log.Fatalf("Error: invalid -not-after: %v", err)
+		}
+		config.NotAfter = t
+	}
+	if !config.NotAfter.IsZero() {
+		start := config.NotBefore
+		if start.IsZero() {
+			start = config.Clock.Now().UTC()
+		}
+		if !config.NotAfter.After(start) {
+			log.Fatalf("Error: -not-after (%s) must be later than the start of validity (%s).",
+				config.NotAfter.Format(time.RFC3339), start.Format(time.RFC3339))
+		}
+	}
+
+	config.RequireExplicitPolicy = optionalSkipCerts(*requireExplicitPolicy)
+	config.InhibitPolicyMapping = optionalSkipCerts(*inhibitPolicyMapping)
+	config.InhibitAnyPolicy = optionalSkipCerts(*inhibitAnyPolicy)
+	config.PathLen = pathLen
+
+	// Construct output paths
+	certOutputFile := filepath.Join(*outputDir, *certFileName)
+	keyOutputFile := filepath.Join(*outputDir, *keyFileName)
+
+	// Resolve output permissions
+	perms := DefaultOutputPermissions()
+	for _, m := range []struct {
+		name  string
+		value string
+		mode  *os.FileMode
+	}{
+		{"cert-mode", *certMode, &perms.CertMode},
+		{"key-mode", *keyMode, &perms.KeyMode},
+		{"dir-mode", *dirMode, &perms.DirMode},
+	} {
+		mode, err := parseFileMode(m.value)
+		if err != nil {
+			log.Fatalf("Error: invalid -%s: %v", m.name, err)
+		}
+		*m.mode = mode
+	}
+	if perms.KeyMode&0077 != 0 {
+		fmt.Printf("Warning: -key-mode %04o makes the private key readable by other users.\n", perms.KeyMode)
+	}
+	if perms.UID, err = lookupOwner(*owner); err != nil {
+		log.Fatalf("Error: invalid -owner: %v", err)
+	}
+	if perms.GID, err = lookupGroup(*group); err != nil {
+		log.Fatalf("Error: invalid -group: %v", err)
+	}
+
+	passphrase, err := keyPassphrase.read()
+	if err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+
+	// Ensure output directory exists
+	if err := prepareOutputDir(*outputDir, perms, *strictPerms); err != nil {
+		log.Fatalf("Error preparing output directory: %v", err)
+	}
+
+	// --- Generation ---
+	fmt.Println("\nGenerating Root CA...")
+	fmt.Printf("  Common Name: %s\n", config.CommonName)
+	if config.Organization != "" {
+		fmt.Printf("  Organization: %s\n", config.Organization)
+	}
+	if attrs := describeSubjectAttributes(config.Subject); attrs != "" {
+		fmt.Printf("  Subject Attributes: %s\n", attrs)
+	}
+	if config.NotBefore.IsZero() && config.NotAfter.IsZero() {
+		fmt.Printf("  Validity: %d days\n", config.ValidityDays)
+	} else {
+		fmt.Printf("  Not Before: %s\n", formatOptionalTime(config.NotBefore, "now"))
+		fmt.Printf("  Not After: %s\n", formatOptionalTime(config.NotAfter, fmt.Sprintf("+%d days", config.ValidityDays)))
+	}
+	fmt.Printf("  Key: %s\n", ca.DescribeKeyType(config.KeyType, config.KeyBitSize))
+	fmt.Printf("  Path Length: %s\n", describePathLen(*config.PathLen))
+	if config.RequireExplicitPolicy != nil {
+		fmt.Printf("  Require Explicit Policy: %d\n", *config.RequireExplicitPolicy)
+	}
+	if config.InhibitPolicyMapping != nil {
+		fmt.Printf("  Inhibit Policy Mapping: %d\n", *config.InhibitPolicyMapping)
+	}
+	if config.InhibitAnyPolicy != nil {
+		fmt.Printf("  Inhibit Any Policy: %d\n", *config.InhibitAnyPolicy)
+	}
+	if constraints := describeNameConstraints(config.NameConstraints); constraints != "" {
+		fmt.Printf("  Name Constraints: %s\n", constraints)
+	}
+	fmt.Printf("  Output Cert: %s\n", certOutputFile)
+	fmt.Printf("  Output Key: %s\n", keyOutputFile)
+	if passphrase != nil {
+		fmt.Println("  Key Encryption: scrypt + AES-256-CBC")
+	}
+
+	fmt.Printf("  Generating %s private key and signing the certificate...\n", ca.DescribeKeyType(config.KeyType, config.KeyBitSize))
+	root, err := ca.NewRootCA(config)
+	if err != nil {
+		log.Fatalf("Error generating CA: %v", err)
+	}
+	fmt.Println("CA certificate and private key generated successfully.")
+
+	// --- Export ---
+	fmt.Println("\nExporting to PEM format...")
+	err = ExportToPEM(root.Certificate.Raw, root.Key, certOutputFile, keyOutputFile, perms, passphrase)
+	if err != nil {
+		log.Fatalf("Error exporting files: %v", err)
+	}
+
+	// Remember this run under -save-preset so it can be repeated with -preset.
+	// Failing to save is not fatal: the CA itself has already been written.
+	if *savePresetName != "" {
+		presets.Presets[*savePresetName] = capturePreset(fs, map[string]string{
+			"cn":  config.CommonName,
+			"org": config.Organization,
+		})
+		if err := presets.save(*presetFile); err != nil {
+			fmt.Printf("Warning: could not save presets: %v\n", err)
+		} else {
+			fmt.Printf("Saved preset %q to %s\n", *savePresetName, *presetFile)
+		}
+	}
+
+	fmt.Printf("\nSuccess!\n")
+	fmt.Printf("  CA Certificate saved to: %s\n", certOutputFile)
+	fmt.Printf("  CA Private Key saved to: %s (Keep this file secure!)\n", keyOutputFile)
+}
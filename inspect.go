@@ -0,0 +1,316 @@
+// inspect.go
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prtk1729/certA/pkg/ca"
+)
+
+// keyUsageNames lists the key usage bits in the order of RFC 5280.
+var keyUsageNames = []struct {
+	usage x509.KeyUsage
+	name  string
+}{
+	{x509.KeyUsageDigitalSignature, "Digital Signature"},
+	{x509.KeyUsageContentCommitment, "Content Commitment"},
+	{x509.KeyUsageKeyEncipherment, "Key Encipherment"},
+	{x509.KeyUsageDataEncipherment, "Data Encipherment"},
+	{x509.KeyUsageKeyAgreement, "Key Agreement"},
+	{x509.KeyUsageCertSign, "Certificate Sign"},
+	{x509.KeyUsageCRLSign, "CRL Sign"},
+	{x509.KeyUsageEncipherOnly, "Encipher Only"},
+	{x509.KeyUsageDecipherOnly, "Decipher Only"},
+}
+
+// extKeyUsageDisplayNames maps extended key usages to display names.
+var extKeyUsageDisplayNames = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageAny:             "Any",
+	x509.ExtKeyUsageServerAuth:      "TLS Web Server Authentication",
+	x509.ExtKeyUsageClientAuth:      "TLS Web Client Authentication",
+	x509.ExtKeyUsageCodeSigning:     "Code Signing",
+	x509.ExtKeyUsageEmailProtection: "E-mail Protection",
+	x509.ExtKeyUsageTimeStamping:    "Time Stamping",
+	x509.ExtKeyUsageOCSPSigning:     "OCSP Signing",
+}
+
+// certInfo is the inspect report for one certificate; it is also the -json
+// output format.
+type certInfo struct {
+	Subject            string    `json:"subject"`
+	Issuer             string    `json:"issuer"`
+	Serial             string    `json:"serial,omitempty"` // Empty for a simulated certificate
+	Version            int       `json:"version"`
+	NotBefore          time.Time `json:"not_before"`
+	NotAfter           time.Time `json:"not_after"`
+	SelfSigned         bool      `json:"self_signed"`
+	IsCA               bool      `json:"is_ca"`
+	MaxPathLen         *int      `json:"max_path_len,omitempty"` // Set only for CAs with a path length constraint
+	KeyUsage           []string  `json:"key_usage,omitempty"`
+	ExtKeyUsage        []string  `json:"ext_key_usage,omitempty"`
+	DNSNames           []string  `json:"dns_names,omitempty"`
+	IPAddresses        []string  `json:"ip_addresses,omitempty"`
+	EmailAddresses     []string  `json:"email_addresses,omitempty"`
+	URIs               []string  `json:"uris,omitempty"`
+	NameConstraints    string    `json:"name_constraints,omitempty"`
+	PublicKey          string    `json:"public_key"`
+	SignatureAlgorithm string    `json:"signature_algorithm"`
+	SubjectKeyID       string    `json:"subject_key_id,omitempty"`
+	AuthorityKeyID     string    `json:"authority_key_id,omitempty"`
+	OCSPServers        []string  `json:"ocsp_servers,omitempty"`
+	CRLDistribution    []string  `json:"crl_distribution_points,omitempty"`
+	SHA1Fingerprint    string    `json:"sha1_fingerprint,omitempty"` // Fingerprints are empty for a simulated certificate
+	SHA256Fingerprint  string    `json:"sha256_fingerprint,omitempty"`
+	SPKIPin            string    `json:"spki_sha256,omitempty"`
+}
+
+// runInspect implements the inspect command: it prints the contents of PEM
+// or DER certificates, so output can be checked without switching to openssl.
+func runInspect(args []string) {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	jsonOutput := fs.Bool("json", false, "Print the certificates as a JSON array")
+	var at clockFlags
+	at.register(fs, "Report remaining validity as of this RFC 3339 timestamp instead of now")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s inspect [options] <cert.pem|cert.der>...\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Prints the subject, issuer, validity, usages, SANs, fingerprints and key of each\n")
+		fmt.Fprintf(os.Stderr, "certificate in the given PEM or DER files.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	clock, err := at.clock()
+	if err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+
+	var infos []certInfo
+	for _, path := range fs.Args() {
+		certs, err := readCertificateFile(path)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		for _, cert := range certs {
+			info, err := describeCertificate(cert)
+			if err != nil {
+				log.Fatalf("Error inspecting certificate in %q: %v", path, err)
+			}
+			infos = append(infos, info)
+		}
+	}
+
+	if *jsonOutput {
+		out, _ := json.MarshalIndent(infos, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+	for i, info := range infos {
+		if i > 0 {
+			fmt.Println()
+		}
+		info.print(clock.Now())
+	}
+}
+
+// readCertificateFile reads every certificate in a PEM file, or the
+// certificates in a DER file if it contains no PEM blocks.
+func readCertificateFile(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q: %w", path, err)
+	}
+	if block, _ := pem.Decode(data); block != nil {
+		return ca.LoadCertificates(path)
+	}
+	certs, err := x509.ParseCertificates(data)
+	if err != nil {
+		return nil, fmt.Errorf("%q is neither PEM nor a DER certificate: %w", path, err)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%w in %q", ca.ErrNoCertificates, path)
+	}
+	return certs, nil
+}
+
+// describeCertificate builds the inspect report for cert.
+func describeCertificate(cert *x509.Certificate) (certInfo, error) {
+	pin, err := ca.SPKIPin(cert.PublicKey)
+	if err != nil {
+		return certInfo{}, err
+	}
+	info := certInfo{
+		Subject:            cert.Subject.String(),
+		Issuer:             cert.Issuer.String(),
+		Version:            cert.Version,
+		NotBefore:          cert.NotBefore.UTC(),
+		NotAfter:           cert.NotAfter.UTC(),
+		SelfSigned:         isSelfSigned(cert),
+		IsCA:               cert.IsCA,
+		DNSNames:           cert.DNSNames,
+		EmailAddresses:     cert.EmailAddresses,
+		PublicKey:          describePublicKey(cert.PublicKey),
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+		SubjectKeyID:       colonHex(cert.SubjectKeyId),
+		AuthorityKeyID:     colonHex(cert.AuthorityKeyId),
+		OCSPServers:        cert.OCSPServer,
+		CRLDistribution:    cert.CRLDistributionPoints,
+		SPKIPin:            pin,
+	}
+	if cert.IsCA {
+		info.NameConstraints = describeNameConstraints(ca.CertificateNameConstraints(cert))
+	}
+	if cert.SerialNumber != nil {
+		info.Serial = fmt.Sprintf("%X", cert.SerialNumber)
+	}
+	if len(cert.Raw) > 0 {
+		sha1Sum := sha1.Sum(cert.Raw)
+		sha256Sum := sha256.Sum256(cert.Raw)
+		info.SHA1Fingerprint = colonHex(sha1Sum[:])
+		info.SHA256Fingerprint = colonHex(sha256Sum[:])
+	}
+	if cert.IsCA && (cert.MaxPathLen > 0 || cert.MaxPathLenZero) {
+		info.MaxPathLen = &cert.MaxPathLen
+	}
+	for _, ku := range keyUsageNames {
+		if cert.KeyUsage&ku.usage != 0 {
+			info.KeyUsage = append(info.KeyUsage, ku.name)
+		}
+	}
+	for _, eku := range cert.ExtKeyUsage {
+		name, ok := extKeyUsageDisplayNames[eku]
+		if !ok {
+			name = fmt.Sprintf("Unknown (%d)", eku)
+		}
+		info.ExtKeyUsage = append(info.ExtKeyUsage, name)
+	}
+	for _, oid := range cert.UnknownExtKeyUsage {
+		info.ExtKeyUsage = append(info.ExtKeyUsage, oid.String())
+	}
+	for _, ip := range cert.IPAddresses {
+		info.IPAddresses = append(info.IPAddresses, ip.String())
+	}
+	for _, u := range cert.URIs {
+		info.URIs = append(info.URIs, u.String())
+	}
+	return info, nil
+}
+
+// print writes the human-readable report, with the remaining validity
+// computed relative to now.
+func (info certInfo) print(now time.Time) {
+	fmt.Printf("Subject: %s\n", info.Subject)
+	fmt.Printf("Issuer: %s\n", info.Issuer)
+	if info.SelfSigned {
+		fmt.Println("  (self-signed)")
+	}
+	fmt.Printf("Serial: %s\n", info.Serial)
+	fmt.Printf("Version: %d\n", info.Version)
+	fmt.Printf("Validity:\n")
+	fmt.Printf("  Not Before: %s\n", info.NotBefore.Format(time.RFC3339))
+	fmt.Printf("  Not After:  %s (%s)\n", info.NotAfter.Format(time.RFC3339), describeRemaining(info.NotBefore, info.NotAfter, now))
+	if info.IsCA {
+		pathLen := "unlimited"
+		if info.MaxPathLen != nil {
+			pathLen = fmt.Sprintf("%d", *info.MaxPathLen)
+		}
+		fmt.Printf("CA: yes (path length: %s)\n", pathLen)
+	} else {
+		fmt.Println("CA: no")
+	}
+	if info.NameConstraints != "" {
+		fmt.Printf("Name Constraints: %s\n", info.NameConstraints)
+	}
+	printList("Key Usage", info.KeyUsage)
+	printList("Extended Key Usage", info.ExtKeyUsage)
+	if len(info.DNSNames)+len(info.IPAddresses)+len(info.EmailAddresses)+len(info.URIs) > 0 {
+		fmt.Println("Subject Alternative Names:")
+		printList("  DNS", info.DNSNames)
+		printList("  IP", info.IPAddresses)
+		printList("  Email", info.EmailAddresses)
+		printList("  URI", info.URIs)
+	}
+	fmt.Printf("Public Key: %s\n", info.PublicKey)
+	fmt.Printf("Signature Algorithm: %s\n", info.SignatureAlgorithm)
+	if info.SubjectKeyID != "" {
+		fmt.Printf("Subject Key ID: %s\n", info.SubjectKeyID)
+	}
+	if info.AuthorityKeyID != "" {
+		fmt.Printf("Authority Key ID: %s\n", info.AuthorityKeyID)
+	}
+	printList("OCSP", info.OCSPServers)
+	printList("CRL Distribution Points", info.CRLDistribution)
+	fmt.Println("Fingerprints:")
+	fmt.Printf("  SHA-1:   %s\n", info.SHA1Fingerprint)
+	fmt.Printf("  SHA-256: %s\n", info.SHA256Fingerprint)
+	fmt.Printf("  SPKI SHA-256 (pin): sha256/%s\n", info.SPKIPin)
+}
+
+// printList prints a labelled comma-separated list, or nothing if it is empty.
+func printList(label string, values []string) {
+	if len(values) > 0 {
+		fmt.Printf("%s: %s\n", label, strings.Join(values, ", "))
+	}
+}
+
+// describeRemaining renders how the validity window relates to now.
+func describeRemaining(notBefore, notAfter, now time.Time) string {
+	switch {
+	case now.Before(notBefore):
+		return fmt.Sprintf("not yet valid, starts in %d days", daysBetween(now, notBefore))
+	case now.After(notAfter):
+		return fmt.Sprintf("EXPIRED %d days ago", daysBetween(notAfter, now))
+	default:
+		return fmt.Sprintf("expires in %d days", daysBetween(now, notAfter))
+	}
+}
+
+// daysBetween returns the number of whole days from a to b.
+func daysBetween(a, b time.Time) int {
+	return int(b.Sub(a) / (24 * time.Hour))
+}
+
+// describePublicKey renders a public key's algorithm and size.
+func describePublicKey(pub any) string {
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d bits", key.N.BitLen())
+	case *ecdsa.PublicKey:
+		return fmt.Sprintf("ECDSA %s", key.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return "Ed25519"
+	default:
+		return fmt.Sprintf("%T", pub)
+	}
+}
+
+// colonHex renders bytes as colon-separated upper-case hex, as openssl does.
+func colonHex(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+	parts := make([]string, len(data))
+	for i, b := range data {
+		parts[i] = strings.ToUpper(hex.EncodeToString([]byte{b}))
+	}
+	return strings.Join(parts, ":")
+}
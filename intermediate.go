@@ -0,0 +1,179 @@
+// intermediate.go
+package main
+
+import (
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/prtk1729/certA/pkg/ca"
+)
+
+const (
+	defaultIntermediateValidityDays = 365 * 5 // Default validity: 5 years
+	defaultIntermediateCertFileName = "intermediate.crt"
+	defaultIntermediateKeyFileName  = "intermediate.key"
+	defaultChainFileName            = "intermediate-chain.crt"
+)
+
+// runIntermediate implements the intermediate command: it signs a new
+// intermediate CA certificate with an existing CA loaded from disk.
+func runIntermediate(args []string) {
+	fs := flag.NewFlagSet("intermediate", flag.ExitOnError)
+	caCertFile := fs.String("ca", defaultCertFileName, "Issuing CA certificate PEM file")
+	caKeyFile := fs.String("ca-key", defaultKeyFileName, "Issuing CA private key PEM file")
+	commonName := fs.String("cn", "", "Required: Common Name (CN) for the intermediate CA")
+	organization := fs.String("org", "", "Optional: Organization (O) for the intermediate CA")
+	var subject subjectFlags
+	subject.register(fs, "Repeatable: ")
+	validityDays := fs.Int("days", defaultIntermediateValidityDays, "Validity period in days")
+	pathLen := fs.Int("path-len", 0, "Maximum number of CAs allowed below this one (0: may only issue leaves, -1: unlimited)")
+	keyType := fs.String("key-type", defaultKeyType, "Key algorithm: "+strings.Join(ca.SupportedKeyTypes, ", "))
+	keyBitSize := fs.Int("bits", defaultKeyBitSize, "RSA key size in bits; ignored for other key types")
+	var nameConstraints nameConstraintFlags
+	nameConstraints.register(fs)
+	var serials serialFlags
+	serials.register(fs)
+	skidMethod := fs.String("skid-method", ca.SKIDMethodSHA1, "Subject key identifier method: 'sha1' or 'sha256'")
+	outputDir := fs.String("out", defaultOutputDir, "Directory to save the certificate, key and chain files")
+	certFileName := fs.String("cert-name", defaultIntermediateCertFileName, "Filename for the intermediate certificate PEM file")
+	keyFileName := fs.String("key-name", defaultIntermediateKeyFileName, "Filename for the intermediate private key PEM file")
+	chainFileName := fs.String("chain-name", defaultChainFileName, "Filename for the intermediate + issuer chain PEM file")
+	var caPassphrase passphraseFlags
+	caPassphrase.register(fs, "ca-", "decrypt the issuing CA private key with")
+	var keyPassphrase passphraseFlags
+	keyPassphrase.register(fs, "", "encrypt the intermediate CA private key with")
+	var db certDBFlags
+	db.register(fs)
+	var reproducible reproducibleFlags
+	reproducible.register(fs)
+
+	var cfg configFlags
+	cfg.register(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s intermediate -cn <name> [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Signs a new intermediate CA certificate with an existing CA.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nExample:\n")
+		fmt.Fprintf(os.Stderr, "  %s intermediate -ca ./my_ca/ca.crt -ca-key ./my_ca/ca.key -cn \"My Issuing CA\" -out ./my_ca\n", os.Args[0])
+	}
+	fs.Parse(args)
+	cfg.apply(fs)
+	if err := checkFlags(fs); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	if *commonName == "" {
+		fs.Usage()
+		log.Fatal("Error: -cn is required.")
+	}
+	if !ca.IsValidKeyType(*keyType) {
+		log.Fatalf("Error: unsupported -key-type %q. Supported: %s.", *keyType, strings.Join(ca.SupportedKeyTypes, ", "))
+	}
+	if err := serials.check(); err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+
+	config := ca.Config{
+		CommonName:   *commonName,
+		Organization: *organization,
+		ValidityDays: *validityDays,
+		KeyType:      *keyType,
+		KeyBitSize:   *keyBitSize,
+		SerialBits:   serials.bits,
+		SKIDMethod:   *skidMethod,
+	}
+	certOutputFile := filepath.Join(*outputDir, *certFileName)
+	keyOutputFile := filepath.Join(*outputDir, *keyFileName)
+	chainOutputFile := filepath.Join(*outputDir, *chainFileName)
+	passphrase, err := keyPassphrase.read()
+	if err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+	if config.Subject, err = subject.attributes(); err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+	if config.NameConstraints, err = nameConstraints.constraints(); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	if config.Rand, config.Clock, err = reproducible.apply(config.KeyType); err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+
+	issuer, err := ca.LoadCA(*caCertFile, *caKeyFile, caPassphrase.source())
+	if err != nil {
+		log.Fatalf("Error loading issuing CA: %v", err)
+	}
+	if err := checkReproducibleSigner(config.Rand, issuer.Key); err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+
+	perms := DefaultOutputPermissions()
+	if err := prepareOutputDir(*outputDir, perms, false); err != nil {
+		log.Fatalf("Error preparing output directory: %v", err)
+	}
+
+	fmt.Println("Generating Intermediate CA...")
+	fmt.Printf("  Common Name: %s\n", config.CommonName)
+	if config.Organization != "" {
+		fmt.Printf("  Organization: %s\n", config.Organization)
+	}
+	if attrs := describeSubjectAttributes(config.Subject); attrs != "" {
+		fmt.Printf("  Subject Attributes: %s\n", attrs)
+	}
+	fmt.Printf("  Issuer: %s\n", issuer.Certificate.Subject)
+	fmt.Printf("  Validity: %d days\n", config.ValidityDays)
+	fmt.Printf("  Path Length: %s\n", describePathLen(*pathLen))
+	if constraints := describeNameConstraints(config.NameConstraints); constraints != "" {
+		fmt.Printf("  Name Constraints: %s\n", constraints)
+	}
+	fmt.Printf("  Key: %s\n", ca.DescribeKeyType(config.KeyType, config.KeyBitSize))
+
+	var seq uint64
+	if config.SerialNumber, seq, err = serials.allocate(db.resolve(*caCertFile), config.Rand); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	intermediate, err := issuer.NewIntermediate(config, *pathLen)
+	if err != nil {
+		log.Fatalf("Error generating intermediate CA: %v", err)
+	}
+	if err := recordIssued(db.resolve(*caCertFile), intermediate.Certificate, certOutputFile, seq); err != nil {
+		log.Fatalf("Error recording certificate: %v", err)
+	}
+
+	fmt.Println("\nExporting to PEM format...")
+	if err := ExportToPEM(intermediate.Certificate.Raw, intermediate.Key, certOutputFile, keyOutputFile, perms, passphrase); err != nil {
+		log.Fatalf("Error exporting files: %v", err)
+	}
+	chainPEM := append(
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: intermediate.Certificate.Raw}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: issuer.Certificate.Raw})...,
+	)
+	if err := writeFileWithPermissions(chainOutputFile, chainPEM, perms.CertMode, perms); err != nil {
+		log.Fatalf("Error writing chain file: %v", err)
+	}
+
+	fmt.Printf("\nSuccess!\n")
+	fmt.Printf("  Intermediate Certificate saved to: %s\n", certOutputFile)
+	fmt.Printf("  Intermediate Private Key saved to: %s (Keep this file secure!)\n", keyOutputFile)
+	fmt.Printf("  Chain (intermediate + issuer) saved to: %s\n", chainOutputFile)
+}
+
+// describePathLen renders a path length constraint for display.
+func describePathLen(pathLen int) string {
+	switch {
+	case pathLen < 0:
+		return "unlimited"
+	case pathLen == 0:
+		return "0 (may only issue leaf certificates)"
+	default:
+		return fmt.Sprintf("%d", pathLen)
+	}
+}
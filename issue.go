@@ -0,0 +1,339 @@
+// issue.go
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/prtk1729/certA/pkg/ca"
+)
+
+const (
+	defaultLeafValidityDays = 365
+	defaultLeafKeyBitSize   = 2048 // Leaves are short-lived; 2048 keeps handshakes fast
+	defaultLeafCertFileName = "server.crt"
+	defaultLeafKeyFileName  = "server.key"
+	defaultFullChainName    = "server-fullchain.crt"
+)
+
+// runIssue implements the issue command: it generates a key pair and a TLS
+// server certificate signed by an existing CA.
+func runIssue(args []string) {
+	fs := flag.NewFlagSet("issue", flag.ExitOnError)
+	caCertFile := fs.String("ca", defaultCertFileName, "Issuing CA certificate PEM file")
+	caKeyFile := fs.String("ca-key", defaultKeyFileName, "Issuing CA private key PEM file")
+	commonName := fs.String("cn", "", "Required: Common Name (CN) for the certificate (e.g., 'app.internal')")
+	organization := fs.String("org", "", "Optional: Organization (O) for the certificate")
+	var subject subjectFlags
+	subject.register(fs, "Repeatable: ")
+	var sans sanFlags
+	sans.register(fs, func(kind string) string {
+		return "Repeatable: " + kind + " to include as a SAN (the CN is used as a DNS name if no SANs are given)"
+	})
+	validityDays := fs.Int("days", defaultLeafValidityDays, "Validity period in days")
+	var profile profileFlags
+	profile.register(fs)
+	keyType := fs.String("key-type", defaultKeyType, "Key algorithm: "+strings.Join(ca.SupportedKeyTypes, ", "))
+	keyBitSize := fs.Int("bits", defaultLeafKeyBitSize, "RSA key size in bits; ignored for other key types")
+	var serials serialFlags
+	serials.register(fs)
+	skidMethod := fs.String("skid-method", ca.SKIDMethodSHA1, "Subject key identifier method: 'sha1' or 'sha256'")
+	outputDir := fs.String("out", defaultOutputDir, "Directory to save the certificate and key files")
+	certFileName := fs.String("cert-name", defaultLeafCertFileName, "Filename for the certificate PEM file")
+	keyFileName := fs.String("key-name", defaultLeafKeyFileName, "Filename for the private key PEM file")
+	chainFileName := fs.String("chain-name", defaultFullChainName, "Filename for the certificate + intermediate chain (written when the issuer is not a root)")
+	reuseKeyFile := fs.String("key-file", "", "Optional: reuse this existing private key instead of generating one, keeping the SPKI stable for pinned clients")
+	var reuseKeyPassphrase passphraseFlags
+	reuseKeyPassphrase.register(fs, "key-file-", "decrypt the -key-file private key with")
+	var pins pinSetFlags
+	pins.register(fs)
+	var denylist denylistFlags
+	denylist.register(fs)
+	var db certDBFlags
+	db.register(fs)
+	var sanPolicy sanPolicyFlags
+	sanPolicy.register(fs)
+	var caPassphrase passphraseFlags
+	caPassphrase.register(fs, "ca-", "decrypt the issuing CA private key with")
+	var reproducible reproducibleFlags
+	reproducible.register(fs)
+	simulate := fs.Bool("simulate", false, "Run every check and print the certificate that would be issued as JSON, without signing it, assigning a serial or writing files (-ca-key is not read)")
+
+	var cfg configFlags
+	cfg.register(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s issue -cn <name> [-dns|-ip|-email|-uri <san>]... [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Issues a certificate signed by an existing CA: a TLS server certificate, or one\n")
+		fmt.Fprintf(os.Stderr, "shaped by -profile.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+		printProfiles(os.Stderr)
+		fmt.Fprintf(os.Stderr, "\nExample:\n")
+		fmt.Fprintf(os.Stderr, "  %s issue -ca ./my_ca/ca.crt -ca-key ./my_ca/ca.key -cn app.local -dns app.local -ip 127.0.0.1 -out ./certs\n", os.Args[0])
+	}
+	fs.Parse(args)
+	cfg.apply(fs)
+	if err := checkFlags(fs); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	if *commonName == "" {
+		fs.Usage()
+		log.Fatal("Error: -cn is required.")
+	}
+	if !ca.IsValidKeyType(*keyType) {
+		log.Fatalf("Error: unsupported -key-type %q. Supported: %s.", *keyType, strings.Join(ca.SupportedKeyTypes, ", "))
+	}
+	if err := serials.check(); err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+	selected, err := profile.profile()
+	if err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+
+	opts := ca.IssueOptions{
+		CommonName:   *commonName,
+		Organization: *organization,
+		ValidityDays: *validityDays,
+		KeyType:      *keyType,
+		KeyBitSize:   *keyBitSize,
+		SerialBits:   serials.bits,
+		SKIDMethod:   *skidMethod,
+		ExtKeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		Profile:      selected,
+	}
+	certOutputFile := filepath.Join(*outputDir, *certFileName)
+	keyOutputFile := filepath.Join(*outputDir, *keyFileName)
+	if err := sans.apply(&opts); err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+	if opts.Subject, err = subject.attributes(); err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+	// Modern clients ignore the CN, so a certificate without SANs is useless
+	// for TLS. Fall back to using the CN as the only SAN, unless the profile
+	// does not take names of that kind (e.g. email, where the CN is a person).
+	if !opts.HasSANs() {
+		if ip := net.ParseIP(opts.CommonName); ip != nil {
+			if selected == nil || selected.Allows(ca.SANTypeIP) {
+				opts.IPAddresses = []net.IP{ip}
+			}
+		} else if selected == nil || selected.Allows(ca.SANTypeDNS) {
+			opts.DNSNames = []string{opts.CommonName}
+		}
+	}
+	if opts.SANPolicy, err = sanPolicy.policy(); err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+	// Issue checks this again, but failing here avoids loading the CA key.
+	if err := opts.SANPolicy.Validate(opts); err != nil {
+		log.Fatalf("Error: rejected by SAN policy:\n%v", err)
+	}
+	if err := opts.Profile.Check(opts); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	if opts.Rand, opts.Clock, err = reproducible.apply(opts.KeyType); err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+	if opts.Denylist, err = denylist.load(*caCertFile); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	var issuer *ca.CA
+	if *simulate {
+		caCerts, err := ca.LoadCertificates(*caCertFile)
+		if err != nil {
+			log.Fatalf("Error loading CA certificate: %v", err)
+		}
+		issuer = &ca.CA{Certificate: caCerts[0]}
+	} else {
+		if issuer, err = ca.LoadCA(*caCertFile, *caKeyFile, caPassphrase.source()); err != nil {
+			log.Fatalf("Error loading issuing CA: %v", err)
+		}
+		if err := checkReproducibleSigner(opts.Rand, issuer.Key); err != nil {
+			log.Fatalf("Error: %v.", err)
+		}
+	}
+
+	keySource := keyOutputFile
+	if *reuseKeyFile != "" {
+		if opts.Key, err = ca.LoadPrivateKey(*reuseKeyFile, reuseKeyPassphrase.source()); err != nil {
+			log.Fatalf("Error loading -key-file: %v", err)
+		}
+		keySource = *reuseKeyFile
+	} else if pins.path != "" {
+		// The key's pin must be checked before anything is signed.
+		random := opts.Rand
+		if random == nil {
+			random = rand.Reader
+		}
+		if opts.Key, err = ca.GenerateKey(random, opts.KeyType, opts.KeyBitSize); err != nil {
+			log.Fatalf("Error generating private key: %v", err)
+		}
+	}
+	var pub crypto.PublicKey // Only needed, and always set, with -pin-set
+	if opts.Key != nil {
+		pub = opts.Key.Public()
+	}
+	pinned, pin, err := pins.check(pub, keySource)
+	if err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+	if *simulate {
+		printIssueSimulation(issuer, opts)
+		return
+	}
+
+	perms := DefaultOutputPermissions()
+	if err := prepareOutputDir(*outputDir, perms, false); err != nil {
+		log.Fatalf("Error preparing output directory: %v", err)
+	}
+
+	if selected != nil {
+		fmt.Println("Issuing Certificate...")
+		fmt.Printf("  Profile: %s\n", selected.Name)
+	} else {
+		fmt.Println("Issuing Server Certificate...")
+	}
+	fmt.Printf("  Common Name: %s\n", opts.CommonName)
+	if attrs := describeSubjectAttributes(opts.Subject); attrs != "" {
+		fmt.Printf("  Subject Attributes: %s\n", attrs)
+	}
+	if len(opts.DNSNames) > 0 {
+		fmt.Printf("  DNS Names: %s\n", strings.Join(opts.DNSNames, ", "))
+	}
+	if len(opts.IPAddresses) > 0 {
+		fmt.Printf("  IP Addresses: %s\n", joinIPs(opts.IPAddresses))
+	}
+	if len(opts.EmailAddresses) > 0 {
+		fmt.Printf("  Email Addresses: %s\n", strings.Join(opts.EmailAddresses, ", "))
+	}
+	if len(opts.URIs) > 0 {
+		fmt.Printf("  URIs: %s\n", joinURIs(opts.URIs))
+	}
+	fmt.Printf("  Issuer: %s\n", issuer.Certificate.Subject)
+	fmt.Printf("  Validity: %d days\n", opts.ValidityDays)
+	if *reuseKeyFile != "" {
+		fmt.Printf("  Key: reused from %s\n", *reuseKeyFile)
+	} else {
+		fmt.Printf("  Key: %s\n", ca.DescribeKeyType(opts.KeyType, opts.KeyBitSize))
+	}
+
+	var seq uint64
+	if opts.SerialNumber, seq, err = serials.allocate(db.resolve(*caCertFile), opts.Rand); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	cert, privateKey, err := issuer.Issue(opts)
+	if err != nil {
+		log.Fatalf("Error issuing certificate: %v", err)
+	}
+	if err := recordIssued(db.resolve(*caCertFile), cert, certOutputFile, seq); err != nil {
+		log.Fatalf("Error recording certificate: %v", err)
+	}
+
+	fmt.Println("\nExporting to PEM format...")
+	if *reuseKeyFile != "" {
+		// The reused key stays where it is; copying it (possibly decrypted)
+		// into the output directory would only spread it further.
+		certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+		if err := writeFileWithPermissions(certOutputFile, certPEM, perms.CertMode, perms); err != nil {
+			log.Fatalf("Error writing certificate: %v", err)
+		}
+	} else if err := ExportToPEM(cert.Raw, privateKey, certOutputFile, keyOutputFile, perms, nil); err != nil {
+		log.Fatalf("Error exporting files: %v", err)
+	}
+
+	fmt.Printf("\nSuccess!\n")
+	fmt.Printf("  Certificate saved to: %s\n", certOutputFile)
+	if *reuseKeyFile != "" {
+		fmt.Printf("  Private Key: %s (reused)\n", *reuseKeyFile)
+	} else {
+		fmt.Printf("  Private Key saved to: %s (Keep this file secure!)\n", keyOutputFile)
+	}
+
+	// Servers must send intermediates themselves; a root is already in the
+	// client's trust store, so there is nothing to bundle in that case.
+	if !issuer.IsRoot() {
+		chainOutputFile := filepath.Join(*outputDir, *chainFileName)
+		chainPEM := append(
+			pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}),
+			pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: issuer.Certificate.Raw})...,
+		)
+		if err := writeFileWithPermissions(chainOutputFile, chainPEM, perms.CertMode, perms); err != nil {
+			log.Fatalf("Error writing chain file: %v", err)
+		}
+		fmt.Printf("  Full Chain saved to: %s\n", chainOutputFile)
+	}
+
+	if pinned != nil {
+		if err := pins.record(pinned, pin, cert); err != nil {
+			log.Fatalf("Error updating pin set: %v", err)
+		}
+	}
+}
+
+// issueSimulation is the output of issue -simulate.
+type issueSimulation struct {
+	Simulated   bool     `json:"simulated"`
+	Certificate certInfo `json:"certificate"`
+	Notes       []string `json:"notes"`
+}
+
+// printIssueSimulation prints the certificate issuer would issue for opts as
+// JSON, or exits with the error issuance would fail with.
+func printIssueSimulation(issuer *ca.CA, opts ca.IssueOptions) {
+	cert, err := issuer.Simulate(opts)
+	if err != nil {
+		log.Fatalf("Error: issuance would fail: %v", err)
+	}
+	info, err := describeCertificate(cert)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	result := issueSimulation{
+		Simulated:   true,
+		Certificate: info,
+		Notes:       []string{"The serial number and fingerprints are only known once the certificate is signed."},
+	}
+	if opts.Key == nil {
+		// The placeholder key says nothing about the real one.
+		result.Certificate.SubjectKeyID = ""
+		result.Certificate.SPKIPin = ""
+		result.Notes = append(result.Notes, "The key pair is generated at issuance; public_key only describes its type.")
+	}
+	out, _ := json.MarshalIndent(result, "", "  ")
+	fmt.Println(string(out))
+}
+
+// joinIPs renders IP addresses as a comma-separated list.
+func joinIPs(ips []net.IP) string {
+	parts := make([]string, len(ips))
+	for i, ip := range ips {
+		parts[i] = ip.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// joinURIs renders URIs as a comma-separated list.
+func joinURIs(uris []*url.URL) string {
+	parts := make([]string, len(uris))
+	for i, u := range uris {
+		parts[i] = u.String()
+	}
+	return strings.Join(parts, ", ")
+}
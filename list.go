@@ -0,0 +1,123 @@
+// list.go
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// listStatuses are the accepted -status values.
+var listStatuses = []string{"all", statusValid, statusRevoked, statusExpired}
+
+// listedRecord is a database record as list -json reports it.
+type listedRecord struct {
+	*certRecord
+	Status string `json:"status"` // Shadows the stored status to report expiry
+}
+
+// listedRecords reports records with their status as of now.
+func listedRecords(records []*certRecord, now time.Time) []listedRecord {
+	out := make([]listedRecord, 0, len(records))
+	for _, record := range records {
+		out = append(out, listedRecord{record, record.status(now)})
+	}
+	return out
+}
+
+// runList implements the list command: it prints the certificates recorded
+// in a CA's certificate database.
+func runList(args []string) {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	caCertFile := fs.String("ca", defaultCertFileName, "Issuing CA certificate PEM file")
+	var db certDBFlags
+	db.register(fs)
+	status := fs.String("status", "all", "Only list certificates with this status: "+strings.Join(listStatuses, ", "))
+	expiring := fs.Duration("expiring", 0, "Optional: only list valid certificates expiring within this duration (e.g. 720h)")
+	jsonOutput := fs.Bool("json", false, "Print the records as a JSON array")
+	var at clockFlags
+	at.register(fs, "Evaluate expiry as of this RFC 3339 timestamp instead of now")
+
+	var cfg configFlags
+	cfg.register(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s list [-ca ca.crt] [-status valid|revoked|expired] [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Lists the certificates issued by a CA, as recorded in its certificate database.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	cfg.apply(fs)
+	if err := checkFlags(fs); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	valid := false
+	for _, s := range listStatuses {
+		valid = valid || s == *status
+	}
+	if !valid {
+		log.Fatalf("Error: unknown -status %q. Supported: %s.", *status, strings.Join(listStatuses, ", "))
+	}
+	clock, err := at.clock()
+	if err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+	now := clock.Now()
+
+	dbFile := db.resolve(*caCertFile)
+	certs, err := loadCertDB(dbFile)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	var records []*certRecord
+	for _, record := range certs.Certificates {
+		s := record.status(now)
+		if *status != "all" && s != *status {
+			continue
+		}
+		if *expiring > 0 && (s != statusValid || record.NotAfter.After(now.Add(*expiring))) {
+			continue
+		}
+		records = append(records, record)
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].NotAfter.Before(records[j].NotAfter)
+	})
+
+	if *jsonOutput {
+		data, _ := json.MarshalIndent(listedRecords(records, now), "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+
+	fmt.Printf("Certificate database %s (%d of %d certificates):\n", dbFile, len(records), len(certs.Certificates))
+	for _, record := range records {
+		notAfter := "unknown"
+		if !record.NotAfter.IsZero() {
+			notAfter = record.NotAfter.Format(time.RFC3339)
+		}
+		subject := record.Subject
+		if subject == "" {
+			subject = "(not recorded)"
+		}
+		fmt.Printf("\n  %s  %-7s  expires %s\n", record.Serial, record.status(now), notAfter)
+		fmt.Printf("    Subject: %s\n", subject)
+		if len(record.SANs) > 0 {
+			fmt.Printf("    SANs: %s\n", strings.Join(record.SANs, ", "))
+		}
+		if record.Status == statusRevoked {
+			fmt.Printf("    Revoked: %s (%s)\n", record.RevokedAt.Format(time.RFC3339), record.Reason)
+		}
+		if record.Path != "" {
+			fmt.Printf("    Path: %s\n", record.Path)
+		}
+	}
+}
@@ -2,228 +2,186 @@
 package main
 
 import (
-	"bufio"
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/x509"
-	"crypto/x509/pkix"
+	"crypto"
 	"encoding/pem"
 	"flag"
 	"fmt"
-	"log"
-	"math/big"
 	"os"
-	"path/filepath"
-	"strconv"
 	"strings"
 	"time"
+
+	"github.com/prtk1729/certA/pkg/ca"
 )
 
 const (
 	defaultValidityDays = 365 * 10 // Default validity: 10 years
 	defaultKeyBitSize   = 4096     // Default RSA key size (stronger default)
+	defaultKeyType      = ca.KeyTypeRSA
 	defaultCertFileName = "ca.crt"
 	defaultKeyFileName  = "ca.key"
 	defaultOutputDir    = "." // Default output directory: current directory
 )
 
-// CAConfig holds the configuration parameters for the root CA.
-type CAConfig struct {
-	CommonName     string
-	Organization   string
-	ValidityDays   int
-	KeyBitSize     int
-	CertOutputFile string
-	KeyOutputFile  string
+// command is one subcommand of the tool. Each command parses its own flags
+// with a dedicated flag.FlagSet, so flag names never collide across commands.
+type command struct {
+	name    string
+	args    string // Synopsis shown after the command name in the overview
+	summary string
+	run     func(args []string)
 }
 
-func main() {
-	// --- CLI Setup ---
-	fmt.Println("Minimal Go Certificate Authority Generator")
-	fmt.Println("----------------------------------------")
-
-	// Define flags
-	commonName := flag.String("cn", "", "Required: Common Name (CN) for the CA (e.g., 'My Corp Root CA')")
-	organization := flag.String("org", "", "Optional: Organization (O) for the CA (e.g., 'My Corp')")
-	validityDays := flag.Int("days", defaultValidityDays, "Validity period in days")
-	keyBitSize := flag.Int("bits", defaultKeyBitSize, "RSA key size in bits (e.g., 2048, 4096)")
-	outputDir := flag.String("out", defaultOutputDir, "Directory to save the certificate and key files")
-	certFileName := flag.String("cert-name", defaultCertFileName, "Filename for the CA certificate PEM file")
-	keyFileName := flag.String("key-name", defaultKeyFileName, "Filename for the CA private key PEM file")
-
-	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s [options]\n\n", os.Args[0])
-		fmt.Fprintf(os.Stderr, "Generates a self-signed root CA certificate and private key.\n\n")
-		fmt.Fprintf(os.Stderr, "Options:\n")
-		flag.PrintDefaults()
-		fmt.Fprintf(os.Stderr, "\nExample:\n")
-		fmt.Fprintf(os.Stderr, "  %s -cn=\"My Test CA\" -org=\"Test Org\" -days=730 -bits=4096 -out=./my_ca\n", os.Args[0])
-		fmt.Fprintf(os.Stderr, "\nIf required flags are omitted, you will be prompted interactively.\n")
-	}
-
-	flag.Parse()
-
-	// --- Configuration Gathering & Validation ---
-	config := CAConfig{
-		ValidityDays: *validityDays,
-		KeyBitSize:   *keyBitSize,
-		Organization: *organization,
-		CommonName:   *commonName,
-	}
-
-	// Interactive prompts if required flags are missing
-	reader := bufio.NewReader(os.Stdin)
-
-	if config.CommonName == "" {
-		config.CommonName = promptUser(reader, "Enter Common Name (CN) for the CA (e.g., 'My Dev Root CA'): ", "")
-		if config.CommonName == "" {
-			log.Fatal("Error: Common Name cannot be empty.")
-		}
+// commands lists the subcommands in the order they are documented. It is
+// populated in init because the help command refers back to it.
+var commands []command
+
+func init() {
+	commands = []command{
+		{"init", "-cn <name> [options]", "Create a self-signed root CA", runInit},
+		{"intermediate", "-cn <name> -ca ca.crt -ca-key ca.key [options]", "Create an intermediate CA signed by an existing CA", runIntermediate},
+		{"issue", "-cn <name> -ca ca.crt -ca-key ca.key [-dns|-ip|-email|-uri <san>]...", "Issue a TLS server certificate and key", runIssue},
+		{"sign-csr", "-csr server.csr -ca ca.crt -ca-key ca.key [options]", "Sign an externally generated CSR", runSignCSR},
+		{"list", "[-ca ca.crt] [-status valid|revoked|expired] [options]", "List the certificates recorded in a CA's database", runList},
+		{"revoke", "-ca ca.crt (-cert server.crt | -serial <hex>) [options]", "Record a certificate as revoked", runRevoke},
+		{"gen-crl", "-ca ca.crt -ca-key ca.key [options]", "Generate a signed CRL of revoked certificates", runGenCRL},
+		{"csr", "-cn <name> [-dns|-ip|-email|-uri <san>]... [options]", "Generate a private key and CSR", runCSR},
+		{"selfsign", "-csr req.csr -key req.key -out cert.crt [options]", "Self-sign a CSR with its own key", runSelfSign},
+		{"denylist", "[-ca ca.crt] [-add compromised.crt]... [-comment text]", "List and extend the denylist of keys never to certify", runDenylist},
+		{"pins", "-pin-set pins.json [-add backup.key]... [-remove <pin>]...", "List and maintain an SPKI pin set", runPins},
+		{"inspect", "[-json] <cert.pem|cert.der>...", "Show the contents of certificates", runInspect},
+		{"verify", "-ca root.crt [-untrusted intermediate.crt]... [options] <leaf.pem>", "Validate a certificate chain for CI checks", runVerify},
+		{"verify-bundle", "[options] <fullchain.pem>", "Check the ordering, completeness and validity of a bundle", runVerifyBundle},
+		{"scan", "-dir <directory> [options]", "Check certificates for weak keys, SHA-1, missing SANs and expiry", runScan},
+		{"serve-ocsp", "-ca ca.crt (-ca-key ca.key | -responder-cert ocsp.crt -responder-key ocsp.key) [options]", "Run an OCSP responder backed by the certificate database", runServeOCSP},
+		{"serve", "-ca ca.crt -ca-key ca.key -token-file tokens.txt [options]", "Run an authenticated HTTP JSON API for signing and revocation", runServe},
+		{"serve-acme", "-ca ca.crt -ca-key ca.key [-dev] [options]", "Run an ACME server for local development, backed by the CA", runServeACME},
+		{"ocsp-fetch", "-cert server.crt -out server.ocsp [options]", "Fetch an OCSP response for stapling", runOCSPFetch},
+		{"ct-monitor", "-log <url> -domain <domain> [options]", "Watch CT logs for certificates covering your domains", runCTMonitor},
+		{"test-server", "-cert server.crt -key server.key [options]", "Serve a simple HTTPS page with a certificate", runTestServer},
+		{"test-client", "[options] <https://host[:port]>", "Perform a TLS handshake and report the result", runTestClient},
+		{"bench", "[options]", "Measure key generation and signing speed", runBench},
+		{"renew", "-cert cert.pem [-ca ca.crt -ca-key ca.key] [-new-key] [options]", "Re-issue a certificate for the same key, or roll a root to a new key", runRenew},
+		{"cross-sign", "-target other-ca.crt -ca root.crt -ca-key root.key [options]", "Certify another CA's key for trust-chain migrations", runCrossSign},
+		{"decommission", "-ca ca.crt -ca-key ca.key -out <dir> -confirm <name> [options]", "Retire a CA: final CRL, archive, key destruction and a signed report", runDecommission},
+		{"dr", "snapshot|restore [options]", "Snapshot a CA directory for disaster recovery, or restore one", runDR},
+		{"config", "[-config ca.yaml] [-out ca.yaml]", "Print or write a configuration file of flag defaults", runConfig},
+		{"help", "[command]", "Show help for a command", runHelp},
 	}
+}
 
-	if config.Organization == "" {
-		// Organization is optional, but prompt for consistency
-		config.Organization = promptUser(reader, "Enter Organization (O) (optional, press Enter to skip): ", "")
-	}
+func main() {
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(2)
+	}
+
+	name, args := os.Args[1], os.Args[2:]
+	if name == "-h" || name == "-help" || name == "--help" {
+		printUsage()
+		return
+	}
+	if strings.HasPrefix(name, "-") {
+		// Before subcommands existed, root CA generation took its flags
+		// directly. Keep existing scripts working.
+		fmt.Fprintf(os.Stderr, "Warning: running without a command is deprecated; use '%s init %s'.\n", os.Args[0], strings.Join(os.Args[1:], " "))
+		runInit(os.Args[1:])
+		return
+	}
+	cmd, ok := findCommand(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q.\n\n", name)
+		printUsage()
+		os.Exit(2)
+	}
+	cmd.run(args)
+}
 
-	// Validate Key Bit Size
-	if config.KeyBitSize != 2048 && config.KeyBitSize != 4096 {
-		fmt.Printf("Warning: Recommended key sizes are 2048 or 4096. Using %d bits.\n", config.KeyBitSize)
-		// Allow other sizes but warn
-		if config.KeyBitSize < 2048 {
-			fmt.Println("Warning: Key size less than 2048 bits is considered insecure.")
+// findCommand looks up a subcommand by name.
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
 		}
 	}
+	return command{}, false
+}
 
-	// Validate Validity Days
-	if config.ValidityDays <= 0 {
-		log.Fatalf("Error: Validity days must be positive. Got %d.", config.ValidityDays)
-	}
-
-	// Construct output paths
-	config.CertOutputFile = filepath.Join(*outputDir, *certFileName)
-	config.KeyOutputFile = filepath.Join(*outputDir, *keyFileName)
-
-	// Ensure output directory exists
-	if err := os.MkdirAll(*outputDir, 0755); err != nil {
-		log.Fatalf("Error creating output directory %q: %v", *outputDir, err)
-	}
-
-	// --- Generation ---
-	fmt.Println("\nGenerating Root CA...")
-	fmt.Printf("  Common Name: %s\n", config.CommonName)
-	if config.Organization != "" {
-		fmt.Printf("  Organization: %s\n", config.Organization)
+// printUsage writes the command overview to stderr.
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [options]\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	width := 0
+	for _, cmd := range commands {
+		width = max(width, len(cmd.name))
 	}
-	fmt.Printf("  Validity: %d days\n", config.ValidityDays)
-	fmt.Printf("  Key Size: %d bits\n", config.KeyBitSize)
-	fmt.Printf("  Output Cert: %s\n", config.CertOutputFile)
-	fmt.Printf("  Output Key: %s\n", config.KeyOutputFile)
-
-	certBytes, privateKey, err := GenerateRootCA(config)
-	if err != nil {
-		log.Fatalf("Error generating CA: %v", err)
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-*s  %s\n", width, cmd.name, cmd.summary)
 	}
-	fmt.Println("CA certificate and private key generated successfully.")
-
-	// --- Export ---
-	fmt.Println("\nExporting to PEM format...")
-	err = ExportToPEM(certBytes, privateKey, config.CertOutputFile, config.KeyOutputFile)
-	if err != nil {
-		log.Fatalf("Error exporting files: %v", err)
+	fmt.Fprintf(os.Stderr, "\nSynopsis:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %s %s %s\n", os.Args[0], cmd.name, cmd.args)
 	}
-
-	fmt.Printf("\nSuccess!\n")
-	fmt.Printf("  CA Certificate saved to: %s\n", config.CertOutputFile)
-	fmt.Printf("  CA Private Key saved to: %s (Keep this file secure!)\n", config.KeyOutputFile)
+	fmt.Fprintf(os.Stderr, "\nRun '%s help <command>' or '%s <command> -h' for the options of a command.\n", os.Args[0], os.Args[0])
 }
 
-// promptUser asks the user for input with a given prompt message.
-func promptUser(reader *bufio.Reader, promptText string, defaultValue string) string {
-	fmt.Print(promptText)
-	input, _ := reader.ReadString('\n')
-	input = strings.TrimSpace(input)
-	if input == "" {
-		return defaultValue
+// runHelp implements the help command: without arguments it prints the
+// command overview, otherwise the usage of the named command.
+func runHelp(args []string) {
+	if len(args) == 0 {
+		printUsage()
+		return
 	}
-	// Attempt to parse integers if applicable (example, could be added for days/bits if not using flags)
-	if _, err := strconv.Atoi(input); err == nil {
-		// It's a number, maybe validate range if needed here
+	cmd, ok := findCommand(args[0])
+	if !ok || cmd.name == "help" {
+		fmt.Fprintf(os.Stderr, "Unknown command %q.\n\n", args[0])
+		printUsage()
+		os.Exit(2)
 	}
-	return input
+	cmd.run([]string{"-h"})
 }
 
-// GenerateRootCA creates a self-signed root CA certificate and its private key.
-func GenerateRootCA(config CAConfig) (certBytes []byte, key *rsa.PrivateKey, err error) {
-	// 1. Generate RSA Private Key
-	fmt.Println("  Generating RSA private key...")
-	privateKey, err := rsa.GenerateKey(rand.Reader, config.KeyBitSize)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to generate RSA key: %w", err)
-	}
-	key = privateKey // Assign to the named return variable
+// isFlagSet reports whether the named flag was explicitly passed on the command line.
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
 
-	// 2. Create Certificate Template
-	fmt.Println("  Creating certificate template...")
-	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128) // 128-bit serial number
-	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
+// parseTimestamp parses an RFC 3339 timestamp and normalizes it to UTC, which is
+// how x509 encodes validity times. A missing timezone offset is rejected rather
+// than guessed.
+func parseTimestamp(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to generate serial number: %w", err)
-	}
-
-	notBefore := time.Now()
-	notAfter := notBefore.AddDate(0, 0, config.ValidityDays)
-
-	template := x509.Certificate{
-		SerialNumber: serialNumber,
-		Subject: pkix.Name{
-			CommonName:   config.CommonName,
-			Organization: []string{config.Organization}, // Use slice even if potentially empty
-		},
-		Issuer: pkix.Name{ // Self-signed, Issuer == Subject
-			CommonName:   config.CommonName,
-			Organization: []string{config.Organization},
-		},
-
-		NotBefore: notBefore,
-		NotAfter:  notAfter,
-
-		KeyUsage:    x509.KeyUsageCertSign | x509.KeyUsageCRLSign, // CA usage
-		ExtKeyUsage: []x509.ExtKeyUsage{                           // Optional: Define extended key usages if needed
-			// x509.ExtKeyUsageServerAuth, // Example: if CA directly issues server certs (less common for root)
-			// x509.ExtKeyUsageClientAuth, // Example: if CA directly issues client certs
-		},
-		BasicConstraintsValid: true,
-		IsCA:                  true,
-		MaxPathLen:            1,     // Allows signing intermediate CAs (depth 1)
-		MaxPathLenZero:        false, // MaxPathLen must be > 0 if MaxPathLenZero is false
-
-		// SubjectKeyId and AuthorityKeyId are often added for easier chain building,
-		// but x509.CreateCertificate calculates AuthorityKeyId from the signer's public key
-		// if the signer's template includes SubjectKeyId. Let's let CreateCertificate handle it.
+		return time.Time{}, fmt.Errorf("%q is not an RFC 3339 timestamp (e.g. 2025-01-01T00:00:00Z): %w", value, err)
 	}
+	return t.UTC(), nil
+}
 
-	// 3. Create (Self-Sign) the Certificate
-	fmt.Println("  Signing the certificate...")
-	// The public key corresponding to the private key is used for the certificate.
-	// The signer's certificate is the template itself (self-signed).
-	// The signer's private key is the generated private key.
-	certBytes, err = x509.CreateCertificate(rand.Reader, &template, &template, &privateKey.PublicKey, privateKey)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to create certificate: %w", err)
+// formatOptionalTime renders t in RFC 3339, or fallback if t is unset.
+func formatOptionalTime(t time.Time, fallback string) string {
+	if t.IsZero() {
+		return fallback
 	}
+	return t.Format(time.RFC3339)
+}
 
-	// Optional: Verify the generated certificate can be parsed
-	_, err = x509.ParseCertificate(certBytes)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to parse generated certificate: %w", err)
+// optionalSkipCerts converts a flag value into an optional SkipCerts count,
+// treating any negative value as "not set".
+func optionalSkipCerts(value int) *int {
+	if value < 0 {
+		return nil
 	}
-
-	return certBytes, key, nil
+	return &value
 }
 
-// ExportToPEM encodes the certificate and private key into PEM format and writes them to files.
-func ExportToPEM(certBytes []byte, privateKey *rsa.PrivateKey, certPath string, keyPath string) error {
+// ExportToPEM encodes the certificate and private key into PEM format and writes them to files
+// with the given permissions. The key is encrypted if passphrase is non-nil.
+func ExportToPEM(certBytes []byte, privateKey crypto.Signer, certPath string, keyPath string, perms OutputPermissions, passphrase []byte) error {
 	// 1. Encode Certificate to PEM
 	fmt.Printf("  Encoding certificate to PEM: %s\n", certPath)
 	certPEM := pem.EncodeToMemory(&pem.Block{
@@ -233,26 +191,25 @@ func ExportToPEM(certBytes []byte, privateKey *rsa.PrivateKey, certPath string,
 	if certPEM == nil {
 		return fmt.Errorf("failed to encode certificate to PEM")
 	}
-	// Write certificate with read access for others (typical for certs)
-	if err := os.WriteFile(certPath, certPEM, 0644); err != nil {
+	// Write certificate (by default with read access for others, typical for certs)
+	if err := writeFileWithPermissions(certPath, certPEM, perms.CertMode, perms); err != nil {
 		return fmt.Errorf("failed to write certificate PEM file %q: %w", certPath, err)
 	}
 
-	// 2. Encode Private Key to PEM (using PKCS#8)
+	// 2. Encode Private Key to PEM and write it
 	fmt.Printf("  Encoding private key to PEM: %s\n", keyPath)
-	keyBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	return writePrivateKeyPEM(privateKey, keyPath, perms, passphrase)
+}
+
+// writePrivateKeyPEM encodes a private key as PKCS#8 PEM, encrypted if
+// passphrase is non-nil, and writes it with the configured key file permissions.
+func writePrivateKeyPEM(privateKey crypto.Signer, keyPath string, perms OutputPermissions, passphrase []byte) error {
+	keyPEM, err := ca.MarshalPrivateKeyPEM(privateKey, passphrase)
 	if err != nil {
-		return fmt.Errorf("failed to marshal private key to PKCS#8: %w", err)
-	}
-	keyPEM := pem.EncodeToMemory(&pem.Block{
-		Type:  "PRIVATE KEY", // "PRIVATE KEY" is standard for PKCS#8
-		Bytes: keyBytes,
-	})
-	if keyPEM == nil {
-		return fmt.Errorf("failed to encode private key to PEM")
+		return err
 	}
-	// Write private key with restricted permissions (owner read/write only)
-	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+	// Write private key (by default restricted to owner read/write only)
+	if err := writeFileWithPermissions(keyPath, keyPEM, perms.KeyMode, perms); err != nil {
 		return fmt.Errorf("failed to write private key PEM file %q: %w", keyPath, err)
 	}
 
@@ -0,0 +1,157 @@
+// ocsp_fetch.go
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/prtk1729/certA/pkg/ca"
+	"golang.org/x/crypto/ocsp"
+)
+
+const maxOCSPResponseSize = 1 << 20 // OCSP responses are small; cap reads at 1 MiB
+
+// runOCSPFetch implements the ocsp-fetch command: it obtains an OCSP response
+// for a certificate and stores it in DER form for server stapling
+// (e.g. nginx ssl_stapling_file, HAProxy .ocsp files).
+func runOCSPFetch(args []string) {
+	fs := flag.NewFlagSet("ocsp-fetch", flag.ExitOnError)
+	certFile := fs.String("cert", "", "Required: certificate PEM file (may include the issuer as the second certificate)")
+	issuerFile := fs.String("issuer", "", "Optional: issuer certificate PEM file (default: second certificate in -cert)")
+	outFile := fs.String("out", "", "Required: path to write the DER-encoded OCSP response")
+	responderURL := fs.String("url", "", "Optional: OCSP responder URL (default: taken from the certificate's AIA extension)")
+	refresh := fs.Duration("refresh-before", 24*time.Hour, "Reuse a cached response unless it expires within this window")
+	timeout := fs.Duration("timeout", 15*time.Second, "HTTP timeout for the responder request")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s ocsp-fetch -cert server.crt -out server.ocsp [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Fetches and caches a DER OCSP response suitable for stapling.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if err := checkFlags(fs); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	if *certFile == "" || *outFile == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	certs, err := ca.LoadCertificates(*certFile)
+	if err != nil {
+		log.Fatalf("Error loading certificate: %v", err)
+	}
+	cert := certs[0]
+
+	var issuer *x509.Certificate
+	switch {
+	case *issuerFile != "":
+		issuers, err := ca.LoadCertificates(*issuerFile)
+		if err != nil {
+			log.Fatalf("Error loading issuer: %v", err)
+		}
+		issuer = issuers[0]
+	case len(certs) > 1:
+		issuer = certs[1]
+	default:
+		log.Fatal("Error: no issuer certificate; pass -issuer or include it in -cert.")
+	}
+
+	// Reuse the cached response while it is comfortably fresh, so the command
+	// can be run from cron without hammering the responder.
+	if cached, err := os.ReadFile(*outFile); err == nil {
+		resp, err := ocsp.ParseResponseForCert(cached, cert, issuer)
+		if err == nil && time.Until(resp.NextUpdate) > *refresh {
+			fmt.Printf("Cached OCSP response in %s is valid until %s; not refreshing.\n", *outFile, resp.NextUpdate.Format(time.RFC3339))
+			return
+		}
+	}
+
+	url := *responderURL
+	if url == "" {
+		if len(cert.OCSPServer) == 0 {
+			log.Fatal("Error: certificate has no OCSP responder URL in its AIA extension; pass -url.")
+		}
+		url = cert.OCSPServer[0]
+	}
+
+	fmt.Printf("Requesting OCSP status for serial %X from %s...\n", cert.SerialNumber, url)
+	der, resp, err := FetchOCSPResponse(&http.Client{Timeout: *timeout}, url, cert, issuer)
+	if err != nil {
+		log.Fatalf("Error fetching OCSP response: %v", err)
+	}
+
+	fmt.Printf("  Status: %s\n", ocspStatusName(resp.Status))
+	fmt.Printf("  This Update: %s\n", resp.ThisUpdate.Format(time.RFC3339))
+	if !resp.NextUpdate.IsZero() {
+		fmt.Printf("  Next Update: %s\n", resp.NextUpdate.Format(time.RFC3339))
+	}
+	if resp.Status != ocsp.Good {
+		fmt.Println("Warning: the certificate is not in good standing; stapling this response will cause clients to reject it.")
+	}
+
+	if err := os.WriteFile(*outFile, der, 0644); err != nil {
+		log.Fatalf("Error writing OCSP response: %v", err)
+	}
+	fmt.Printf("OCSP response saved to: %s\n", *outFile)
+}
+
+// FetchOCSPResponse queries an OCSP responder for cert and returns the raw DER
+// response together with its parsed form. The response's signature is verified
+// against issuer before it is returned.
+func FetchOCSPResponse(client *http.Client, responderURL string, cert, issuer *x509.Certificate) ([]byte, *ocsp.Response, error) {
+	request, err := ocsp.CreateRequest(cert, issuer, &ocsp.RequestOptions{Hash: crypto.SHA1})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create OCSP request: %w", err)
+	}
+
+	httpResp, err := client.Post(responderURL, "application/ocsp-request", bytes.NewReader(request))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to contact responder: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("responder returned HTTP %s", httpResp.Status)
+	}
+	der, err := io.ReadAll(io.LimitReader(httpResp.Body, maxOCSPResponseSize))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	resp, err := ocsp.ParseResponseForCert(der, cert, issuer)
+	if err != nil {
+		var respErr ocsp.ResponseError
+		if errors.As(err, &respErr) {
+			return nil, nil, fmt.Errorf("responder refused the request: %w", err)
+		}
+		return nil, nil, fmt.Errorf("invalid OCSP response: %w", err)
+	}
+	if !resp.NextUpdate.IsZero() && time.Now().After(resp.NextUpdate) {
+		return nil, nil, fmt.Errorf("responder returned a stale response (nextUpdate %s)", resp.NextUpdate.Format(time.RFC3339))
+	}
+	return der, resp, nil
+}
+
+// ocspStatusName renders an OCSP certificate status for display.
+func ocspStatusName(status int) string {
+	switch status {
+	case ocsp.Good:
+		return "good"
+	case ocsp.Revoked:
+		return "revoked"
+	default:
+		return "unknown"
+	}
+}
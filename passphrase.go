@@ -0,0 +1,60 @@
+// passphrase.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/prtk1729/certA/pkg/ca"
+)
+
+// passphraseFlags holds a -passphrase / -passphrase-file flag pair.
+type passphraseFlags struct {
+	prefix string
+	value  string
+	file   string
+}
+
+// register adds <prefix>passphrase and <prefix>passphrase-file to fs. purpose
+// completes the usage text, e.g. "encrypt the CA private key with".
+func (p *passphraseFlags) register(fs *flag.FlagSet, prefix, purpose string) {
+	p.prefix = prefix
+	fs.StringVar(&p.value, prefix+"passphrase", "", "Passphrase to "+purpose+" (visible to other local users; prefer -"+prefix+"passphrase-file)")
+	fs.StringVar(&p.file, prefix+"passphrase-file", "", "File whose first line is the passphrase to "+purpose)
+}
+
+// read returns the configured passphrase, or nil if neither flag was given.
+func (p *passphraseFlags) read() ([]byte, error) {
+	if p.value != "" && p.file != "" {
+		return nil, fmt.Errorf("-%spassphrase and -%spassphrase-file are mutually exclusive", p.prefix, p.prefix)
+	}
+	passphrase := p.value
+	if p.file != "" {
+		data, err := os.ReadFile(p.file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read passphrase file: %w", err)
+		}
+		passphrase, _, _ = strings.Cut(string(data), "\n")
+		passphrase = strings.TrimSuffix(passphrase, "\r")
+		if passphrase == "" {
+			return nil, fmt.Errorf("passphrase file %q is empty", p.file)
+		}
+	}
+	if passphrase == "" {
+		return nil, nil
+	}
+	return []byte(passphrase), nil
+}
+
+// source returns a ca.PassphraseFunc backed by the flags.
+func (p *passphraseFlags) source() ca.PassphraseFunc {
+	return func() ([]byte, error) {
+		passphrase, err := p.read()
+		if err == nil && passphrase == nil {
+			err = fmt.Errorf("the key is encrypted; supply -%spassphrase or -%spassphrase-file", p.prefix, p.prefix)
+		}
+		return passphrase, err
+	}
+}
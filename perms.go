@@ -0,0 +1,130 @@
+// perms.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"runtime"
+	"strconv"
+)
+
+const (
+	defaultCertFileMode os.FileMode = 0644 // Certificates are public
+	defaultKeyFileMode  os.FileMode = 0600 // Keys are owner read/write only
+	defaultDirMode      os.FileMode = 0755
+)
+
+// OutputPermissions controls the mode and ownership of emitted files and of
+// the output directory when it has to be created. A UID or GID of -1 leaves
+// ownership unchanged.
+type OutputPermissions struct {
+	CertMode os.FileMode
+	KeyMode  os.FileMode
+	DirMode  os.FileMode
+	UID      int
+	GID      int
+}
+
+// DefaultOutputPermissions returns the permissions used when none are given.
+func DefaultOutputPermissions() OutputPermissions {
+	return OutputPermissions{
+		CertMode: defaultCertFileMode,
+		KeyMode:  defaultKeyFileMode,
+		DirMode:  defaultDirMode,
+		UID:      -1,
+		GID:      -1,
+	}
+}
+
+// parseFileMode parses an octal permission string such as "0640".
+func parseFileMode(value string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("%q is not an octal permission mode (e.g. 0640)", value)
+	}
+	return os.FileMode(mode), nil
+}
+
+// lookupOwner resolves a user name or numeric UID. An empty value yields -1.
+func lookupOwner(value string) (int, error) {
+	if value == "" {
+		return -1, nil
+	}
+	if id, err := strconv.Atoi(value); err == nil {
+		return id, nil
+	}
+	u, err := user.Lookup(value)
+	if err != nil {
+		return 0, fmt.Errorf("unknown user %q: %w", value, err)
+	}
+	return strconv.Atoi(u.Uid)
+}
+
+// lookupGroup resolves a group name or numeric GID. An empty value yields -1.
+func lookupGroup(value string) (int, error) {
+	if value == "" {
+		return -1, nil
+	}
+	if id, err := strconv.Atoi(value); err == nil {
+		return id, nil
+	}
+	g, err := user.LookupGroup(value)
+	if err != nil {
+		return 0, fmt.Errorf("unknown group %q: %w", value, err)
+	}
+	return strconv.Atoi(g.Gid)
+}
+
+// prepareOutputDir creates dir if needed, applying the configured mode and
+// ownership to it only when it was newly created. In strict mode it refuses a
+// directory that other users could write to, since they could replace the
+// key or certificate after it has been written.
+func prepareOutputDir(dir string, perms OutputPermissions, strict bool) error {
+	_, statErr := os.Stat(dir)
+	created := errors.Is(statErr, os.ErrNotExist)
+
+	if err := os.MkdirAll(dir, perms.DirMode); err != nil {
+		return fmt.Errorf("failed to create output directory %q: %w", dir, err)
+	}
+	if created {
+		if err := applyPermissions(dir, perms.DirMode, perms); err != nil {
+			return err
+		}
+	}
+
+	if strict && runtime.GOOS != "windows" {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return fmt.Errorf("failed to stat output directory %q: %w", dir, err)
+		}
+		if info.Mode().Perm()&0022 != 0 {
+			return fmt.Errorf("output directory %q is group- or world-writable (mode %04o); refusing in strict mode", dir, info.Mode().Perm())
+		}
+	}
+	return nil
+}
+
+// writeFileWithPermissions writes data to path and then enforces mode and
+// ownership explicitly: os.WriteFile leaves an existing file's mode untouched
+// and is subject to the umask.
+func writeFileWithPermissions(path string, data []byte, mode os.FileMode, perms OutputPermissions) error {
+	if err := os.WriteFile(path, data, mode); err != nil {
+		return err
+	}
+	return applyPermissions(path, mode, perms)
+}
+
+// applyPermissions sets mode and, if requested, ownership on path.
+func applyPermissions(path string, mode os.FileMode, perms OutputPermissions) error {
+	if err := os.Chmod(path, mode); err != nil {
+		return fmt.Errorf("failed to set mode %04o on %q: %w", mode, path, err)
+	}
+	if perms.UID != -1 || perms.GID != -1 {
+		if err := os.Chown(path, perms.UID, perms.GID); err != nil {
+			return fmt.Errorf("failed to set ownership on %q: %w", path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+// pins.go
+package main
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/prtk1729/certA/pkg/ca"
+)
+
+// pinSet is the on-disk state file for SPKI-pinned issuance. Clients such as
+// mobile apps ship with the declared pins; every certificate issued against
+// the set must use one of their keys, or pinned clients will reject it.
+type pinSet struct {
+	Pins []*pinEntry `json:"pins"`
+}
+
+// pinEntry is one declared pin and the most recent certificate issued for it.
+type pinEntry struct {
+	SPKISHA256     string    `json:"spki_sha256"`
+	Source         string    `json:"source,omitempty"` // File the pin was taken from
+	Added          time.Time `json:"added"`
+	Issued         int       `json:"issued"`
+	LastSerial     string    `json:"last_serial,omitempty"`
+	LastCommonName string    `json:"last_common_name,omitempty"`
+	LastNotAfter   time.Time `json:"last_not_after,omitempty"`
+}
+
+// loadPinSet reads a pin set file. A missing file is not an error and yields
+// an empty set.
+func loadPinSet(path string) (*pinSet, error) {
+	set := &pinSet{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return set, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pin set %q: %w", path, err)
+	}
+	if err := json.Unmarshal(data, set); err != nil {
+		return nil, fmt.Errorf("failed to parse pin set %q: %w", path, err)
+	}
+	return set, nil
+}
+
+// save writes the pin set back to disk, creating the parent directory if needed.
+func (s *pinSet) save(path string) error {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create pin set directory: %w", err)
+		}
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode pin set: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write pin set %q: %w", path, err)
+	}
+	return nil
+}
+
+// find returns the entry for pin, or nil if it is not declared.
+func (s *pinSet) find(pin string) *pinEntry {
+	for _, entry := range s.Pins {
+		if entry.SPKISHA256 == pin {
+			return entry
+		}
+	}
+	return nil
+}
+
+// add declares pin, returning the existing entry if it is already present.
+func (s *pinSet) add(pin, source string) *pinEntry {
+	if entry := s.find(pin); entry != nil {
+		return entry
+	}
+	entry := &pinEntry{SPKISHA256: pin, Source: source, Added: time.Now().UTC()}
+	s.Pins = append(s.Pins, entry)
+	return entry
+}
+
+// remove drops pin from the set, reporting whether it was present.
+func (s *pinSet) remove(pin string) bool {
+	for i, entry := range s.Pins {
+		if entry.SPKISHA256 == pin {
+			s.Pins = append(s.Pins[:i], s.Pins[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// print writes the pin set report, marking current as the pin just used.
+func (s *pinSet) print(current string) {
+	if len(s.Pins) == 0 {
+		fmt.Println("  (no pins declared)")
+		return
+	}
+	for _, entry := range s.Pins {
+		marker := " "
+		if entry.SPKISHA256 == current {
+			marker = "*"
+		}
+		fmt.Printf(" %s sha256/%s", marker, entry.SPKISHA256)
+		if entry.Source != "" {
+			fmt.Printf("  (%s)", entry.Source)
+		}
+		fmt.Println()
+		if entry.Issued == 0 {
+			fmt.Println("      no certificates issued (backup pin)")
+			continue
+		}
+		fmt.Printf("      %d issued; last: %s, serial %s, expires %s\n", entry.Issued,
+			entry.LastCommonName, entry.LastSerial, entry.LastNotAfter.Format("2006-01-02"))
+	}
+}
+
+// pinSetFlags are the flags shared by commands that issue against a pin set.
+type pinSetFlags struct {
+	path   string
+	addPin bool
+}
+
+func (f *pinSetFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.path, "pin-set", "", "Optional: SPKI pin set file; the certificate key must be one of its declared pins (an empty set adopts the key as its first pin)")
+	fs.BoolVar(&f.addPin, "add-pin", false, "Declare the certificate key in -pin-set if it is not already there (only once pinned clients ship the new pin)")
+}
+
+// check loads the pin set and verifies that pub is declared in it, returning
+// the set and pub's pin. It returns a nil set when -pin-set is not given.
+func (f *pinSetFlags) check(pub crypto.PublicKey, source string) (*pinSet, string, error) {
+	if f.path == "" {
+		if f.addPin {
+			return nil, "", errors.New("-add-pin requires -pin-set")
+		}
+		return nil, "", nil
+	}
+	pin, err := ca.SPKIPin(pub)
+	if err != nil {
+		return nil, "", err
+	}
+	set, err := loadPinSet(f.path)
+	if err != nil {
+		return nil, "", err
+	}
+	if set.find(pin) == nil {
+		if len(set.Pins) > 0 && !f.addPin {
+			return nil, "", fmt.Errorf("key pin sha256/%s is not declared in pin set %q, so pinned clients would reject the certificate; "+
+				"reuse a pinned key with -key-file, or pass -add-pin once clients trust the new pin", pin, f.path)
+		}
+		set.add(pin, source)
+	}
+	return set, pin, nil
+}
+
+// record notes cert against its pin, saves the set and prints the report.
+func (f *pinSetFlags) record(set *pinSet, pin string, cert *x509.Certificate) error {
+	entry := set.find(pin)
+	entry.Issued++
+	entry.LastSerial = fmt.Sprintf("%X", cert.SerialNumber)
+	entry.LastCommonName = cert.Subject.CommonName
+	entry.LastNotAfter = cert.NotAfter.UTC()
+	if err := set.save(f.path); err != nil {
+		return err
+	}
+
+	fmt.Printf("\nPin set %s (* = this certificate):\n", f.path)
+	set.print(pin)
+	if len(set.Pins) < 2 {
+		fmt.Println("  Warning: only one pin is declared; declare a backup key with the pins command so the key can be rotated without breaking clients.")
+	}
+	return nil
+}
+
+// runPins implements the pins command: it lists an SPKI pin set and declares
+// or withdraws pins, e.g. for backup keys that clients should already trust
+// before they are rotated in.
+func runPins(args []string) {
+	fs := flag.NewFlagSet("pins", flag.ExitOnError)
+	path := fs.String("pin-set", "", "Required: SPKI pin set file")
+	var add, remove stringList
+	fs.Var(&add, "add", "Repeatable: declare the pin of a private key, public key, certificate or CSR PEM file")
+	fs.Var(&remove, "remove", "Repeatable: withdraw a pin (base64 SHA-256, with or without the 'sha256/' prefix)")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s pins -pin-set pins.json [-add backup.key]... [-remove <pin>]...\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Lists and maintains the SPKI pin set used by issue -pin-set and sign-csr -pin-set.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *path == "" {
+		fs.Usage()
+		log.Fatal("Error: -pin-set is required.")
+	}
+	set, err := loadPinSet(*path)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	for _, file := range add {
+		pub, err := loadPinnedPublicKey(file)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		pin, err := ca.SPKIPin(pub)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		set.add(pin, file)
+		fmt.Printf("Declared pin sha256/%s from %s\n", pin, file)
+	}
+	for _, pin := range remove {
+		pin = strings.TrimPrefix(pin, "sha256/")
+		if entry := set.find(pin); entry != nil && entry.Issued > 0 && entry.LastNotAfter.After(time.Now()) {
+			fmt.Printf("Warning: withdrawing sha256/%s while a certificate using it is still valid.\n", pin)
+		}
+		if !set.remove(pin) {
+			log.Fatalf("Error: pin sha256/%s is not declared in %q.", pin, *path)
+		}
+		fmt.Printf("Withdrew pin sha256/%s\n", pin)
+	}
+	if len(add) > 0 || len(remove) > 0 {
+		if err := set.save(*path); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		fmt.Println()
+	}
+
+	fmt.Printf("Pin set %s:\n", *path)
+	set.print("")
+}
+
+// loadPinnedPublicKey reads the public key from the first private key, public
+// key, certificate or certificate request in a PEM file.
+func loadPinnedPublicKey(path string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q: %w", path, err)
+	}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("no PEM key, certificate or certificate request found in %q", path)
+		}
+		switch block.Type {
+		case "PUBLIC KEY":
+			return x509.ParsePKIXPublicKey(block.Bytes)
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse certificate in %q: %w", path, err)
+			}
+			return cert.PublicKey, nil
+		case "CERTIFICATE REQUEST", "NEW CERTIFICATE REQUEST":
+			csr, err := x509.ParseCertificateRequest(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse certificate request in %q: %w", path, err)
+			}
+			return csr.PublicKey, nil
+		case "PRIVATE KEY", "RSA PRIVATE KEY", "EC PRIVATE KEY", "ENCRYPTED PRIVATE KEY":
+			key, err := ca.LoadPrivateKey(path, nil)
+			if err != nil {
+				return nil, err
+			}
+			return key.Public(), nil
+		}
+	}
+}
@@ -0,0 +1,204 @@
+// ca.go
+package ca
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+)
+
+// CA is a certificate authority: a CA certificate and the key it signs with.
+type CA struct {
+	Certificate *x509.Certificate
+	Key         crypto.Signer
+}
+
+// Config holds the parameters for a new root or intermediate CA certificate.
+type Config struct {
+	CommonName   string
+	Organization string
+	Subject      SubjectAttributes // Optional further subject attributes
+	ValidityDays int
+	NotBefore    time.Time // Optional: defaults to the current time
+	NotAfter     time.Time // Optional: defaults to NotBefore + ValidityDays
+	KeyType      string    // One of the KeyType* constants; empty selects RSA
+	KeyBitSize   int       // RSA key size; ignored for other key types
+	SerialBits   int       // Serial number length in bits (64-160); 0 selects the default
+	SerialNumber *big.Int  // Optional: use this serial instead of generating one; SerialBits is ignored
+	SKIDMethod   string    // Subject key identifier method: "sha1" (default) or "sha256"
+
+	// Policy constraints for CA certificates. A nil value omits the
+	// corresponding field (or extension) from the certificate.
+	RequireExplicitPolicy *int
+	InhibitPolicyMapping  *int
+	InhibitAnyPolicy      *int
+
+	// PathLen is the path length constraint of a root created by NewRootCA:
+	// how many CA levels may follow it, 0 for a root that may only sign
+	// leaves, or -1 for unlimited. Nil selects DefaultRootPathLen.
+	// NewIntermediate takes the path length as an argument instead.
+	PathLen *int
+
+	// NameConstraints restricts the names certificates below the CA may
+	// carry; the zero value omits the extension.
+	NameConstraints NameConstraints
+
+	// Clock supplies the current time; nil selects SystemClock.
+	Clock Clock
+
+	// Test support: when set, Rand replaces crypto/rand so that, together
+	// with a fixed Clock, identical inputs produce byte-identical certificates.
+	Rand io.Reader
+}
+
+// DefaultRootPathLen is the path length constraint of a root CA unless
+// Config.PathLen says otherwise: the root may sign one level of intermediate
+// CAs, which in turn sign leaves.
+const DefaultRootPathLen = 1
+
+// NewRootCA creates a self-signed root CA certificate and its private key.
+// Its path length constraint is set by config.PathLen.
+func NewRootCA(config Config) (*CA, error) {
+	random := randomOrDefault(config.Rand)
+	privateKey, err := GenerateKey(random, config.KeyType, config.KeyBitSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate private key: %w", err)
+	}
+
+	pathLen := DefaultRootPathLen
+	if config.PathLen != nil {
+		pathLen = *config.PathLen
+	}
+	template, err := buildCATemplate(config, privateKey.Public(), pathLen)
+	if err != nil {
+		return nil, err
+	}
+	// A self-signed root is its own authority, so Issuer == Subject and AKID == SKID.
+	template.Issuer = template.Subject
+	template.AuthorityKeyId = template.SubjectKeyId
+
+	certBytes, err := x509.CreateCertificate(random, template, template, privateKey.Public(), privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(certBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse generated certificate: %w", err)
+	}
+	return &CA{Certificate: cert, Key: privateKey}, nil
+}
+
+// NewIntermediate creates a new key pair and an intermediate CA certificate
+// for it, signed by c. maxPathLen limits how many further CA levels may follow
+// (-1 for unlimited).
+func (c *CA) NewIntermediate(config Config, maxPathLen int) (*CA, error) {
+	if err := c.checkPathLen(maxPathLen); err != nil {
+		return nil, err
+	}
+	random := randomOrDefault(config.Rand)
+	privateKey, err := GenerateKey(random, config.KeyType, config.KeyBitSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate private key: %w", err)
+	}
+
+	template, err := buildCATemplate(config, privateKey.Public(), maxPathLen)
+	if err != nil {
+		return nil, err
+	}
+
+	// CreateCertificate takes the issuer name from the parent and derives the
+	// AKID from the parent's SKID.
+	certBytes, err := x509.CreateCertificate(random, template, c.Certificate, privateKey.Public(), c.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(certBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse generated certificate: %w", err)
+	}
+	return &CA{Certificate: cert, Key: privateKey}, nil
+}
+
+// checkPathLen reports whether c's own path length constraint allows it to
+// sign a CA certificate with the given one, which relying parties would
+// otherwise reject when building the chain.
+func (c *CA) checkPathLen(maxPathLen int) error {
+	issuer := c.Certificate
+	switch {
+	case issuer.MaxPathLen == 0 && issuer.MaxPathLenZero:
+		return fmt.Errorf("issuing CA %s has path length 0 and may only sign leaf certificates", issuer.Subject)
+	case issuer.MaxPathLen > 0 && (maxPathLen < 0 || maxPathLen >= issuer.MaxPathLen):
+		return fmt.Errorf("issuing CA %s has path length %d, so the new CA's path length must be at most %d", issuer.Subject, issuer.MaxPathLen, issuer.MaxPathLen-1)
+	}
+	return nil
+}
+
+// IsRoot reports whether the CA certificate is self-issued.
+func (c *CA) IsRoot() bool {
+	return bytes.Equal(c.Certificate.RawIssuer, c.Certificate.RawSubject)
+}
+
+// ValidityWindow resolves the certificate validity period. Absolute NotBefore and
+// NotAfter values take precedence; otherwise the window starts at now and lasts
+// ValidityDays.
+func (c Config) ValidityWindow(now time.Time) (notBefore, notAfter time.Time) {
+	return validityWindow(c.NotBefore, c.NotAfter, c.ValidityDays, now)
+}
+
+// buildCATemplate assembles the certificate template shared by root and
+// intermediate CAs for the given public key. maxPathLen follows the x509
+// convention: -1 means unlimited, 0 means the CA may only issue leaves.
+func buildCATemplate(config Config, pub crypto.PublicKey, maxPathLen int) (*x509.Certificate, error) {
+	serialNumber, err := serialOrGenerate(config.SerialNumber, randomOrDefault(config.Rand), config.SerialBits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	notBefore, notAfter := config.ValidityWindow(clockOrDefault(config.Clock).Now())
+
+	// Some chain builders match issuers purely by key identifier, so set the
+	// SKID explicitly rather than relying on library defaults.
+	subjectKeyID, err := computeSubjectKeyID(pub, config.SKIDMethod)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute subject key identifier: %w", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject:      subjectName(config.CommonName, config.Organization, config.Subject),
+
+		NotBefore: notBefore,
+		NotAfter:  notAfter,
+
+		KeyUsage:    x509.KeyUsageCertSign | x509.KeyUsageCRLSign, // CA usage
+		ExtKeyUsage: []x509.ExtKeyUsage{                           // Optional: Define extended key usages if needed
+			// x509.ExtKeyUsageServerAuth, // Example: if CA directly issues server certs (less common for root)
+			// x509.ExtKeyUsageClientAuth, // Example: if CA directly issues client certs
+		},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLen:            maxPathLen,
+		MaxPathLenZero:        maxPathLen == 0, // Distinguishes an explicit 0 from "unset"
+
+		SubjectKeyId: subjectKeyID,
+	}
+
+	if err := config.NameConstraints.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid name constraints:\n%w", err)
+	}
+	config.NameConstraints.applyTo(template)
+
+	// Policy constraints are not exposed on x509.Certificate in our minimum Go
+	// version, so they are encoded by hand.
+	policyExtensions, err := policyConstraintExtensions(config)
+	if err != nil {
+		return nil, err
+	}
+	template.ExtraExtensions = append(template.ExtraExtensions, policyExtensions...)
+
+	return template, nil
+}
@@ -0,0 +1,14 @@
+// ca_test.go
+package ca
+
+import "testing"
+
+// newTestRoot returns a root CA with an Ed25519 key, valid for days.
+func newTestRoot(t *testing.T, commonName string, days int) *CA {
+	t.Helper()
+	root, err := NewRootCA(Config{CommonName: commonName, ValidityDays: days, KeyType: KeyTypeEd25519})
+	if err != nil {
+		t.Fatalf("NewRootCA: %v", err)
+	}
+	return root
+}
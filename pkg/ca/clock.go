@@ -0,0 +1,34 @@
+// clock.go
+package ca
+
+import "time"
+
+// Clock supplies the current time wherever this package depends on it, such
+// as certificate validity windows. Tests can substitute a fixed clock to
+// simulate expiry, and servers can plug in a trusted time source.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the Clock backed by time.Now. It is used whenever a Clock
+// option is nil.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// FixedClock returns a Clock that always reports t.
+func FixedClock(t time.Time) Clock { return fixedClock(t) }
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time { return time.Time(c) }
+
+// clockOrDefault returns clock, or SystemClock if it is nil.
+func clockOrDefault(clock Clock) Clock {
+	if clock != nil {
+		return clock
+	}
+	return SystemClock
+}
@@ -0,0 +1,199 @@
+// constraints.go
+package ca
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// NameConstraints restricts the names that certificates below a CA may carry
+// (RFC 5280, section 4.2.1.10). A name must fall within at least one permitted
+// entry of its type, if there are any, and within no excluded entry.
+//
+// DNS entries match the domain and its subdomains; a leading dot
+// (".example.com") matches subdomains only. Email entries are a full mailbox,
+// a host ("example.com": mailboxes on that host) or a domain (".example.com":
+// mailboxes on any host below it). URI entries match the URI's host the same
+// way as email hosts.
+type NameConstraints struct {
+	PermittedDNSDomains     []string
+	ExcludedDNSDomains      []string
+	PermittedIPRanges       []*net.IPNet
+	ExcludedIPRanges        []*net.IPNet
+	PermittedEmailAddresses []string
+	ExcludedEmailAddresses  []string
+	PermittedURIDomains     []string
+	ExcludedURIDomains      []string
+
+	// NotCritical marks the extension non-critical. RFC 5280 requires it
+	// to be critical, but a few old clients reject certificates with a
+	// critical extension they do not understand.
+	NotCritical bool
+}
+
+// IsEmpty reports whether n constrains nothing.
+func (n NameConstraints) IsEmpty() bool {
+	return len(n.PermittedDNSDomains)+len(n.ExcludedDNSDomains)+
+		len(n.PermittedIPRanges)+len(n.ExcludedIPRanges)+
+		len(n.PermittedEmailAddresses)+len(n.ExcludedEmailAddresses)+
+		len(n.PermittedURIDomains)+len(n.ExcludedURIDomains) == 0
+}
+
+// Validate reports every malformed entry.
+func (n NameConstraints) Validate() error {
+	var errs []error
+	checkDomains := func(kind string, domains []string) {
+		for _, domain := range domains {
+			if err := checkDNSSyntax(strings.TrimPrefix(domain, "."), SANPolicy{}); err != nil || strings.HasPrefix(domain, "*") {
+				errs = append(errs, fmt.Errorf("%s constraint %q is not a domain", kind, domain))
+			}
+		}
+	}
+	checkDomains("DNS", n.PermittedDNSDomains)
+	checkDomains("DNS", n.ExcludedDNSDomains)
+	checkDomains("URI", n.PermittedURIDomains)
+	checkDomains("URI", n.ExcludedURIDomains)
+	for _, email := range append(append([]string(nil), n.PermittedEmailAddresses...), n.ExcludedEmailAddresses...) {
+		if strings.Contains(email, "@") {
+			if err := checkEmailSyntax(email, SANPolicy{}); err != nil {
+				errs = append(errs, fmt.Errorf("email constraint %q: %w", email, err))
+			}
+		} else {
+			checkDomains("email", []string{email})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// applyTo sets the constraints on a CA certificate template.
+func (n NameConstraints) applyTo(template *x509.Certificate) {
+	if n.IsEmpty() {
+		return
+	}
+	template.PermittedDNSDomainsCritical = !n.NotCritical
+	template.PermittedDNSDomains = n.PermittedDNSDomains
+	template.ExcludedDNSDomains = n.ExcludedDNSDomains
+	template.PermittedIPRanges = n.PermittedIPRanges
+	template.ExcludedIPRanges = n.ExcludedIPRanges
+	template.PermittedEmailAddresses = n.PermittedEmailAddresses
+	template.ExcludedEmailAddresses = n.ExcludedEmailAddresses
+	template.PermittedURIDomains = n.PermittedURIDomains
+	template.ExcludedURIDomains = n.ExcludedURIDomains
+}
+
+// CertificateNameConstraints returns the name constraints of cert.
+func CertificateNameConstraints(cert *x509.Certificate) NameConstraints {
+	return NameConstraints{
+		PermittedDNSDomains:     cert.PermittedDNSDomains,
+		ExcludedDNSDomains:      cert.ExcludedDNSDomains,
+		PermittedIPRanges:       cert.PermittedIPRanges,
+		ExcludedIPRanges:        cert.ExcludedIPRanges,
+		PermittedEmailAddresses: cert.PermittedEmailAddresses,
+		ExcludedEmailAddresses:  cert.ExcludedEmailAddresses,
+		PermittedURIDomains:     cert.PermittedURIDomains,
+		ExcludedURIDomains:      cert.ExcludedURIDomains,
+		NotCritical:             !cert.PermittedDNSDomainsCritical,
+	}
+}
+
+// Check reports every SAN in opts that n does not allow, so that a CA does not
+// sign a certificate that relying parties would reject.
+func (n NameConstraints) Check(opts IssueOptions) error {
+	var errs []error
+	check := func(kind, name string, permitted, excluded []string, match func(name, constraint string) bool) {
+		for _, constraint := range excluded {
+			if match(name, constraint) {
+				errs = append(errs, fmt.Errorf("%s SAN %q is excluded by %q", kind, name, constraint))
+				return
+			}
+		}
+		if len(permitted) == 0 {
+			return
+		}
+		for _, constraint := range permitted {
+			if match(name, constraint) {
+				return
+			}
+		}
+		errs = append(errs, fmt.Errorf("%s SAN %q is not within the permitted %s", kind, name, strings.Join(permitted, ", ")))
+	}
+
+	for _, name := range opts.DNSNames {
+		check("dns", name, n.PermittedDNSDomains, n.ExcludedDNSDomains, matchDNSConstraint)
+	}
+	for _, email := range opts.EmailAddresses {
+		check("email", email, n.PermittedEmailAddresses, n.ExcludedEmailAddresses, matchEmailConstraint)
+	}
+	for _, u := range opts.URIs {
+		host := u.Hostname()
+		if len(n.PermittedURIDomains)+len(n.ExcludedURIDomains) > 0 && (host == "" || net.ParseIP(host) != nil) {
+			errs = append(errs, fmt.Errorf("uri SAN %q has no domain name to check against the URI constraints", u))
+			continue
+		}
+		check("uri", u.String(), n.PermittedURIDomains, n.ExcludedURIDomains, func(string, constraint string) bool {
+			return matchHostConstraint(host, constraint)
+		})
+	}
+	for _, ip := range opts.IPAddresses {
+		if r := containingRange(ip, n.ExcludedIPRanges); r != nil {
+			errs = append(errs, fmt.Errorf("ip SAN %q is excluded by %q", ip, r))
+		} else if len(n.PermittedIPRanges) > 0 && containingRange(ip, n.PermittedIPRanges) == nil {
+			errs = append(errs, fmt.Errorf("ip SAN %q is not within the permitted %s", ip, joinIPRanges(n.PermittedIPRanges)))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// containingRange returns the first of ranges that contains ip, or nil.
+func containingRange(ip net.IP, ranges []*net.IPNet) *net.IPNet {
+	for _, r := range ranges {
+		if r.Contains(ip) {
+			return r
+		}
+	}
+	return nil
+}
+
+// joinIPRanges renders ranges in CIDR notation.
+func joinIPRanges(ranges []*net.IPNet) string {
+	parts := make([]string, len(ranges))
+	for i, r := range ranges {
+		parts[i] = r.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// matchDNSConstraint reports whether a DNS name is within constraint.
+func matchDNSConstraint(name, constraint string) bool {
+	name, constraint = strings.ToLower(name), strings.ToLower(constraint)
+	if strings.HasPrefix(constraint, ".") {
+		return strings.HasSuffix(name, constraint)
+	}
+	return name == constraint || strings.HasSuffix(name, "."+constraint)
+}
+
+// matchHostConstraint reports whether a host is within an email or URI
+// constraint, which unlike a DNS constraint names one host unless it starts
+// with a dot.
+func matchHostConstraint(host, constraint string) bool {
+	host, constraint = strings.ToLower(host), strings.ToLower(constraint)
+	if strings.HasPrefix(constraint, ".") {
+		return strings.HasSuffix(host, constraint)
+	}
+	return host == constraint
+}
+
+// matchEmailConstraint reports whether a mailbox is within constraint.
+func matchEmailConstraint(email, constraint string) bool {
+	local, host, ok := strings.Cut(email, "@")
+	if !ok {
+		return false
+	}
+	if constraintLocal, constraintHost, ok := strings.Cut(constraint, "@"); ok {
+		return local == constraintLocal && strings.EqualFold(host, constraintHost)
+	}
+	return matchHostConstraint(host, constraint)
+}
@@ -0,0 +1,82 @@
+// crosssign.go
+package ca
+
+import (
+	"bytes"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// CrossSignOptions holds the parameters for cross-signing another CA.
+type CrossSignOptions struct {
+	// ValidityDays is the validity period; 0 runs until the target
+	// certificate expires. Either way the certificate ends no later than
+	// the signing CA's own certificate.
+	ValidityDays int
+
+	// MaxPathLen overrides the target's path length, e.g. to fit below
+	// the signing CA's; nil keeps it. -1 means unlimited.
+	MaxPathLen *int
+
+	SerialBits   int      // Serial number length in bits (64-160); 0 selects the default
+	SerialNumber *big.Int // Optional: use this serial instead of generating one
+
+	// Clock supplies the current time; nil selects SystemClock.
+	Clock Clock
+
+	// Test support: when set, Rand replaces crypto/rand.
+	Rand io.Reader
+}
+
+// CrossSign certifies another CA's key under c: the result carries target's
+// subject, public key, subject key identifier and constraints, with c as the
+// issuer. Certificates issued by the target then chain to either the
+// target's own root or to c's, which lets relying parties migrate between
+// roots without a flag day.
+func (c *CA) CrossSign(target *x509.Certificate, opts CrossSignOptions) (*x509.Certificate, error) {
+	if !target.IsCA || !target.BasicConstraintsValid {
+		return nil, fmt.Errorf("%s is not a CA certificate", target.Subject)
+	}
+	if bytes.Equal(target.RawSubjectPublicKeyInfo, c.Certificate.RawSubjectPublicKeyInfo) {
+		return nil, fmt.Errorf("%s has the signing CA's own key; use Renew instead", target.Subject)
+	}
+
+	template, err := renewalTemplate(target, RenewOptions{
+		ValidityDays: opts.ValidityDays,
+		SerialBits:   opts.SerialBits,
+		SerialNumber: opts.SerialNumber,
+		Clock:        opts.Clock,
+		Rand:         opts.Rand,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if opts.ValidityDays == 0 {
+		template.NotAfter = target.NotAfter
+	}
+	if template.NotAfter.After(c.Certificate.NotAfter) {
+		template.NotAfter = c.Certificate.NotAfter
+	}
+	if !template.NotAfter.After(template.NotBefore) {
+		return nil, fmt.Errorf("%s expired at %s", target.Subject, target.NotAfter)
+	}
+	if opts.MaxPathLen != nil {
+		template.MaxPathLen = *opts.MaxPathLen
+		template.MaxPathLenZero = *opts.MaxPathLen == 0
+	}
+	maxPathLen := template.MaxPathLen
+	if maxPathLen == 0 && !template.MaxPathLenZero {
+		maxPathLen = -1
+	}
+	if err := c.checkPathLen(maxPathLen); err != nil {
+		return nil, err
+	}
+	// x509.CreateCertificate only takes the authority key identifier from
+	// the issuer when the subjects differ, and a root cross-signed by its
+	// predecessor often has the same subject.
+	template.AuthorityKeyId = c.Certificate.SubjectKeyId
+
+	return c.sign(randomOrDefault(opts.Rand), template, target.PublicKey)
+}
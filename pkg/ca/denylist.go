@@ -0,0 +1,183 @@
+// denylist.go
+package ca
+
+import (
+	"bufio"
+	"crypto"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ErrDeniedKey is returned when asked to certify a key on the denylist.
+var ErrDeniedKey = errors.New("public key is on the key denylist")
+
+// KeyDenylist is a set of public keys that must never be certified, such as
+// known weak or previously compromised keys. Keys are identified by their
+// SPKI pin (see SPKIPin). A nil *KeyDenylist denies nothing.
+//
+// The file format has one key per line: the base64 pin, optionally prefixed
+// with "sha256/", or the same hash as 64 hex digits, followed by an optional
+// "# comment". Blank lines and lines starting with '#' are ignored.
+type KeyDenylist struct {
+	entries map[string]string // pin -> comment
+}
+
+// NewKeyDenylist returns an empty denylist.
+func NewKeyDenylist() *KeyDenylist {
+	return &KeyDenylist{entries: map[string]string{}}
+}
+
+// LoadKeyDenylist reads a denylist file. A missing file yields an empty list.
+func LoadKeyDenylist(path string) (*KeyDenylist, error) {
+	d := NewKeyDenylist()
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key denylist %q: %w", path, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		value, comment, _ := strings.Cut(text, "#")
+		pin, err := NormalizePin(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("key denylist %q line %d: %w", path, line, err)
+		}
+		d.entries[pin] = strings.TrimSpace(comment)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read key denylist %q: %w", path, err)
+	}
+	return d, nil
+}
+
+// NormalizePin converts an SPKI SHA-256 hash, in base64 with an optional
+// "sha256/" prefix or in hex, to the base64 form SPKIPin returns.
+func NormalizePin(value string) (string, error) {
+	value = strings.TrimPrefix(value, "sha256/")
+	if len(value) == 64 {
+		if sum, err := hex.DecodeString(value); err == nil {
+			return base64.StdEncoding.EncodeToString(sum), nil
+		}
+	}
+	sum, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(sum) != 32 {
+		return "", fmt.Errorf("%q is not a base64 or hex SHA-256 SPKI hash", value)
+	}
+	return value, nil
+}
+
+// Add puts pub on the denylist and returns its pin.
+func (d *KeyDenylist) Add(pub crypto.PublicKey, comment string) (string, error) {
+	pin, err := SPKIPin(pub)
+	if err != nil {
+		return "", err
+	}
+	d.entries[pin] = comment
+	return pin, nil
+}
+
+// Check returns an error wrapping ErrDeniedKey if pub is on the denylist.
+func (d *KeyDenylist) Check(pub crypto.PublicKey) error {
+	if d == nil || len(d.entries) == 0 {
+		return nil
+	}
+	pin, err := SPKIPin(pub)
+	if err != nil {
+		return err
+	}
+	comment, denied := d.entries[pin]
+	if !denied {
+		return nil
+	}
+	if comment != "" {
+		return fmt.Errorf("%w (sha256/%s: %s)", ErrDeniedKey, pin, comment)
+	}
+	return fmt.Errorf("%w (sha256/%s)", ErrDeniedKey, pin)
+}
+
+// Len returns the number of denied keys.
+func (d *KeyDenylist) Len() int {
+	if d == nil {
+		return 0
+	}
+	return len(d.entries)
+}
+
+// Pins returns the denied pins in sorted order.
+func (d *KeyDenylist) Pins() []string {
+	if d == nil {
+		return nil
+	}
+	pins := make([]string, 0, len(d.entries))
+	for pin := range d.entries {
+		pins = append(pins, pin)
+	}
+	sort.Strings(pins)
+	return pins
+}
+
+// Comment returns the comment recorded for pin.
+func (d *KeyDenylist) Comment(pin string) string {
+	if d == nil {
+		return ""
+	}
+	return d.entries[pin]
+}
+
+// AppendKeyDenylist adds pub to the denylist file at path, creating it if
+// needed, and returns its pin. Existing entries and comments are preserved.
+func AppendKeyDenylist(path string, pub crypto.PublicKey, comment string) (string, error) {
+	existing, err := LoadKeyDenylist(path)
+	if err != nil {
+		return "", err
+	}
+	pin, err := SPKIPin(pub)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := existing.entries[pin]; ok {
+		return pin, nil
+	}
+
+	line := "sha256/" + pin
+	if comment = strings.ReplaceAll(comment, "\n", " "); comment != "" {
+		line += " # " + comment
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return "", fmt.Errorf("failed to open key denylist %q: %w", path, err)
+	}
+	// A hand-edited file may lack the final newline, which would glue the
+	// new entry onto the last one.
+	if info, err := file.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, info.Size()-1); err != nil {
+			file.Close()
+			return "", fmt.Errorf("failed to read key denylist %q: %w", path, err)
+		}
+		if last[0] != '\n' {
+			line = "\n" + line
+		}
+	}
+	if _, err := fmt.Fprintln(file, line); err != nil {
+		file.Close()
+		return "", fmt.Errorf("failed to append to key denylist %q: %w", path, err)
+	}
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("failed to append to key denylist %q: %w", path, err)
+	}
+	return pin, nil
+}
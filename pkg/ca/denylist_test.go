@@ -0,0 +1,73 @@
+// denylist_test.go
+package ca
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAppendKeyDenylist(t *testing.T) {
+	newKey := func() ed25519.PublicKey {
+		pub, _, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pub
+	}
+	first, second := newKey(), newKey()
+	firstPin, err := SPKIPin(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		existing string
+	}{
+		{"new file", ""},
+		{"trailing newline", "sha256/" + firstPin + " # hand-edited\n"},
+		{"no trailing newline", "sha256/" + firstPin + " # hand-edited"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "key-denylist.txt")
+			if tt.existing != "" {
+				if err := os.WriteFile(path, []byte(tt.existing), 0644); err != nil {
+					t.Fatal(err)
+				}
+			} else if _, err := AppendKeyDenylist(path, first, "first"); err != nil {
+				t.Fatalf("AppendKeyDenylist: %v", err)
+			}
+			if _, err := AppendKeyDenylist(path, second, "second"); err != nil {
+				t.Fatalf("AppendKeyDenylist: %v", err)
+			}
+
+			list, err := LoadKeyDenylist(path)
+			if err != nil {
+				t.Fatalf("LoadKeyDenylist: %v", err)
+			}
+			if got := len(list.Pins()); got != 2 {
+				t.Errorf("denylist has %d entries, want 2: %q", got, list.Pins())
+			}
+			for _, pub := range []ed25519.PublicKey{first, second} {
+				if err := list.Check(pub); err == nil {
+					t.Errorf("key %x is not denied", pub)
+				}
+			}
+
+			// Appending a listed key again changes nothing.
+			before, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := AppendKeyDenylist(path, second, "again"); err != nil {
+				t.Fatalf("AppendKeyDenylist: %v", err)
+			}
+			if after, err := os.ReadFile(path); err != nil || string(after) != string(before) {
+				t.Errorf("appending a listed key changed the file to %q", after)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+// Package ca implements a small X.509 certificate authority: creating root and
+// intermediate CAs, issuing end-entity certificates, and signing PKCS#10
+// requests. It is the library behind the go-CA command-line tool.
+//
+// A typical embedding creates or loads a CA once and issues from it:
+//
+//	root, err := ca.NewRootCA(ca.Config{
+//		CommonName:   "Example Root CA",
+//		ValidityDays: 3650,
+//		KeyType:      ca.KeyTypeECDSAP256,
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	cert, key, err := root.Issue(ca.IssueOptions{
+//		CommonName:   "app.internal",
+//		DNSNames:     []string{"app.internal"},
+//		ValidityDays: 90,
+//		KeyType:      ca.KeyTypeECDSAP256,
+//		ExtKeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+//	})
+//
+// Setting IssueOptions.Profile to a profile from LookupProfile, such as
+// "server" or "email", fixes the key usages and EKUs and enforces the
+// profile's validity cap and SAN types.
+//
+// LoadCA reads an existing CA from PEM files, and MarshalPrivateKeyPEM encodes
+// keys for storage, optionally encrypted with a passphrase.
+package ca
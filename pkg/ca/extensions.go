@@ -0,0 +1,104 @@
+// extensions.go
+package ca
+
+import (
+	"crypto"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+)
+
+// Subject key identifier methods.
+const (
+	SKIDMethodSHA1   = "sha1"   // RFC 5280, section 4.2.1.2, method (1)
+	SKIDMethodSHA256 = "sha256" // RFC 7093, section 2, method (1): truncated SHA-256
+)
+
+var (
+	oidExtensionKeyUsage          = asn1.ObjectIdentifier{2, 5, 29, 15}
+	oidExtensionPolicyConstraints = asn1.ObjectIdentifier{2, 5, 29, 36}
+	oidExtensionInhibitAnyPolicy  = asn1.ObjectIdentifier{2, 5, 29, 54}
+)
+
+// policyConstraints mirrors the PolicyConstraints ASN.1 structure from
+// RFC 5280, section 4.2.1.11. A value of -1 marks a field as absent.
+type policyConstraints struct {
+	RequireExplicitPolicy int `asn1:"optional,tag:0,default:-1"`
+	InhibitPolicyMapping  int `asn1:"optional,tag:1,default:-1"`
+}
+
+// hasExtension reports whether extensions contains the given OID.
+func hasExtension(extensions []pkix.Extension, oid asn1.ObjectIdentifier) bool {
+	for _, ext := range extensions {
+		if ext.Id.Equal(oid) {
+			return true
+		}
+	}
+	return false
+}
+
+// policyConstraintExtensions builds the policyConstraints and inhibitAnyPolicy
+// extensions requested by the config. Both are marked critical, as RFC 5280
+// requires for conforming CAs.
+func policyConstraintExtensions(config Config) ([]pkix.Extension, error) {
+	var extensions []pkix.Extension
+
+	if config.RequireExplicitPolicy != nil || config.InhibitPolicyMapping != nil {
+		constraints := policyConstraints{RequireExplicitPolicy: -1, InhibitPolicyMapping: -1}
+		if config.RequireExplicitPolicy != nil {
+			constraints.RequireExplicitPolicy = *config.RequireExplicitPolicy
+		}
+		if config.InhibitPolicyMapping != nil {
+			constraints.InhibitPolicyMapping = *config.InhibitPolicyMapping
+		}
+		value, err := asn1.Marshal(constraints)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode policy constraints: %w", err)
+		}
+		extensions = append(extensions, pkix.Extension{Id: oidExtensionPolicyConstraints, Critical: true, Value: value})
+	}
+
+	if config.InhibitAnyPolicy != nil {
+		value, err := asn1.Marshal(*config.InhibitAnyPolicy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode inhibitAnyPolicy: %w", err)
+		}
+		extensions = append(extensions, pkix.Extension{Id: oidExtensionInhibitAnyPolicy, Critical: true, Value: value})
+	}
+
+	return extensions, nil
+}
+
+// subjectPublicKeyInfo is used to extract the subjectPublicKey BIT STRING, which
+// is what key identifiers are computed over.
+type subjectPublicKeyInfo struct {
+	Algorithm        pkix.AlgorithmIdentifier
+	SubjectPublicKey asn1.BitString
+}
+
+// computeSubjectKeyID derives a key identifier for pub using the given method.
+// An empty method selects SHA-1, matching what most tooling emits.
+func computeSubjectKeyID(pub crypto.PublicKey, method string) ([]byte, error) {
+	spkiDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal public key: %w", err)
+	}
+	var spki subjectPublicKeyInfo
+	if _, err := asn1.Unmarshal(spkiDER, &spki); err != nil {
+		return nil, fmt.Errorf("failed to parse public key info: %w", err)
+	}
+
+	switch method {
+	case "", SKIDMethodSHA1:
+		sum := sha1.Sum(spki.SubjectPublicKey.Bytes)
+		return sum[:], nil
+	case SKIDMethodSHA256:
+		sum := sha256.Sum256(spki.SubjectPublicKey.Bytes)
+		return sum[:20], nil // Leftmost 160 bits
+	default:
+		return nil, fmt.Errorf("unsupported subject key identifier method %q (use %q or %q)", method, SKIDMethodSHA1, SKIDMethodSHA256)
+	}
+}
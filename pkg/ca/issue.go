@@ -0,0 +1,377 @@
+// issue.go
+package ca
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/url"
+	"time"
+)
+
+// IssueOptions holds the parameters for an end-entity certificate or CSR.
+type IssueOptions struct {
+	CommonName   string
+	Organization string
+	Subject      SubjectAttributes // Optional further subject attributes
+	ValidityDays int
+	NotBefore    time.Time // Optional: defaults to the current time
+	NotAfter     time.Time // Optional: defaults to NotBefore + ValidityDays
+	KeyType      string    // One of the KeyType* constants; empty selects RSA
+	KeyBitSize   int       // RSA key size; ignored for other key types
+	SerialBits   int       // Serial number length in bits (64-160); 0 selects the default
+	SerialNumber *big.Int  // Optional: use this serial instead of generating one; SerialBits is ignored
+	SKIDMethod   string    // Subject key identifier method: "sha1" (default) or "sha256"
+
+	// Key, when set, is reused by Issue instead of generating a new key pair,
+	// so successive certificates keep the same SPKI (see SPKIPin). KeyType and
+	// KeyBitSize are ignored.
+	Key crypto.Signer
+
+	// Subject Alternative Names.
+	DNSNames       []string
+	IPAddresses    []net.IP
+	EmailAddresses []string
+	URIs           []*url.URL
+
+	// ExtKeyUsages lists the extended key usages, e.g. x509.ExtKeyUsageServerAuth.
+	// Leaving it empty omits the extension, which permits any usage.
+	ExtKeyUsages []x509.ExtKeyUsage
+
+	// Profile, when set, supplies the key usage and EKUs, overriding
+	// ExtKeyUsages, and its limits are checked before anything is signed.
+	Profile *Profile
+
+	// SANPolicy is checked against the final SANs before anything is signed.
+	// The zero value enables no rules.
+	SANPolicy SANPolicy
+
+	// Denylist, if set, lists public keys that must never be certified.
+	Denylist *KeyDenylist
+
+	// Clock supplies the current time; nil selects SystemClock.
+	Clock Clock
+
+	// Test support: when set, Rand replaces crypto/rand so that, together
+	// with a fixed Clock, identical inputs produce byte-identical certificates.
+	Rand io.Reader
+}
+
+// ValidityWindow resolves the certificate validity period. Absolute NotBefore and
+// NotAfter values take precedence; otherwise the window starts at now and lasts
+// ValidityDays.
+func (o IssueOptions) ValidityWindow(now time.Time) (notBefore, notAfter time.Time) {
+	return validityWindow(o.NotBefore, o.NotAfter, o.ValidityDays, now)
+}
+
+// HasSANs reports whether opts requests any Subject Alternative Name.
+func (o IssueOptions) HasSANs() bool {
+	return len(o.DNSNames) > 0 || len(o.IPAddresses) > 0 ||
+		len(o.EmailAddresses) > 0 || len(o.URIs) > 0
+}
+
+// Issue creates an end-entity certificate signed by c, for a new key pair or
+// for opts.Key if set. The certificate's key is returned alongside it.
+func (c *CA) Issue(opts IssueOptions) (*x509.Certificate, crypto.Signer, error) {
+	template, privateKey, err := c.prepareIssue(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cert, err := c.sign(randomOrDefault(opts.Rand), template, privateKey.Public())
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, privateKey, nil
+}
+
+// Simulate runs every check Issue performs and returns the certificate Issue
+// would sign for opts, without signing it or assigning a serial number, so
+// automation can be validated in advance. Only c.Certificate is used; the CA
+// key is not needed. When opts.Key is nil a throwaway key of the requested
+// type stands in for the one Issue would generate, so the public key and
+// subject key identifier are placeholders.
+func (c *CA) Simulate(opts IssueOptions) (*x509.Certificate, error) {
+	template, privateKey, err := c.prepareIssue(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in what x509.CreateCertificate would take from its arguments.
+	template.PublicKey = privateKey.Public()
+	template.SerialNumber = nil
+	template.Version = 3
+	template.Issuer = c.Certificate.Subject
+	template.RawIssuer = c.Certificate.RawSubject
+	if template.RawSubject, err = asn1.Marshal(template.Subject.ToRDNSequence()); err != nil {
+		return nil, fmt.Errorf("failed to encode subject: %w", err)
+	}
+	template.AuthorityKeyId = c.Certificate.SubjectKeyId
+	template.SignatureAlgorithm = signatureAlgorithmFor(c.Certificate.PublicKey)
+	return template, nil
+}
+
+// prepareIssue checks opts and returns the template and key Issue signs.
+func (c *CA) prepareIssue(opts IssueOptions) (*x509.Certificate, crypto.Signer, error) {
+	if err := opts.SANPolicy.Validate(opts); err != nil {
+		return nil, nil, fmt.Errorf("rejected by SAN policy:\n%w", err)
+	}
+	if err := opts.Profile.Check(opts); err != nil {
+		return nil, nil, err
+	}
+	if err := CertificateNameConstraints(c.Certificate).Check(opts); err != nil {
+		return nil, nil, fmt.Errorf("outside the issuing CA's name constraints:\n%w", err)
+	}
+
+	privateKey := opts.Key
+	if privateKey == nil {
+		var err error
+		privateKey, err = GenerateKey(randomOrDefault(opts.Rand), opts.KeyType, opts.KeyBitSize)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to generate private key: %w", err)
+		}
+	} else if publicKeysEqual(privateKey.Public(), c.Certificate.PublicKey) {
+		return nil, nil, fmt.Errorf("refusing to issue a certificate for the issuing CA's own key")
+	}
+	if err := opts.Denylist.Check(privateKey.Public()); err != nil {
+		return nil, nil, err
+	}
+
+	template, err := buildLeafTemplate(opts, privateKey.Public())
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := c.checkNotAfter(template); err != nil {
+		return nil, nil, err
+	}
+	return template, privateKey, nil
+}
+
+// checkNotAfter refuses a certificate that would outlive c: relying parties
+// reject it as soon as c expires, whatever its own validity says.
+func (c *CA) checkNotAfter(template *x509.Certificate) error {
+	if !template.NotAfter.After(c.Certificate.NotAfter) {
+		return nil
+	}
+	days := int(c.Certificate.NotAfter.Sub(template.NotBefore).Hours() / 24)
+	return fmt.Errorf("certificate would be valid until %s, after the issuing CA %s expires at %s; request at most %d days",
+		template.NotAfter.UTC().Format(time.RFC3339), c.Certificate.Subject, c.Certificate.NotAfter.UTC().Format(time.RFC3339), days)
+}
+
+// SignCSR issues a certificate for csr signed by c. The CSR's subject and
+// SANs are copied unless opts overrides them: a non-empty CommonName,
+// Organization or Subject attribute replaces that attribute, Subject's
+// ExtraNames are added, and each non-empty SAN slice replaces the CSR's SANs
+// of that type. Extensions requested in the CSR are not
+// copied; key usage and EKUs are set by the CA.
+func (c *CA) SignCSR(csr *x509.CertificateRequest, opts IssueOptions) (*x509.Certificate, error) {
+	if err := csr.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("CSR signature is invalid: %w", err)
+	}
+	if err := opts.Denylist.Check(csr.PublicKey); err != nil {
+		return nil, fmt.Errorf("CSR rejected: %w", err)
+	}
+
+	opts = mergeCSRSANs(csr, opts)
+	if err := opts.SANPolicy.Validate(opts); err != nil {
+		return nil, fmt.Errorf("CSR rejected by SAN policy:\n%w", err)
+	}
+	if err := opts.Profile.Check(opts); err != nil {
+		return nil, fmt.Errorf("CSR %w", err)
+	}
+	if err := CertificateNameConstraints(c.Certificate).Check(opts); err != nil {
+		return nil, fmt.Errorf("CSR is outside the issuing CA's name constraints:\n%w", err)
+	}
+	template, err := buildLeafTemplate(opts, csr.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkNotAfter(template); err != nil {
+		return nil, err
+	}
+
+	template.Subject = csrSubject(csr)
+	if opts.CommonName != "" {
+		template.Subject.CommonName = opts.CommonName
+	}
+	if opts.Organization != "" {
+		template.Subject.Organization = []string{opts.Organization}
+	}
+	opts.Subject.applyTo(&template.Subject)
+	template.Subject = separateRDNs(template.Subject)
+	if template.Subject.CommonName == "" && len(opts.DNSNames) == 0 {
+		return nil, fmt.Errorf("CSR has neither a Common Name nor DNS names; a CommonName or DNSNames override is required")
+	}
+
+	if bytes.Equal(template.SubjectKeyId, c.Certificate.SubjectKeyId) {
+		return nil, fmt.Errorf("CSR public key is the issuing CA's own key")
+	}
+
+	return c.sign(randomOrDefault(opts.Rand), template, csr.PublicKey)
+}
+
+// sign creates and parses the certificate described by template.
+func (c *CA) sign(random io.Reader, template *x509.Certificate, pub crypto.PublicKey) (*x509.Certificate, error) {
+	certBytes, err := x509.CreateCertificate(random, template, c.Certificate, pub, c.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(certBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse issued certificate: %w", err)
+	}
+	return cert, nil
+}
+
+// mergeCSRSANs returns opts with each empty SAN slice filled in from csr,
+// i.e. the SANs SignCSR will actually put in the certificate.
+func mergeCSRSANs(csr *x509.CertificateRequest, opts IssueOptions) IssueOptions {
+	if len(opts.DNSNames) == 0 {
+		opts.DNSNames = csr.DNSNames
+	}
+	if len(opts.IPAddresses) == 0 {
+		opts.IPAddresses = csr.IPAddresses
+	}
+	if len(opts.EmailAddresses) == 0 {
+		opts.EmailAddresses = csr.EmailAddresses
+	}
+	if len(opts.URIs) == 0 {
+		opts.URIs = csr.URIs
+	}
+	return opts
+}
+
+// buildLeafTemplate assembles an end-entity certificate template for pub.
+func buildLeafTemplate(opts IssueOptions, pub crypto.PublicKey) (*x509.Certificate, error) {
+	serialNumber, err := serialOrGenerate(opts.SerialNumber, randomOrDefault(opts.Rand), opts.SerialBits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+	subjectKeyID, err := computeSubjectKeyID(pub, opts.SKIDMethod)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute subject key identifier: %w", err)
+	}
+
+	notBefore, notAfter := opts.ValidityWindow(clockOrDefault(opts.Clock).Now())
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject:      leafSubject(opts),
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+
+		DNSNames:       opts.DNSNames,
+		IPAddresses:    opts.IPAddresses,
+		EmailAddresses: opts.EmailAddresses,
+		URIs:           opts.URIs,
+
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           opts.ExtKeyUsages,
+		BasicConstraintsValid: true,
+		IsCA:                  false,
+
+		SubjectKeyId: subjectKeyID,
+	}
+	// RSA key exchange (TLS 1.2 and earlier) encrypts to the certificate key.
+	_, isRSA := pub.(*rsa.PublicKey)
+	if p := opts.Profile; p != nil {
+		template.KeyUsage = p.KeyUsage
+		template.ExtKeyUsage = p.ExtKeyUsages
+		if p.KeyEncipherment && isRSA {
+			template.KeyUsage |= x509.KeyUsageKeyEncipherment
+		}
+	} else if isRSA {
+		template.KeyUsage |= x509.KeyUsageKeyEncipherment
+	}
+	return template, nil
+}
+
+// leafSubject builds the subject name for an end-entity certificate or CSR.
+func leafSubject(opts IssueOptions) pkix.Name {
+	return subjectName(opts.CommonName, opts.Organization, opts.Subject)
+}
+
+// NewCSR creates a new key pair and a DER PKCS#10 request for the subject and
+// SANs in opts.
+func NewCSR(opts IssueOptions) (csrBytes []byte, key crypto.Signer, err error) {
+	if err := opts.SANPolicy.Validate(opts); err != nil {
+		return nil, nil, fmt.Errorf("rejected by SAN policy:\n%w", err)
+	}
+
+	random := randomOrDefault(opts.Rand)
+	privateKey, err := GenerateKey(random, opts.KeyType, opts.KeyBitSize)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate private key: %w", err)
+	}
+
+	template := &x509.CertificateRequest{
+		Subject:        leafSubject(opts),
+		DNSNames:       opts.DNSNames,
+		IPAddresses:    opts.IPAddresses,
+		EmailAddresses: opts.EmailAddresses,
+		URIs:           opts.URIs,
+	}
+
+	csrBytes, err = x509.CreateCertificateRequest(random, template, privateKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create certificate request: %w", err)
+	}
+	return csrBytes, privateKey, nil
+}
+
+// SelfSignCSR creates a DER certificate for the CSR's subject and requested
+// extensions, signed by the CSR's own key. The validity starts at the time
+// reported by clock, or the current time if clock is nil. The serial number
+// and any randomized signature draw from random, or crypto/rand if it is nil.
+func SelfSignCSR(csr *x509.CertificateRequest, key crypto.Signer, validityDays int, clock Clock, random io.Reader) ([]byte, error) {
+	// The key must be the one the CSR was made with, or the result would be a
+	// certificate whose signature cannot be verified with its own public key.
+	if !publicKeysEqual(key.Public(), csr.PublicKey) {
+		return nil, fmt.Errorf("private key does not match the CSR's public key")
+	}
+
+	random = randomOrDefault(random)
+	serialNumber, err := GenerateSerialNumber(random, DefaultSerialBits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+	subjectKeyID, err := computeSubjectKeyID(csr.PublicKey, SKIDMethodSHA1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute subject key identifier: %w", err)
+	}
+
+	notBefore := clockOrDefault(clock).Now()
+	template := x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject:      csr.Subject,
+		NotBefore:    notBefore,
+		NotAfter:     notBefore.AddDate(0, 0, validityDays),
+
+		DNSNames:       csr.DNSNames,
+		IPAddresses:    csr.IPAddresses,
+		EmailAddresses: csr.EmailAddresses,
+		URIs:           csr.URIs,
+
+		SubjectKeyId:   subjectKeyID,
+		AuthorityKeyId: subjectKeyID,
+
+		// Requested extensions (key usage, EKUs, basic constraints, ...) are
+		// copied verbatim and take precedence over the template fields.
+		ExtraExtensions: csr.Extensions,
+	}
+
+	if !hasExtension(csr.Extensions, oidExtensionKeyUsage) {
+		template.KeyUsage = x509.KeyUsageDigitalSignature
+		if _, isRSA := key.Public().(*rsa.PublicKey); isRSA {
+			template.KeyUsage |= x509.KeyUsageKeyEncipherment
+		}
+	}
+
+	return x509.CreateCertificate(random, &template, &template, csr.PublicKey, key)
+}
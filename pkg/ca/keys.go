@@ -0,0 +1,115 @@
+// keys.go
+package ca
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io"
+)
+
+// Supported key types for Config.KeyType and IssueOptions.KeyType.
+const (
+	KeyTypeRSA       = "rsa"
+	KeyTypeECDSAP256 = "ecdsa-p256"
+	KeyTypeECDSAP384 = "ecdsa-p384"
+	KeyTypeECDSAP521 = "ecdsa-p521"
+	KeyTypeEd25519   = "ed25519"
+)
+
+// SupportedKeyTypes lists the key types in the order they are documented.
+var SupportedKeyTypes = []string{KeyTypeRSA, KeyTypeECDSAP256, KeyTypeECDSAP384, KeyTypeECDSAP521, KeyTypeEd25519}
+
+// ecdsaCurves maps ECDSA key types to their curves.
+var ecdsaCurves = map[string]elliptic.Curve{
+	KeyTypeECDSAP256: elliptic.P256(),
+	KeyTypeECDSAP384: elliptic.P384(),
+	KeyTypeECDSAP521: elliptic.P521(),
+}
+
+// IsValidKeyType reports whether keyType is one of the supported key types.
+// An empty key type is treated as RSA.
+func IsValidKeyType(keyType string) bool {
+	if keyType == "" {
+		return true
+	}
+	for _, t := range SupportedKeyTypes {
+		if t == keyType {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateKey creates a new private key of the given type from random. rsaBits
+// is only used for RSA keys; ECDSA and Ed25519 key sizes are fixed by the
+// algorithm.
+func GenerateKey(random io.Reader, keyType string, rsaBits int) (crypto.Signer, error) {
+	switch keyType {
+	case "", KeyTypeRSA:
+		return rsa.GenerateKey(random, rsaBits)
+	case KeyTypeEd25519:
+		// Derive the key from an explicit seed so a deterministic random
+		// source yields a deterministic key.
+		seed := make([]byte, ed25519.SeedSize)
+		if _, err := io.ReadFull(random, seed); err != nil {
+			return nil, err
+		}
+		return ed25519.NewKeyFromSeed(seed), nil
+	}
+	if curve, ok := ecdsaCurves[keyType]; ok {
+		return ecdsa.GenerateKey(curve, random)
+	}
+	return nil, fmt.Errorf("unsupported key type %q", keyType)
+}
+
+// DescribeKeyType renders a key type for display, including the RSA size.
+func DescribeKeyType(keyType string, rsaBits int) string {
+	switch keyType {
+	case "", KeyTypeRSA:
+		return fmt.Sprintf("RSA %d bits", rsaBits)
+	case KeyTypeEd25519:
+		return "Ed25519"
+	}
+	return fmt.Sprintf("ECDSA %s", ecdsaCurves[keyType].Params().Name)
+}
+
+// SPKIPin returns the base64-encoded SHA-256 hash of the DER-encoded
+// SubjectPublicKeyInfo of pub: the pin format used by HPKP (RFC 7469) and by
+// the certificate pinning APIs of Android and iOS networking libraries.
+func SPKIPin(pub crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal public key: %w", err)
+	}
+	sum := sha256.Sum256(der)
+	return base64.StdEncoding.EncodeToString(sum[:]), nil
+}
+
+// signatureAlgorithmFor returns the signature algorithm crypto/x509 selects
+// by default for a signer with public key pub.
+func signatureAlgorithmFor(pub crypto.PublicKey) x509.SignatureAlgorithm {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		return x509.SHA256WithRSA
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P384():
+			return x509.ECDSAWithSHA384
+		case elliptic.P521():
+			return x509.ECDSAWithSHA512
+		default:
+			return x509.ECDSAWithSHA256
+		}
+	case ed25519.PublicKey:
+		return x509.PureEd25519
+	default:
+		return x509.UnknownSignatureAlgorithm
+	}
+}
@@ -0,0 +1,212 @@
+// pemfile.go
+package ca
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrNoCertificates is returned when a file contains no CERTIFICATE blocks.
+var ErrNoCertificates = errors.New("no PEM certificates found")
+
+// LoadCertificates reads every CERTIFICATE block from a PEM file, in file order.
+// Non-certificate blocks (e.g. keys accidentally concatenated into a bundle)
+// are skipped.
+func LoadCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q: %w", path, err)
+	}
+
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate #%d in %q: %w", len(certs)+1, path, err)
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%w in %q", ErrNoCertificates, path)
+	}
+	return certs, nil
+}
+
+// LoadPrivateKey reads the first private key from a PEM file. PKCS#8, PKCS#1
+// (RSA) and SEC 1 (EC) encodings are accepted, since CSRs brought to this tool
+// are often produced by OpenSSL or other software. Encrypted PKCS#8 keys are
+// decrypted with the passphrase from passphrase, which may be nil if the key
+// is not expected to be encrypted.
+func LoadPrivateKey(path string, passphrase PassphraseFunc) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q: %w", path, err)
+	}
+
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("no PEM private key found in %q", path)
+		}
+
+		var key any
+		switch block.Type {
+		case "PRIVATE KEY":
+			key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		case "RSA PRIVATE KEY":
+			key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			key, err = x509.ParseECPrivateKey(block.Bytes)
+		case "ENCRYPTED PRIVATE KEY":
+			if passphrase == nil {
+				return nil, fmt.Errorf("private key %q is encrypted", path)
+			}
+			secret, perr := passphrase()
+			if perr != nil {
+				return nil, fmt.Errorf("failed to decrypt private key %q: %w", path, perr)
+			}
+			der, derr := decryptPKCS8(block.Bytes, secret)
+			if derr != nil {
+				return nil, fmt.Errorf("failed to decrypt private key %q: %w", path, derr)
+			}
+			key, err = x509.ParsePKCS8PrivateKey(der)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key in %q: %w", path, err)
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T in %q", key, path)
+		}
+		return signer, nil
+	}
+}
+
+// LoadCertificateRequest reads a PKCS#10 CSR from a file and verifies its
+// self-signature. PEM files with the standard or the legacy "NEW" block type
+// are accepted, as are the other forms Windows certreq writes: DER (-binary)
+// and base64 without PEM headers.
+func LoadCertificateRequest(path string) (*x509.CertificateRequest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q: %w", path, err)
+	}
+	csr, err := ParseCertificateRequest(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w in %q", err, path)
+	}
+	return csr, nil
+}
+
+// ParseCertificateRequest parses and verifies a CSR in any of the forms
+// LoadCertificateRequest accepts.
+func ParseCertificateRequest(data []byte) (*x509.CertificateRequest, error) {
+	der := certificateRequestDER(data)
+	if der == nil {
+		return nil, fmt.Errorf("no certificate request found")
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate request: %w", err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("certificate request signature is invalid: %w", err)
+	}
+	return csr, nil
+}
+
+// certificateRequestDER extracts the DER request from the contents of a
+// request file, or returns nil if it holds none.
+func certificateRequestDER(data []byte) []byte {
+	for rest := data; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE REQUEST" || block.Type == "NEW CERTIFICATE REQUEST" {
+			return block.Bytes
+		}
+	}
+	// A DER request is a SEQUENCE; anything else is tried as bare base64.
+	if len(data) > 0 && data[0] == 0x30 {
+		return data
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(data)), ""))
+	if err != nil || len(decoded) == 0 || decoded[0] != 0x30 {
+		return nil
+	}
+	return decoded
+}
+
+// LoadCA loads a CA certificate and its private key for signing, checking
+// that the certificate is a CA and that the key belongs to it.
+func LoadCA(certPath, keyPath string, passphrase PassphraseFunc) (*CA, error) {
+	certs, err := LoadCertificates(certPath)
+	if err != nil {
+		return nil, err
+	}
+	cert := certs[0]
+
+	key, err := LoadPrivateKey(keyPath, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	if !cert.IsCA {
+		return nil, fmt.Errorf("certificate %q (%s) is not a CA certificate", certPath, cert.Subject)
+	}
+	if !publicKeysEqual(key.Public(), cert.PublicKey) {
+		return nil, fmt.Errorf("private key %q does not match CA certificate %q", keyPath, certPath)
+	}
+	return &CA{Certificate: cert, Key: key}, nil
+}
+
+// LoadKeyPair loads a certificate chain and its private key for use with TLS,
+// like tls.LoadX509KeyPair but accepting encrypted keys.
+func LoadKeyPair(certPath, keyPath string, passphrase PassphraseFunc) (tls.Certificate, error) {
+	certs, err := LoadCertificates(certPath)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	key, err := LoadPrivateKey(keyPath, passphrase)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if !publicKeysEqual(key.Public(), certs[0].PublicKey) {
+		return tls.Certificate{}, fmt.Errorf("private key %q does not match certificate %q", keyPath, certPath)
+	}
+
+	pair := tls.Certificate{PrivateKey: key, Leaf: certs[0]}
+	for _, cert := range certs {
+		pair.Certificate = append(pair.Certificate, cert.Raw)
+	}
+	return pair, nil
+}
+
+// publicKeysEqual reports whether two public keys are identical. All key types
+// in the standard library implement Equal.
+func publicKeysEqual(a, b crypto.PublicKey) bool {
+	type equaler interface{ Equal(crypto.PublicKey) bool }
+	key, ok := a.(equaler)
+	return ok && key.Equal(b)
+}
@@ -0,0 +1,138 @@
+// profile.go
+package ca
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Profile describes the shape of a class of end-entity certificates: which
+// key usages and EKUs they carry, how long they may be valid and which SANs
+// they may contain. Setting IssueOptions.Profile makes Issue, Simulate and
+// SignCSR produce certificates of that shape and reject requests that do not
+// fit it.
+type Profile struct {
+	Name        string
+	Description string
+
+	KeyUsage x509.KeyUsage
+	// KeyEncipherment adds x509.KeyUsageKeyEncipherment for RSA keys, which
+	// RSA key exchange and S/MIME encryption need.
+	KeyEncipherment bool
+	ExtKeyUsages    []x509.ExtKeyUsage
+
+	MaxValidityDays int       // 0 means no cap
+	SANTypes        []SANType // SAN types allowed; others are rejected
+	RequiredSAN     SANType   // Optional: at least one SAN of this type is required
+}
+
+var (
+	profiles     = map[string]*Profile{}
+	profileOrder []string // Registration order, for stable output
+)
+
+// RegisterProfile makes profile available to LookupProfile. Registering the
+// same name twice is a programming error.
+func RegisterProfile(profile *Profile) {
+	if _, dup := profiles[profile.Name]; dup {
+		panic("duplicate profile " + profile.Name)
+	}
+	profiles[profile.Name] = profile
+	profileOrder = append(profileOrder, profile.Name)
+}
+
+func init() {
+	RegisterProfile(&Profile{
+		Name:            "server",
+		Description:     "TLS server; at most 398 days, as browsers require",
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		KeyEncipherment: true,
+		ExtKeyUsages:    []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		MaxValidityDays: 398,
+		SANTypes:        []SANType{SANTypeDNS, SANTypeIP},
+	})
+	RegisterProfile(&Profile{
+		Name:            "client",
+		Description:     "TLS client authentication, e.g. mTLS service or user identities",
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsages:    []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		MaxValidityDays: 825,
+		SANTypes:        []SANType{SANTypeDNS, SANTypeIP, SANTypeEmail, SANTypeURI},
+	})
+	RegisterProfile(&Profile{
+		Name:            "code-signing",
+		Description:     "code signing; identified by the subject, so no DNS or IP SANs",
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsages:    []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		MaxValidityDays: 1185, // 39 months
+		SANTypes:        []SANType{SANTypeEmail, SANTypeURI},
+	})
+	RegisterProfile(&Profile{
+		Name:            "email",
+		Description:     "S/MIME signing and encryption for the -email addresses",
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		KeyEncipherment: true,
+		ExtKeyUsages:    []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+		MaxValidityDays: 825,
+		SANTypes:        []SANType{SANTypeEmail},
+		RequiredSAN:     SANTypeEmail,
+	})
+}
+
+// LookupProfile returns the registered profile with the given name.
+func LookupProfile(name string) (*Profile, error) {
+	profile, ok := profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %q (available: %s)", name, strings.Join(ProfileNames(), ", "))
+	}
+	return profile, nil
+}
+
+// ProfileNames returns the names of every registered profile, in
+// registration order.
+func ProfileNames() []string {
+	return append([]string(nil), profileOrder...)
+}
+
+// Allows reports whether the profile permits SANs of type t.
+func (p *Profile) Allows(t SANType) bool {
+	return slices.Contains(p.SANTypes, t)
+}
+
+// Check reports every way opts does not fit the profile, using the final SANs
+// and the validity window the certificate would get. A nil profile accepts
+// anything.
+func (p *Profile) Check(opts IssueOptions) error {
+	if p == nil {
+		return nil
+	}
+	counts := map[SANType]int{
+		SANTypeDNS:   len(opts.DNSNames),
+		SANTypeIP:    len(opts.IPAddresses),
+		SANTypeEmail: len(opts.EmailAddresses),
+		SANTypeURI:   len(opts.URIs),
+	}
+
+	var errs []error
+	for _, t := range []SANType{SANTypeDNS, SANTypeIP, SANTypeEmail, SANTypeURI} {
+		if counts[t] > 0 && !p.Allows(t) {
+			errs = append(errs, fmt.Errorf("%s SANs are not allowed", t))
+		}
+	}
+	if p.RequiredSAN != "" && counts[p.RequiredSAN] == 0 {
+		errs = append(errs, fmt.Errorf("at least one %s SAN is required", p.RequiredSAN))
+	}
+	if p.MaxValidityDays > 0 {
+		notBefore, notAfter := opts.ValidityWindow(clockOrDefault(opts.Clock).Now())
+		if notAfter.After(notBefore.AddDate(0, 0, p.MaxValidityDays)) {
+			errs = append(errs, fmt.Errorf("validity may not exceed %d days", p.MaxValidityDays))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("rejected by profile %s:\n%w", p.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+// renew.go
+package ca
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+)
+
+// RenewOptions holds the parameters for re-issuing a certificate.
+type RenewOptions struct {
+	// ValidityDays is the new validity period; 0 keeps the length of the
+	// current one.
+	ValidityDays int
+
+	SerialBits   int      // Serial number length in bits (64-160); 0 selects the default
+	SerialNumber *big.Int // Optional: use this serial instead of generating one
+
+	// Clock supplies the current time; nil selects SystemClock.
+	Clock Clock
+
+	// Test support: when set, Rand replaces crypto/rand.
+	Rand io.Reader
+}
+
+// Extensions that x509.CreateCertificate encodes from Certificate fields.
+// Renewal copies every other extension of the old certificate verbatim.
+var regeneratedExtensions = []asn1.ObjectIdentifier{
+	{2, 5, 29, 14},                  // Subject key identifier
+	{2, 5, 29, 15},                  // Key usage
+	{2, 5, 29, 17},                  // Subject alternative name
+	{2, 5, 29, 19},                  // Basic constraints
+	{2, 5, 29, 30},                  // Name constraints
+	{2, 5, 29, 31},                  // CRL distribution points
+	{2, 5, 29, 32},                  // Certificate policies
+	{2, 5, 29, 35},                  // Authority key identifier
+	{2, 5, 29, 37},                  // Extended key usage
+	{1, 3, 6, 1, 5, 5, 7, 1, 1},     // Authority information access
+	{1, 3, 6, 1, 4, 1, 11129, 2, 4}, // CT precertificate SCTs, which are specific to one certificate
+}
+
+// Renew re-issues cert, which c issued or which is c's own certificate, for
+// the same public key with a new serial number and validity window. The
+// subject, subject key identifier, SANs, constraints and other extensions
+// are carried over, so anything that trusts or chains to the old
+// certificate accepts the new one. Renewing c's own self-signed certificate
+// extends a root without redistributing a new trust anchor.
+func (c *CA) Renew(cert *x509.Certificate, opts RenewOptions) (*x509.Certificate, error) {
+	self := bytes.Equal(cert.Raw, c.Certificate.Raw)
+	if !self && !c.Issued(cert) {
+		return nil, fmt.Errorf("%s was not issued by %s", cert.Subject, c.Certificate.Subject)
+	}
+	if self && !c.IsRoot() {
+		return nil, fmt.Errorf("%s is not self-signed; renew it with its issuing CA", cert.Subject)
+	}
+	if cert.IsCA && !self {
+		maxPathLen := cert.MaxPathLen
+		if !cert.BasicConstraintsValid {
+			maxPathLen = -1
+		}
+		if err := c.checkPathLen(maxPathLen); err != nil {
+			return nil, err
+		}
+	}
+	if !cert.IsCA {
+		names := IssueOptions{DNSNames: cert.DNSNames, IPAddresses: cert.IPAddresses, EmailAddresses: cert.EmailAddresses, URIs: cert.URIs}
+		if err := CertificateNameConstraints(c.Certificate).Check(names); err != nil {
+			return nil, fmt.Errorf("outside the issuing CA's name constraints:\n%w", err)
+		}
+	}
+
+	template, err := renewalTemplate(cert, opts)
+	if err != nil {
+		return nil, err
+	}
+	if !self {
+		if err := c.checkNotAfter(template); err != nil {
+			return nil, err
+		}
+	}
+	return c.sign(randomOrDefault(opts.Rand), template, cert.PublicKey)
+}
+
+// RollKey moves a root CA to a new key of the given type. It returns the CA
+// for the new key, with a self-signed certificate carrying c's subject and
+// constraints, and a transition certificate for the new key signed by c's
+// key. Relying parties that only trust the old root can build chains through
+// the transition certificate until the new root has been distributed; it
+// expires with the old root.
+func (c *CA) RollKey(keyType string, keyBitSize int, skidMethod string, opts RenewOptions) (*CA, *x509.Certificate, error) {
+	if !c.IsRoot() {
+		return nil, nil, fmt.Errorf("%s is not a root CA; issue a new intermediate instead", c.Certificate.Subject)
+	}
+	random := randomOrDefault(opts.Rand)
+	privateKey, err := GenerateKey(random, keyType, keyBitSize)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate private key: %w", err)
+	}
+	subjectKeyID, err := computeSubjectKeyID(privateKey.Public(), skidMethod)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to compute subject key identifier: %w", err)
+	}
+
+	template, err := renewalTemplate(c.Certificate, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	template.SubjectKeyId = subjectKeyID
+	template.AuthorityKeyId = subjectKeyID
+	certBytes, err := x509.CreateCertificate(random, template, template, privateKey.Public(), privateKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(certBytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse generated certificate: %w", err)
+	}
+
+	// The transition certificate gets its own serial and is capped at the
+	// old root's expiry, after which nothing chains to the old root anyway.
+	transitionOpts := opts
+	transitionOpts.SerialNumber = nil
+	transitionTemplate, err := renewalTemplate(c.Certificate, transitionOpts)
+	if err != nil {
+		return nil, nil, err
+	}
+	transitionTemplate.SubjectKeyId = subjectKeyID
+	// Both roots share the subject, so only the authority key identifier
+	// tells chain builders which key signed this. x509.CreateCertificate
+	// leaves it out for matching subjects, as CrossSign notes, and roots from
+	// before SKIDs were added have none to copy.
+	transitionTemplate.AuthorityKeyId = c.Certificate.SubjectKeyId
+	if len(transitionTemplate.AuthorityKeyId) == 0 {
+		if transitionTemplate.AuthorityKeyId, err = computeSubjectKeyID(c.Certificate.PublicKey, SKIDMethodSHA1); err != nil {
+			return nil, nil, fmt.Errorf("failed to compute authority key identifier: %w", err)
+		}
+	}
+	if transitionTemplate.NotAfter.After(c.Certificate.NotAfter) {
+		transitionTemplate.NotAfter = c.Certificate.NotAfter
+	}
+	transition, err := c.sign(random, transitionTemplate, privateKey.Public())
+	if err != nil {
+		return nil, nil, err
+	}
+	return &CA{Certificate: cert, Key: privateKey}, transition, nil
+}
+
+// renewalTemplate copies everything but the serial number and validity
+// window from cert.
+func renewalTemplate(cert *x509.Certificate, opts RenewOptions) (*x509.Certificate, error) {
+	serialNumber, err := serialOrGenerate(opts.SerialNumber, randomOrDefault(opts.Rand), opts.SerialBits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+	now := clockOrDefault(opts.Clock).Now()
+	notBefore, notAfter := now, now.Add(cert.NotAfter.Sub(cert.NotBefore))
+	if opts.ValidityDays > 0 {
+		notBefore, notAfter = validityWindow(time.Time{}, time.Time{}, opts.ValidityDays, now)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		RawSubject:   cert.RawSubject, // Byte-for-byte, so name matching is unaffected
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+
+		KeyUsage:              cert.KeyUsage,
+		ExtKeyUsage:           cert.ExtKeyUsage,
+		UnknownExtKeyUsage:    cert.UnknownExtKeyUsage,
+		BasicConstraintsValid: cert.BasicConstraintsValid,
+		IsCA:                  cert.IsCA,
+		MaxPathLen:            cert.MaxPathLen,
+		MaxPathLenZero:        cert.MaxPathLenZero,
+		SubjectKeyId:          cert.SubjectKeyId,
+		AuthorityKeyId:        cert.AuthorityKeyId, // Used only for self-signed certificates
+
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		IPAddresses:    cert.IPAddresses,
+		URIs:           cert.URIs,
+
+		PermittedDNSDomainsCritical: cert.PermittedDNSDomainsCritical,
+		PermittedDNSDomains:         cert.PermittedDNSDomains,
+		ExcludedDNSDomains:          cert.ExcludedDNSDomains,
+		PermittedIPRanges:           cert.PermittedIPRanges,
+		ExcludedIPRanges:            cert.ExcludedIPRanges,
+		PermittedEmailAddresses:     cert.PermittedEmailAddresses,
+		ExcludedEmailAddresses:      cert.ExcludedEmailAddresses,
+		PermittedURIDomains:         cert.PermittedURIDomains,
+		ExcludedURIDomains:          cert.ExcludedURIDomains,
+
+		PolicyIdentifiers:     cert.PolicyIdentifiers,
+		OCSPServer:            cert.OCSPServer,
+		IssuingCertificateURL: cert.IssuingCertificateURL,
+		CRLDistributionPoints: cert.CRLDistributionPoints,
+	}
+	for _, ext := range cert.Extensions {
+		if !isRegeneratedExtension(ext) {
+			template.ExtraExtensions = append(template.ExtraExtensions, ext)
+		}
+	}
+	return template, nil
+}
+
+// isRegeneratedExtension reports whether x509.CreateCertificate encodes ext
+// from Certificate fields.
+func isRegeneratedExtension(ext pkix.Extension) bool {
+	for _, oid := range regeneratedExtensions {
+		if ext.Id.Equal(oid) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+// renew_test.go
+package ca
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"strings"
+	"testing"
+)
+
+// verifies reports an error unless cert chains to root, through
+// intermediates if any.
+func verifies(cert, root *x509.Certificate, intermediates ...*x509.Certificate) error {
+	opts := x509.VerifyOptions{Roots: x509.NewCertPool(), Intermediates: x509.NewCertPool(), KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}
+	opts.Roots.AddCert(root)
+	for _, intermediate := range intermediates {
+		opts.Intermediates.AddCert(intermediate)
+	}
+	_, err := cert.Verify(opts)
+	return err
+}
+
+func TestRenew(t *testing.T) {
+	root := newTestRoot(t, "Test Root", 365)
+	leaf, _, err := root.Issue(IssueOptions{CommonName: "app.example.com", DNSNames: []string{"app.example.com"}, ValidityDays: 30, KeyType: KeyTypeEd25519})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renewed, err := root.Renew(leaf, RenewOptions{ValidityDays: 90})
+	if err != nil {
+		t.Fatalf("Renew: %v", err)
+	}
+	if err := verifies(renewed, root.Certificate); err != nil {
+		t.Errorf("renewed certificate does not verify: %v", err)
+	}
+	if renewed.SerialNumber.Cmp(leaf.SerialNumber) == 0 {
+		t.Error("renewed certificate kept the serial number")
+	}
+	if !bytes.Equal(renewed.RawSubject, leaf.RawSubject) || !bytes.Equal(renewed.SubjectKeyId, leaf.SubjectKeyId) {
+		t.Error("renewed certificate changed the subject or subject key identifier")
+	}
+	if got := renewed.NotAfter.Sub(renewed.NotBefore).Hours() / 24; got != 90 {
+		t.Errorf("renewed certificate is valid for %v days, want 90", got)
+	}
+
+	// A renewal may not outlive its issuer.
+	if _, err := root.Renew(leaf, RenewOptions{ValidityDays: 400}); err == nil || !strings.Contains(err.Error(), "after the issuing CA") {
+		t.Errorf("Renew beyond the issuer's expiry: error = %v", err)
+	}
+	other := newTestRoot(t, "Other Root", 365)
+	if _, err := other.Renew(leaf, RenewOptions{}); err == nil || !strings.Contains(err.Error(), "was not issued by") {
+		t.Errorf("Renew by another CA: error = %v", err)
+	}
+
+	// Renewing the root itself extends it without changing the trust anchor.
+	extended, err := root.Renew(root.Certificate, RenewOptions{ValidityDays: 730})
+	if err != nil {
+		t.Fatalf("Renew of the root: %v", err)
+	}
+	if !extended.NotAfter.After(root.Certificate.NotAfter) {
+		t.Errorf("renewed root expires at %s, not after %s", extended.NotAfter, root.Certificate.NotAfter)
+	}
+	if err := verifies(leaf, extended); err != nil {
+		t.Errorf("certificate issued under the old root does not verify against the renewed one: %v", err)
+	}
+}
+
+func TestRollKey(t *testing.T) {
+	root := newTestRoot(t, "Test Root", 365)
+	legacy := *root.Certificate
+	legacy.SubjectKeyId = nil
+
+	tests := []struct {
+		name string
+		old  *CA
+	}{
+		{"with subject key identifier", root},
+		{"without subject key identifier", &CA{Certificate: &legacy, Key: root.Key}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, transition, err := tt.old.RollKey(KeyTypeEd25519, 0, SKIDMethodSHA1, RenewOptions{ValidityDays: 3650})
+			if err != nil {
+				t.Fatalf("RollKey: %v", err)
+			}
+			if !bytes.Equal(next.Certificate.RawSubject, root.Certificate.RawSubject) || next.Certificate.PublicKey.(interface{ Equal(crypto.PublicKey) bool }).Equal(root.Certificate.PublicKey) {
+				t.Error("new root does not keep the subject under a new key")
+			}
+			if err := verifies(next.Certificate, next.Certificate); err != nil {
+				t.Errorf("new root does not verify itself: %v", err)
+			}
+
+			wantAKID, err := computeSubjectKeyID(root.Certificate.PublicKey, SKIDMethodSHA1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(transition.AuthorityKeyId, wantAKID) {
+				t.Errorf("transition certificate AKID = %X, want the old root's key ID %X", transition.AuthorityKeyId, wantAKID)
+			}
+			if !bytes.Equal(transition.SubjectKeyId, next.Certificate.SubjectKeyId) {
+				t.Error("transition certificate and new root differ in subject key identifier")
+			}
+			if transition.NotAfter.After(root.Certificate.NotAfter) {
+				t.Errorf("transition certificate expires at %s, after the old root at %s", transition.NotAfter, root.Certificate.NotAfter)
+			}
+
+			// Relying parties that only trust the old root reach certificates
+			// from the new one through the transition certificate.
+			leaf, _, err := next.Issue(IssueOptions{CommonName: "app.example.com", DNSNames: []string{"app.example.com"}, ValidityDays: 30, KeyType: KeyTypeEd25519})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := verifies(leaf, root.Certificate, transition); err != nil {
+				t.Errorf("certificate from the new root does not verify against the old one: %v", err)
+			}
+			if err := verifies(leaf, next.Certificate); err != nil {
+				t.Errorf("certificate from the new root does not verify against it: %v", err)
+			}
+		})
+	}
+}
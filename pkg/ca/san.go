@@ -0,0 +1,198 @@
+// san.go
+package ca
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// SANType identifies the kind of Subject Alternative Name a rule applies to.
+type SANType string
+
+const (
+	SANTypeDNS   SANType = "dns"
+	SANTypeIP    SANType = "ip"
+	SANTypeEmail SANType = "email"
+	SANTypeURI   SANType = "uri"
+)
+
+// SANRule is a named check applied to every SAN of one type. Rules are
+// registered with RegisterSANRule and enabled by name through a SANPolicy, so
+// new checks can be added without touching the commands that issue
+// certificates.
+type SANRule struct {
+	Name        string
+	Type        SANType
+	Description string
+	Check       func(value string, policy SANPolicy) error
+}
+
+// SANPolicy selects which rules run and carries their settings.
+type SANPolicy struct {
+	Rules      []string // Names of the enabled rules
+	URISchemes []string // Allowed URI schemes for the uri-scheme rule; empty allows any
+}
+
+var (
+	sanRules     = map[string]SANRule{}
+	sanRuleOrder []string // Registration order, for stable output
+)
+
+// RegisterSANRule makes rule available to SAN policies. Registering the same
+// name twice is a programming error.
+func RegisterSANRule(rule SANRule) {
+	if _, dup := sanRules[rule.Name]; dup {
+		panic("duplicate SAN rule " + rule.Name)
+	}
+	sanRules[rule.Name] = rule
+	sanRuleOrder = append(sanRuleOrder, rule.Name)
+}
+
+func init() {
+	RegisterSANRule(SANRule{Name: "dns-syntax", Type: SANTypeDNS, Description: "hostname syntax (LDH labels, optional leading wildcard)", Check: checkDNSSyntax})
+	RegisterSANRule(SANRule{Name: "dns-not-ip", Type: SANTypeDNS, Description: "IP addresses must use -ip, not -dns", Check: checkDNSNotIP})
+	RegisterSANRule(SANRule{Name: "dns-public-suffix", Type: SANTypeDNS, Description: "no public suffixes or wildcards directly below one", Check: checkDNSPublicSuffix})
+	RegisterSANRule(SANRule{Name: "ip-specified", Type: SANTypeIP, Description: "no unspecified addresses (0.0.0.0, ::)", Check: checkIPSpecified})
+	RegisterSANRule(SANRule{Name: "email-syntax", Type: SANTypeEmail, Description: "RFC 5321 mailbox syntax and length limits", Check: checkEmailSyntax})
+	RegisterSANRule(SANRule{Name: "uri-scheme", Type: SANTypeURI, Description: "URI scheme is in the allowlist", Check: checkURIScheme})
+}
+
+// SANRuleNames returns the names of every registered rule, in registration
+// order.
+func SANRuleNames() []string {
+	return append([]string(nil), sanRuleOrder...)
+}
+
+// Validate runs the enabled rules against the SANs in opts and reports every
+// violation, not just the first.
+func (p SANPolicy) Validate(opts IssueOptions) error {
+	values := map[SANType][]string{
+		SANTypeDNS:   opts.DNSNames,
+		SANTypeEmail: opts.EmailAddresses,
+	}
+	for _, ip := range opts.IPAddresses {
+		values[SANTypeIP] = append(values[SANTypeIP], ip.String())
+	}
+	for _, u := range opts.URIs {
+		values[SANTypeURI] = append(values[SANTypeURI], u.String())
+	}
+
+	var errs []error
+	for _, name := range p.Rules {
+		rule, ok := sanRules[name]
+		if !ok {
+			return fmt.Errorf("unknown SAN rule %q", name)
+		}
+		for _, value := range values[rule.Type] {
+			if err := rule.Check(value, p); err != nil {
+				errs = append(errs, fmt.Errorf("%s SAN %q: %w (rule %s)", rule.Type, value, err, rule.Name))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func checkDNSSyntax(value string, _ SANPolicy) error {
+	name := strings.TrimPrefix(value, "*.")
+	if len(value) > 253 {
+		return errors.New("longer than 253 characters")
+	}
+	if name == "" {
+		return errors.New("empty name")
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 {
+			return errors.New("labels must be 1-63 characters")
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return errors.New("labels may not start or end with a hyphen")
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				switch {
+				case c == '*':
+					return errors.New("a wildcard is only allowed as the entire leftmost label")
+				case c > 0x7f:
+					return fmt.Errorf("invalid character %q (internationalized names must be punycode)", c)
+				}
+				return fmt.Errorf("invalid character %q", c)
+			}
+		}
+	}
+	return nil
+}
+
+func checkDNSNotIP(value string, _ SANPolicy) error {
+	if net.ParseIP(value) != nil {
+		return errors.New("is an IP address; use an IP SAN instead")
+	}
+	return nil
+}
+
+func checkDNSPublicSuffix(value string, _ SANPolicy) error {
+	name := strings.ToLower(strings.TrimPrefix(value, "*."))
+	suffix, icann := publicsuffix.PublicSuffix(name)
+	// Unlisted single-label names such as "localhost" fall under the implicit
+	// "*" rule; only reject names that are actually on the list.
+	if name != suffix || (!icann && !strings.Contains(suffix, ".")) {
+		return nil
+	}
+	if name != strings.ToLower(value) {
+		return fmt.Errorf("wildcard directly below public suffix %q", suffix)
+	}
+	return errors.New("is a public suffix")
+}
+
+func checkIPSpecified(value string, _ SANPolicy) error {
+	if ip := net.ParseIP(value); ip != nil && ip.IsUnspecified() {
+		return errors.New("unspecified address")
+	}
+	return nil
+}
+
+// checkEmailSyntax enforces the RFC 5321 mailbox limits that matter for
+// certificates: a dot-atom local part of at most 64 octets and a valid domain.
+func checkEmailSyntax(value string, policy SANPolicy) error {
+	local, domain, ok := strings.Cut(value, "@")
+	if !ok || strings.Contains(domain, "@") {
+		return errors.New("must contain exactly one @")
+	}
+	if len(value) > 254 {
+		return errors.New("longer than 254 characters")
+	}
+	if local == "" || len(local) > 64 {
+		return errors.New("local part must be 1-64 characters")
+	}
+	if strings.HasPrefix(local, ".") || strings.HasSuffix(local, ".") || strings.Contains(local, "..") {
+		return errors.New("local part has misplaced dots")
+	}
+	for _, c := range local {
+		if c > 0x7e || c <= ' ' || strings.ContainsRune(`"(),:;<>@[\]`, c) {
+			return fmt.Errorf("invalid character %q in local part", c)
+		}
+	}
+	if strings.HasPrefix(domain, "*.") {
+		return errors.New("wildcard domains are not allowed")
+	}
+	if err := checkDNSSyntax(domain, policy); err != nil {
+		return fmt.Errorf("domain: %w", err)
+	}
+	return nil
+}
+
+func checkURIScheme(value string, policy SANPolicy) error {
+	if len(policy.URISchemes) == 0 {
+		return nil
+	}
+	scheme, _, _ := strings.Cut(value, ":")
+	for _, allowed := range policy.URISchemes {
+		if strings.EqualFold(scheme, allowed) {
+			return nil
+		}
+	}
+	return fmt.Errorf("scheme %q is not allowed (allowed: %s)", scheme, strings.Join(policy.URISchemes, ", "))
+}
@@ -0,0 +1,88 @@
+// serial.go
+package ca
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+const (
+	DefaultSerialBits = 128 // Default serial number length in bits
+	MinSerialBits     = 64  // CA/Browser Forum minimum CSPRNG output
+	MaxSerialBits     = 160 // RFC 5280 caps serial numbers at 20 octets
+)
+
+// GenerateSerialNumber returns a random, positive, non-zero serial number
+// carrying the given number of bits of CSPRNG output. A bits value of 0
+// selects the default length.
+//
+// DER INTEGERs are signed, so a value whose most significant bit is set gains a
+// leading zero octet. At the 160-bit maximum that would make the encoding 21
+// octets long, so the top bit is cleared to stay within RFC 5280's limit.
+func GenerateSerialNumber(random io.Reader, bits int) (*big.Int, error) {
+	if bits == 0 {
+		bits = DefaultSerialBits
+	}
+	if bits < MinSerialBits || bits > MaxSerialBits {
+		return nil, fmt.Errorf("serial number length must be between %d and %d bits, got %d", MinSerialBits, MaxSerialBits, bits)
+	}
+
+	limit := new(big.Int).Lsh(big.NewInt(1), uint(bits))
+	if bits == MaxSerialBits {
+		limit.Rsh(limit, 1)
+	}
+
+	for {
+		serial, err := rand.Int(random, limit)
+		if err != nil {
+			return nil, err
+		}
+		// Zero is not a valid serial number; the odds of drawing it are
+		// negligible, but retrying is cheap.
+		if serial.Sign() > 0 {
+			return serial, nil
+		}
+	}
+}
+
+// SequentialRandomBits is the number of CSPRNG bits SequentialSerial appends
+// to the sequence number. With a 64-bit sequence the serial stays well within
+// MaxSerialBits.
+const SequentialRandomBits = 64
+
+// SequentialSerial returns the serial number for sequence number seq (from 1):
+// seq in the high bits followed by SequentialRandomBits bits of CSPRNG output.
+// Serials therefore sort in issuance order while remaining unpredictable, as
+// the CA/Browser Forum requires.
+func SequentialSerial(random io.Reader, seq uint64) (*big.Int, error) {
+	if seq == 0 {
+		return nil, fmt.Errorf("sequence numbers start at 1")
+	}
+	low, err := rand.Int(random, new(big.Int).Lsh(big.NewInt(1), SequentialRandomBits))
+	if err != nil {
+		return nil, err
+	}
+	serial := new(big.Int).SetUint64(seq)
+	serial.Lsh(serial, SequentialRandomBits)
+	return serial.Or(serial, low), nil
+}
+
+// SerialSequence returns the sequence number of a serial created by
+// SequentialSerial.
+func SerialSequence(serial *big.Int) uint64 {
+	return new(big.Int).Rsh(serial, SequentialRandomBits).Uint64()
+}
+
+// serialOrGenerate returns serial after checking it against RFC 5280, or a
+// new random serial of the given length if serial is nil.
+func serialOrGenerate(serial *big.Int, random io.Reader, bits int) (*big.Int, error) {
+	if serial == nil {
+		return GenerateSerialNumber(random, bits)
+	}
+	if serial.Sign() <= 0 || serial.BitLen() >= MaxSerialBits {
+		return nil, fmt.Errorf("serial number %X must be positive and at most 20 octets when encoded", serial)
+	}
+	return serial, nil
+}
@@ -0,0 +1,160 @@
+// serial_test.go
+package ca
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/asn1"
+	"io"
+	"math"
+	"math/big"
+	"testing"
+)
+
+// onesReader returns 0xFF bytes forever, driving rand.Int to the largest
+// value below its limit.
+type onesReader struct{}
+
+func (onesReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0xFF
+	}
+	return len(p), nil
+}
+
+// derLength returns the number of content octets in the DER encoding of n.
+func derLength(t *testing.T, n *big.Int) int {
+	t.Helper()
+	der, err := asn1.Marshal(n)
+	if err != nil {
+		t.Fatalf("asn1.Marshal(%X): %v", n, err)
+	}
+	if der[1]&0x80 != 0 {
+		t.Fatalf("DER encoding of %X uses a long-form length", n)
+	}
+	return int(der[1])
+}
+
+func TestGenerateSerialNumber(t *testing.T) {
+	tests := []struct {
+		bits       int
+		maxBitLen  int // Highest bit length a serial may have
+		maxOctets  int // Longest DER encoding
+		onesBitLen int // Bit length drawn from an all-ones source
+	}{
+		{bits: 0, maxBitLen: 128, maxOctets: 17, onesBitLen: 128},
+		{bits: 64, maxBitLen: 64, maxOctets: 9, onesBitLen: 64},
+		{bits: 128, maxBitLen: 128, maxOctets: 17, onesBitLen: 128},
+		// The top bit is cleared so the sign octet keeps it within 20 octets.
+		{bits: 160, maxBitLen: 159, maxOctets: 20, onesBitLen: 159},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			serial, err := GenerateSerialNumber(rand.Reader, tt.bits)
+			if err != nil {
+				t.Fatalf("GenerateSerialNumber(%d): %v", tt.bits, err)
+			}
+			if serial.Sign() <= 0 {
+				t.Fatalf("GenerateSerialNumber(%d) = %X, want a positive serial", tt.bits, serial)
+			}
+			if serial.BitLen() > tt.maxBitLen {
+				t.Fatalf("GenerateSerialNumber(%d) = %X, %d bits; want at most %d", tt.bits, serial, serial.BitLen(), tt.maxBitLen)
+			}
+			if n := derLength(t, serial); n > tt.maxOctets || n > 20 {
+				t.Fatalf("GenerateSerialNumber(%d) = %X encodes to %d octets; want at most %d", tt.bits, serial, n, tt.maxOctets)
+			}
+		}
+
+		serial, err := GenerateSerialNumber(onesReader{}, tt.bits)
+		if err != nil {
+			t.Fatalf("GenerateSerialNumber(%d) with an all-ones source: %v", tt.bits, err)
+		}
+		if serial.BitLen() != tt.onesBitLen {
+			t.Errorf("GenerateSerialNumber(%d) with an all-ones source has %d bits, want %d", tt.bits, serial.BitLen(), tt.onesBitLen)
+		}
+		if n := derLength(t, serial); n != tt.maxOctets {
+			t.Errorf("GenerateSerialNumber(%d) with an all-ones source encodes to %d octets, want %d", tt.bits, n, tt.maxOctets)
+		}
+	}
+}
+
+func TestGenerateSerialNumberRejectsLengths(t *testing.T) {
+	for _, bits := range []int{-1, 1, MinSerialBits - 1, MaxSerialBits + 1} {
+		if serial, err := GenerateSerialNumber(rand.Reader, bits); err == nil {
+			t.Errorf("GenerateSerialNumber(%d) = %X, want an error", bits, serial)
+		}
+	}
+}
+
+func TestGenerateSerialNumberSkipsZero(t *testing.T) {
+	// Eight zero octets draw a zero serial, which must be drawn again.
+	random := io.MultiReader(bytes.NewReader(make([]byte, 8)), onesReader{})
+	serial, err := GenerateSerialNumber(random, 64)
+	if err != nil {
+		t.Fatalf("GenerateSerialNumber: %v", err)
+	}
+	if serial.Sign() <= 0 {
+		t.Fatalf("GenerateSerialNumber = %X, want a positive serial", serial)
+	}
+}
+
+func TestSequentialSerial(t *testing.T) {
+	for _, seq := range []uint64{1, 2, 1000, math.MaxUint64} {
+		serial, err := SequentialSerial(rand.Reader, seq)
+		if err != nil {
+			t.Fatalf("SequentialSerial(%d): %v", seq, err)
+		}
+		if serial.Sign() <= 0 {
+			t.Errorf("SequentialSerial(%d) = %X, want a positive serial", seq, serial)
+		}
+		if got := SerialSequence(serial); got != seq {
+			t.Errorf("SerialSequence(SequentialSerial(%d)) = %d", seq, got)
+		}
+		if n := derLength(t, serial); n > 20 {
+			t.Errorf("SequentialSerial(%d) encodes to %d octets, want at most 20", seq, n)
+		}
+	}
+
+	if _, err := SequentialSerial(rand.Reader, 0); err == nil {
+		t.Error("SequentialSerial(0) succeeded, want an error")
+	}
+}
+
+func TestSequentialSerialOrder(t *testing.T) {
+	// The highest random part of one sequence number still sorts below the
+	// lowest of the next.
+	for _, seq := range []uint64{1, 41, math.MaxUint64 - 1} {
+		high, err := SequentialSerial(onesReader{}, seq)
+		if err != nil {
+			t.Fatalf("SequentialSerial(%d): %v", seq, err)
+		}
+		low, err := SequentialSerial(bytes.NewReader(make([]byte, 8)), seq+1)
+		if err != nil {
+			t.Fatalf("SequentialSerial(%d): %v", seq+1, err)
+		}
+		if high.Cmp(low) >= 0 {
+			t.Errorf("SequentialSerial(%d) = %X does not sort below SequentialSerial(%d) = %X", seq, high, seq+1, low)
+		}
+	}
+}
+
+func TestSerialOrGenerate(t *testing.T) {
+	max := new(big.Int).Lsh(big.NewInt(1), MaxSerialBits-1)
+	max.Sub(max, big.NewInt(1))
+	tests := []struct {
+		serial *big.Int
+		ok     bool
+	}{
+		{big.NewInt(1), true},
+		{max, true},
+		{new(big.Int).Add(max, big.NewInt(1)), false}, // 21 octets with the sign octet
+		{big.NewInt(0), false},
+		{big.NewInt(-5), false},
+	}
+	for _, tt := range tests {
+		_, err := serialOrGenerate(tt.serial, rand.Reader, 0)
+		if (err == nil) != tt.ok {
+			t.Errorf("serialOrGenerate(%X) error = %v, want ok = %v", tt.serial, err, tt.ok)
+		}
+	}
+}
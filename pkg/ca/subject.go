@@ -0,0 +1,184 @@
+// subject.go
+package ca
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"strings"
+)
+
+// SubjectAttributes holds the distinguished name attributes beyond the
+// Common Name and Organization. Empty fields are omitted.
+type SubjectAttributes struct {
+	Country            []string // Two-letter ISO 3166 codes
+	Province           []string // State or province
+	Locality           []string
+	OrganizationalUnit []string
+
+	// ExtraNames are added after the attributes above, for naming standards
+	// that need other attribute types (see ParseRDN).
+	ExtraNames []pkix.AttributeTypeAndValue
+}
+
+// rdnNames maps the attribute names ParseRDN accepts besides dotted OIDs.
+var rdnNames = map[string]asn1.ObjectIdentifier{
+	"street":       oidStreetAddress,
+	"postalCode":   oidPostalCode,
+	"serialNumber": oidSerialNumber,
+	"title":        {2, 5, 4, 12},
+	"DC":           {0, 9, 2342, 19200300, 100, 1, 25},
+	"emailAddress": {1, 2, 840, 113549, 1, 9, 1},
+}
+
+// ParseRDN parses an "attribute=value" pair such as "2.5.4.9=1 Main St" or
+// "postalCode=12345" into a subject attribute.
+func ParseRDN(value string) (pkix.AttributeTypeAndValue, error) {
+	name, attrValue, ok := strings.Cut(value, "=")
+	if !ok || name == "" || attrValue == "" {
+		return pkix.AttributeTypeAndValue{}, fmt.Errorf("expected attribute=value, got %q", value)
+	}
+	oid, ok := rdnNames[name]
+	if !ok {
+		for _, part := range strings.Split(name, ".") {
+			var arc int
+			if _, err := fmt.Sscanf(part, "%d", &arc); err != nil || fmt.Sprint(arc) != part || arc < 0 {
+				return pkix.AttributeTypeAndValue{}, fmt.Errorf("unknown attribute %q: use a dotted OID or one of street, postalCode, serialNumber, title, DC, emailAddress", name)
+			}
+			oid = append(oid, arc)
+		}
+		if len(oid) < 2 {
+			return pkix.AttributeTypeAndValue{}, fmt.Errorf("invalid OID %q", name)
+		}
+	}
+	return pkix.AttributeTypeAndValue{Type: oid, Value: attrValue}, nil
+}
+
+// Validate checks the attributes that have a fixed format.
+func (a SubjectAttributes) Validate() error {
+	for _, c := range a.Country {
+		if len(c) != 2 || strings.ToUpper(c) != c || !isPrintableLetters(c) {
+			return fmt.Errorf("country %q must be a two-letter ISO 3166 code such as US", c)
+		}
+	}
+	return nil
+}
+
+func isPrintableLetters(s string) bool {
+	for _, r := range s {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// subjectName builds a distinguished name from its parts. An empty
+// Organization is left out rather than encoded as an empty attribute.
+func subjectName(commonName, organization string, attrs SubjectAttributes) pkix.Name {
+	name := pkix.Name{CommonName: commonName}
+	if organization != "" {
+		name.Organization = []string{organization}
+	}
+	attrs.applyTo(&name)
+	return separateRDNs(name)
+}
+
+// Attribute types of pkix.Name fields, in the order pkix encodes them.
+var (
+	oidCountry            = asn1.ObjectIdentifier{2, 5, 4, 6}
+	oidProvince           = asn1.ObjectIdentifier{2, 5, 4, 8}
+	oidLocality           = asn1.ObjectIdentifier{2, 5, 4, 7}
+	oidStreetAddress      = asn1.ObjectIdentifier{2, 5, 4, 9}
+	oidPostalCode         = asn1.ObjectIdentifier{2, 5, 4, 17}
+	oidOrganization       = asn1.ObjectIdentifier{2, 5, 4, 10}
+	oidOrganizationalUnit = asn1.ObjectIdentifier{2, 5, 4, 11}
+	oidCommonName         = asn1.ObjectIdentifier{2, 5, 4, 3}
+	oidSerialNumber       = asn1.ObjectIdentifier{2, 5, 4, 5}
+)
+
+// csrSubject returns the subject of csr for a certificate. Parsing puts every
+// attribute in Names, and those pkix.Name has no field for, such as DC, UID
+// and emailAddress, only there; they are carried over in ExtraNames, after
+// the field attributes, so they are not lost when the name is encoded again.
+func csrSubject(csr *x509.CertificateRequest) pkix.Name {
+	name := csr.Subject
+	name.ExtraNames = nil
+	for _, attr := range name.Names {
+		if !isNameField(attr.Type) {
+			name.ExtraNames = append(name.ExtraNames, attr)
+		}
+	}
+	return name
+}
+
+// isNameField reports whether pkix.Name has a field for attribute type oid.
+func isNameField(oid asn1.ObjectIdentifier) bool {
+	for _, field := range []asn1.ObjectIdentifier{oidCountry, oidProvince, oidLocality, oidStreetAddress, oidPostalCode,
+		oidOrganization, oidOrganizationalUnit, oidCommonName, oidSerialNumber} {
+		if oid.Equal(field) {
+			return true
+		}
+	}
+	return false
+}
+
+// separateRDNs returns name with every attribute value in an RDN of its own.
+// pkix puts repeated values of one type, such as two OUs, into a single
+// multi-valued RDN ("OU=A+OU=B"), which naming standards rarely expect. When
+// that would happen, the whole name is spelled out in ExtraNames, which pkix
+// encodes one attribute per RDN in place of the fields.
+func separateRDNs(name pkix.Name) pkix.Name {
+	fields := []struct {
+		oid    asn1.ObjectIdentifier
+		values []string
+	}{
+		{oidCountry, name.Country},
+		{oidProvince, name.Province},
+		{oidLocality, name.Locality},
+		{oidStreetAddress, name.StreetAddress},
+		{oidPostalCode, name.PostalCode},
+		{oidOrganization, name.Organization},
+		{oidOrganizationalUnit, name.OrganizationalUnit},
+	}
+	multiValued := false
+	for _, field := range fields {
+		multiValued = multiValued || len(field.values) > 1
+	}
+	if !multiValued {
+		return name
+	}
+
+	var rdns []pkix.AttributeTypeAndValue
+	for _, field := range fields {
+		for _, value := range field.values {
+			rdns = append(rdns, pkix.AttributeTypeAndValue{Type: field.oid, Value: value})
+		}
+	}
+	if name.CommonName != "" {
+		rdns = append(rdns, pkix.AttributeTypeAndValue{Type: oidCommonName, Value: name.CommonName})
+	}
+	if name.SerialNumber != "" {
+		rdns = append(rdns, pkix.AttributeTypeAndValue{Type: oidSerialNumber, Value: name.SerialNumber})
+	}
+	name.ExtraNames = append(rdns, name.ExtraNames...)
+	return name
+}
+
+// applyTo sets every non-empty attribute on name, replacing what was there.
+func (a SubjectAttributes) applyTo(name *pkix.Name) {
+	if len(a.Country) > 0 {
+		name.Country = a.Country
+	}
+	if len(a.Province) > 0 {
+		name.Province = a.Province
+	}
+	if len(a.Locality) > 0 {
+		name.Locality = a.Locality
+	}
+	if len(a.OrganizationalUnit) > 0 {
+		name.OrganizationalUnit = a.OrganizationalUnit
+	}
+	name.ExtraNames = append(name.ExtraNames, a.ExtraNames...)
+}
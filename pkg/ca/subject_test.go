@@ -0,0 +1,74 @@
+// subject_test.go
+package ca
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"testing"
+)
+
+func TestSignCSRKeepsSubjectAttributes(t *testing.T) {
+	root := newTestRoot(t, "Test Root", 365)
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oidDC := asn1.ObjectIdentifier{0, 9, 2342, 19200300, 100, 1, 25}
+	oidUID := asn1.ObjectIdentifier{0, 9, 2342, 19200300, 100, 1, 1}
+	oidEmail := asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 1}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{
+			CommonName:   "jdoe",
+			Organization: []string{"CSR Org"},
+			ExtraNames: []pkix.AttributeTypeAndValue{
+				{Type: oidDC, Value: "example"},
+				{Type: oidUID, Value: "jdoe"},
+				{Type: oidEmail, Value: "jdoe@example.com"},
+			},
+		},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	title, err := ParseRDN("title=Engineer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := root.SignCSR(csr, IssueOptions{
+		ValidityDays: 30,
+		Organization: "Override Org",
+		Subject:      SubjectAttributes{ExtraNames: []pkix.AttributeTypeAndValue{title}},
+	})
+	if err != nil {
+		t.Fatalf("SignCSR: %v", err)
+	}
+
+	if got := cert.Subject.Organization; len(got) != 1 || got[0] != "Override Org" {
+		t.Errorf("Organization = %q, want the override", got)
+	}
+	if cert.Subject.CommonName != "jdoe" {
+		t.Errorf("CommonName = %q, want the CSR's", cert.Subject.CommonName)
+	}
+	want := map[string]string{
+		oidDC.String():      "example",
+		oidUID.String():     "jdoe",
+		oidEmail.String():   "jdoe@example.com",
+		title.Type.String(): "Engineer",
+	}
+	for _, attr := range cert.Subject.Names {
+		if value, ok := want[attr.Type.String()]; ok && attr.Value == value {
+			delete(want, attr.Type.String())
+		}
+	}
+	for oid, value := range want {
+		t.Errorf("subject %s lacks %s=%s", cert.Subject, oid, value)
+	}
+}
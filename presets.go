@@ -0,0 +1,152 @@
+// presets.go
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	presetFileName  = "presets.json"
+	presetConfigDir = "go-ca"
+)
+
+// presetFlags are the flags that are never stored inside a preset: those that
+// manage presets themselves, secrets, and test-support flags that must not
+// leak into later runs.
+var presetFlags = map[string]bool{
+	"preset":             true,
+	"save-preset":        true,
+	"preset-file":        true,
+	"passphrase":         true,
+	"deterministic-seed": true,
+	"fixed-time":         true,
+	"non-interactive":    true,
+}
+
+// exclusiveFlags lists flags that cannot be combined. A preset value is not
+// applied when its counterpart was given explicitly on the command line.
+var exclusiveFlags = map[string]string{
+	"days":      "not-after",
+	"not-after": "days",
+}
+
+// presetListSeparator separates the values of a repeatable flag, such as
+// -ou, stored in a preset; unlike a comma it cannot occur in a value.
+const presetListSeparator = "\n"
+
+// presetStore is the on-disk state file holding named presets. Each preset
+// maps flag names to their string values, exactly as they would be typed.
+type presetStore struct {
+	Presets map[string]map[string]string `json:"presets"`
+}
+
+// defaultPresetFile returns the per-user location of the preset state file.
+func defaultPresetFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return filepath.Join(".", "."+presetConfigDir+"-"+presetFileName)
+	}
+	return filepath.Join(dir, presetConfigDir, presetFileName)
+}
+
+// loadPresetStore reads the preset state file. A missing file is not an error
+// and yields an empty store.
+func loadPresetStore(path string) (*presetStore, error) {
+	store := &presetStore{Presets: map[string]map[string]string{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read preset file %q: %w", path, err)
+	}
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, fmt.Errorf("failed to parse preset file %q: %w", path, err)
+	}
+	if store.Presets == nil {
+		store.Presets = map[string]map[string]string{}
+	}
+	return store, nil
+}
+
+// save writes the store back to disk, creating the parent directory if needed.
+// Presets may contain subject details, so the file is kept private.
+func (s *presetStore) save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create preset directory: %w", err)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode presets: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write preset file %q: %w", path, err)
+	}
+	return nil
+}
+
+// names returns the stored preset names in sorted order.
+func (s *presetStore) names() []string {
+	names := make([]string, 0, len(s.Presets))
+	for name := range s.Presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyPreset sets every flag stored in the preset that was not given
+// explicitly on the command line, so explicit flags always win.
+func applyPreset(fs *flag.FlagSet, values map[string]string) error {
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	for name, value := range values {
+		if explicit[name] || explicit[exclusiveFlags[name]] || presetFlags[name] {
+			continue
+		}
+		f := fs.Lookup(name)
+		if f == nil {
+			return fmt.Errorf("unknown flag -%s", name)
+		}
+		values := []string{value}
+		if _, repeatable := f.Value.(*stringList); repeatable {
+			values = strings.Split(value, presetListSeparator)
+		}
+		for _, value := range values {
+			if err := fs.Set(name, value); err != nil {
+				return fmt.Errorf("invalid value for -%s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// capturePreset records every flag that was set, either explicitly or from a
+// preset, together with overrides for values gathered interactively.
+func capturePreset(fs *flag.FlagSet, overrides map[string]string) map[string]string {
+	values := map[string]string{}
+	fs.Visit(func(f *flag.Flag) {
+		if presetFlags[f.Name] {
+			return
+		}
+		if list, repeatable := f.Value.(*stringList); repeatable {
+			values[f.Name] = strings.Join(*list, presetListSeparator)
+		} else {
+			values[f.Name] = f.Value.String()
+		}
+	})
+	for name, value := range overrides {
+		if value != "" {
+			values[name] = value
+		}
+	}
+	return values
+}
@@ -0,0 +1,102 @@
+// presets_test.go
+package main
+
+import (
+	"flag"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testPresetFlags returns a flag set with flags of each kind presets store.
+func testPresetFlags() (*flag.FlagSet, *stringList) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.String("cn", "", "")
+	fs.Int("days", 365, "")
+	fs.String("not-after", "", "")
+	fs.String("passphrase", "", "")
+	var ou stringList
+	fs.Var(&ou, "ou", "")
+	return fs, &ou
+}
+
+func TestApplyPreset(t *testing.T) {
+	preset := map[string]string{
+		"cn":         "Preset Root",
+		"days":       "90",
+		"passphrase": "from-preset",
+		"ou":         "Platform" + presetListSeparator + "Security, Team B",
+	}
+	tests := []struct {
+		name string
+		args []string
+		want map[string]string
+	}{
+		{
+			name: "no explicit flags",
+			want: map[string]string{"cn": "Preset Root", "days": "90", "not-after": "", "ou": "Platform,Security, Team B"},
+		},
+		{
+			name: "explicit flags win",
+			args: []string{"-cn", "Explicit Root", "-ou", "Ops"},
+			want: map[string]string{"cn": "Explicit Root", "days": "90", "not-after": "", "ou": "Ops"},
+		},
+		{
+			name: "exclusive flag given explicitly",
+			args: []string{"-not-after", "2030-01-01"},
+			want: map[string]string{"cn": "Preset Root", "days": "365", "not-after": "2030-01-01", "ou": "Platform,Security, Team B"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs, _ := testPresetFlags()
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if err := applyPreset(fs, preset); err != nil {
+				t.Fatalf("applyPreset: %v", err)
+			}
+			for name, want := range tt.want {
+				if got := fs.Lookup(name).Value.String(); got != want {
+					t.Errorf("-%s = %q, want %q", name, got, want)
+				}
+			}
+			if got := fs.Lookup("passphrase").Value.String(); got != "" {
+				t.Errorf("-passphrase = %q, want it never taken from a preset", got)
+			}
+		})
+	}
+
+	fs, _ := testPresetFlags()
+	if err := applyPreset(fs, map[string]string{"org": "Example Corp"}); err == nil || !strings.Contains(err.Error(), "unknown flag -org") {
+		t.Errorf("applyPreset with an unknown flag: error = %v", err)
+	}
+	if err := applyPreset(fs, map[string]string{"days": "ninety"}); err == nil || !strings.Contains(err.Error(), "invalid value for -days") {
+		t.Errorf("applyPreset with an invalid value: error = %v", err)
+	}
+}
+
+func TestCapturePresetRoundTrip(t *testing.T) {
+	fs, _ := testPresetFlags()
+	args := []string{"-cn", "Root", "-ou", "Platform", "-ou", "Security, Team B", "-passphrase", "secret"}
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	captured := capturePreset(fs, map[string]string{"days": "30", "not-after": ""})
+	if _, stored := captured["passphrase"]; stored {
+		t.Error("capturePreset stored -passphrase")
+	}
+
+	restored, ou := testPresetFlags()
+	if err := applyPreset(restored, captured); err != nil {
+		t.Fatalf("applyPreset: %v", err)
+	}
+	if want := []string{"Platform", "Security, Team B"}; !reflect.DeepEqual([]string(*ou), want) {
+		t.Errorf("-ou = %q, want %q", *ou, want)
+	}
+	if got := restored.Lookup("days").Value.String(); got != "30" {
+		t.Errorf("-days = %q, want the interactive override 30", got)
+	}
+}
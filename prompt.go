@@ -0,0 +1,224 @@
+// prompt.go
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const maxPromptAttempts = 3 // Invalid answers tolerated before giving up
+
+// errNonInteractive is returned by Ask for a question that has neither an
+// answer nor a usable default when prompting is disabled.
+var errNonInteractive = errors.New("prompting is disabled in non-interactive mode")
+
+// Prompt IDs. They are stable keys used both in answers files and in the
+// message catalogs below.
+const (
+	promptCommonName   = "cn"
+	promptOrganization = "org"
+)
+
+// Message IDs that are not prompts.
+const (
+	msgDefault      = "default"
+	msgInvalid      = "invalid"
+	msgFromAnswers  = "from-answers"
+	msgValueMissing = "required"
+)
+
+// promptMessages holds the localized text for each prompt and message ID.
+// English is the fallback for missing locales and missing entries.
+var promptMessages = map[string]map[string]string{
+	"en": {
+		promptCommonName:   "Enter Common Name (CN) for the CA (e.g., 'My Dev Root CA')",
+		promptOrganization: "Enter Organization (O) (optional, press Enter to skip)",
+		msgDefault:         "default",
+		msgInvalid:         "Invalid value",
+		msgFromAnswers:     "from answers file",
+		msgValueMissing:    "a value is required",
+	},
+	"de": {
+		promptCommonName:   "Common Name (CN) der CA eingeben (z. B. 'Meine Dev Root CA')",
+		promptOrganization: "Organisation (O) eingeben (optional, Enter zum Überspringen)",
+		msgDefault:         "Standard",
+		msgInvalid:         "Ungültiger Wert",
+		msgFromAnswers:     "aus Antwortdatei",
+		msgValueMissing:    "ein Wert ist erforderlich",
+	},
+	"es": {
+		promptCommonName:   "Introduzca el Common Name (CN) de la CA (p. ej., 'Mi CA raíz de desarrollo')",
+		promptOrganization: "Introduzca la organización (O) (opcional, pulse Intro para omitir)",
+		msgDefault:         "predeterminado",
+		msgInvalid:         "Valor no válido",
+		msgFromAnswers:     "del archivo de respuestas",
+		msgValueMissing:    "se requiere un valor",
+	},
+	"fr": {
+		promptCommonName:   "Saisissez le Common Name (CN) de l'AC (ex. 'Mon AC racine de dev')",
+		promptOrganization: "Saisissez l'organisation (O) (facultatif, Entrée pour ignorer)",
+		msgDefault:         "par défaut",
+		msgInvalid:         "Valeur invalide",
+		msgFromAnswers:     "depuis le fichier de réponses",
+		msgValueMissing:    "une valeur est requise",
+	},
+}
+
+// Prompt describes one question asked interactively.
+type Prompt struct {
+	ID       string             // Message/answer key, e.g. promptCommonName
+	Default  string             // Returned when the user just presses Enter
+	Validate func(string) error // Optional: rejects unacceptable answers
+}
+
+// Prompter asks questions on a terminal, or answers them from a pre-recorded
+// answers file so the interactive path can be scripted and tested.
+type Prompter struct {
+	in      *bufio.Reader
+	out     io.Writer
+	locale  string
+	answers map[string]string
+
+	// NonInteractive disables prompting: questions without an answer are
+	// given their default, or fail if that is not acceptable, instead of
+	// waiting for input that may never come.
+	NonInteractive bool
+}
+
+// NewPrompter returns a Prompter reading from in and writing to out, using
+// messages for locale (falling back to English).
+func NewPrompter(in io.Reader, out io.Writer, locale string) *Prompter {
+	return &Prompter{
+		in:      bufio.NewReader(in),
+		out:     out,
+		locale:  normalizeLocale(locale),
+		answers: map[string]string{},
+	}
+}
+
+// LoadAnswers reads a JSON object mapping prompt IDs to answers, e.g.
+// {"cn": "My Root CA", "org": "Example Corp"}.
+func (p *Prompter) LoadAnswers(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read answers file %q: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &p.answers); err != nil {
+		return fmt.Errorf("failed to parse answers file %q: %w", path, err)
+	}
+	return nil
+}
+
+// Ask returns the answer to q. Answers from the answers file are validated
+// like typed ones; invalid typed answers are re-asked a few times.
+func (p *Prompter) Ask(q Prompt) (string, error) {
+	if answer, ok := p.answers[q.ID]; ok {
+		answer = strings.TrimSpace(answer)
+		if answer == "" {
+			answer = q.Default
+		}
+		if err := validateAnswer(q, answer); err != nil {
+			return "", fmt.Errorf("answers file value for %q: %w", q.ID, err)
+		}
+		fmt.Fprintf(p.out, "%s: %s (%s)\n", p.message(q.ID), answer, p.message(msgFromAnswers))
+		return answer, nil
+	}
+	if p.NonInteractive {
+		// Prompt IDs double as the names of the flags that answer them.
+		if err := validateAnswer(q, q.Default); err != nil {
+			return "", fmt.Errorf("-%s is required: %w", q.ID, errNonInteractive)
+		}
+		return q.Default, nil
+	}
+
+	for attempt := 1; ; attempt++ {
+		text := p.message(q.ID)
+		if q.Default != "" {
+			text = fmt.Sprintf("%s [%s: %s]", text, p.message(msgDefault), q.Default)
+		}
+		fmt.Fprint(p.out, text+": ")
+
+		input, readErr := p.in.ReadString('\n')
+		input = strings.TrimSpace(input)
+		if input == "" {
+			input = q.Default
+		}
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return "", readErr
+		}
+
+		err := validateAnswer(q, input)
+		if err == nil {
+			return input, nil
+		}
+		// At end of input there is no way to re-ask.
+		if errors.Is(readErr, io.EOF) || attempt >= maxPromptAttempts {
+			fmt.Fprintln(p.out)
+			return "", fmt.Errorf("%s: %w", q.ID, err)
+		}
+		fmt.Fprintf(p.out, "%s: %v\n", p.message(msgInvalid), err)
+	}
+}
+
+// message returns the localized text for id.
+func (p *Prompter) message(id string) string {
+	if text, ok := promptMessages[p.locale][id]; ok {
+		return text
+	}
+	if text, ok := promptMessages["en"][id]; ok {
+		return text
+	}
+	return id
+}
+
+// requiredValue is a validator rejecting empty answers.
+func (p *Prompter) requiredValue(value string) error {
+	if value == "" {
+		return errors.New(p.message(msgValueMissing))
+	}
+	return nil
+}
+
+// validateAnswer applies q's validator, if any.
+func validateAnswer(q Prompt, answer string) error {
+	if q.Validate == nil {
+		return nil
+	}
+	return q.Validate(answer)
+}
+
+// stdinIsTerminal reports whether standard input is a terminal, i.e. whether
+// anyone could answer a prompt.
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// detectLocale picks the message locale from the standard POSIX environment
+// variables, in their usual order of precedence.
+func detectLocale() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return "en"
+}
+
+// normalizeLocale reduces a locale such as "de_DE.UTF-8" to its language
+// ("de"), falling back to English for unknown languages.
+func normalizeLocale(locale string) string {
+	lang := strings.ToLower(locale)
+	if i := strings.IndexAny(lang, "_.@-"); i >= 0 {
+		lang = lang[:i]
+	}
+	if _, ok := promptMessages[lang]; ok {
+		return lang
+	}
+	return "en"
+}
@@ -0,0 +1,302 @@
+// prompt_test.go
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// notExample is a validator rejecting one value, for exercising re-asks.
+func notExample(value string) error {
+	if value == "example" {
+		return errors.New("example is taken")
+	}
+	return nil
+}
+
+func TestPrompterAsk(t *testing.T) {
+	required := (&Prompter{locale: "en"}).requiredValue
+	tests := []struct {
+		name     string
+		input    string
+		prompt   Prompt
+		want     string
+		wantErr  bool
+		wantOut  []string // Substrings of the output
+		prompted int      // Times the question is shown
+	}{
+		{
+			name:     "typed answer",
+			input:    "My Root CA\n",
+			prompt:   Prompt{ID: promptCommonName},
+			want:     "My Root CA",
+			prompted: 1,
+		},
+		{
+			name:     "surrounding space is trimmed",
+			input:    "  My Root CA \t\n",
+			prompt:   Prompt{ID: promptCommonName},
+			want:     "My Root CA",
+			prompted: 1,
+		},
+		{
+			name:     "enter takes the default",
+			input:    "\n",
+			prompt:   Prompt{ID: promptOrganization, Default: "Example Corp"},
+			want:     "Example Corp",
+			wantOut:  []string{"[default: Example Corp]"},
+			prompted: 1,
+		},
+		{
+			name:     "answer without a final newline",
+			input:    "My Root CA",
+			prompt:   Prompt{ID: promptCommonName},
+			want:     "My Root CA",
+			prompted: 1,
+		},
+		{
+			name:     "invalid answer is asked again",
+			input:    "example\nother\n",
+			prompt:   Prompt{ID: promptCommonName, Validate: notExample},
+			want:     "other",
+			wantOut:  []string{"Invalid value: example is taken"},
+			prompted: 2,
+		},
+		{
+			name:     "gives up after repeated invalid answers",
+			input:    strings.Repeat("example\n", maxPromptAttempts+1),
+			prompt:   Prompt{ID: promptCommonName, Validate: notExample},
+			wantErr:  true,
+			prompted: maxPromptAttempts,
+		},
+		{
+			name:     "invalid answer at end of input",
+			input:    "",
+			prompt:   Prompt{ID: promptCommonName, Validate: required},
+			wantErr:  true,
+			prompted: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			p := NewPrompter(strings.NewReader(tt.input), &out, "en")
+			got, err := p.Ask(tt.prompt)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Ask() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Ask() = %q, want %q", got, tt.want)
+			}
+			for _, s := range tt.wantOut {
+				if !strings.Contains(out.String(), s) {
+					t.Errorf("output %q does not contain %q", out.String(), s)
+				}
+			}
+			if n := strings.Count(out.String(), p.message(tt.prompt.ID)); n != tt.prompted {
+				t.Errorf("question shown %d times, want %d; output %q", n, tt.prompted, out.String())
+			}
+		})
+	}
+}
+
+func TestPrompterLocales(t *testing.T) {
+	tests := []struct {
+		locale string
+		want   string // Catalog used
+	}{
+		{"en", "en"},
+		{"de", "de"},
+		{"es", "es"},
+		{"fr", "fr"},
+		{"de_DE.UTF-8", "de"},
+		{"es_MX", "es"},
+		{"fr-CA", "fr"},
+		{"FR_fr.utf8", "fr"},
+		{"C", "en"},
+		{"ja_JP.UTF-8", "en"},
+		{"", "en"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.locale, func(t *testing.T) {
+			var out strings.Builder
+			p := NewPrompter(strings.NewReader("\nexample\n"), &out, tt.locale)
+			// Reject the first answer to show the invalid-value message.
+			attempts := 0
+			q := Prompt{ID: promptOrganization, Default: "example", Validate: func(string) error {
+				if attempts++; attempts == 1 {
+					return errors.New("retry")
+				}
+				return nil
+			}}
+			if _, err := p.Ask(q); err != nil {
+				t.Fatalf("Ask() error = %v", err)
+			}
+			catalog := promptMessages[tt.want]
+			for _, s := range []string{catalog[promptOrganization], "[" + catalog[msgDefault] + ": example]", catalog[msgInvalid] + ": retry"} {
+				if !strings.Contains(out.String(), s) {
+					t.Errorf("output %q does not contain %q", out.String(), s)
+				}
+			}
+		})
+	}
+}
+
+func TestPromptCatalogsComplete(t *testing.T) {
+	for locale, catalog := range promptMessages {
+		for id := range promptMessages["en"] {
+			if catalog[id] == "" {
+				t.Errorf("locale %q has no message %q", locale, id)
+			}
+		}
+		for id := range catalog {
+			if _, ok := promptMessages["en"][id]; !ok {
+				t.Errorf("locale %q has message %q, which English lacks", locale, id)
+			}
+		}
+	}
+}
+
+func TestPrompterAnswersFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name    string
+		answers string
+		locale  string
+		prompt  Prompt
+		want    string
+		wantErr bool
+		wantOut string
+	}{
+		{
+			name:    "answer is used",
+			answers: `{"cn": " My Root CA "}`,
+			prompt:  Prompt{ID: promptCommonName},
+			want:    "My Root CA",
+			wantOut: "My Root CA (from answers file)",
+		},
+		{
+			name:    "empty answer takes the default",
+			answers: `{"org": ""}`,
+			prompt:  Prompt{ID: promptOrganization, Default: "Example Corp"},
+			want:    "Example Corp",
+		},
+		{
+			name:    "answers are validated",
+			answers: `{"cn": "example"}`,
+			prompt:  Prompt{ID: promptCommonName, Validate: notExample},
+			wantErr: true,
+		},
+		{
+			name:    "unanswered question is asked",
+			answers: `{"org": "Example Corp"}`,
+			prompt:  Prompt{ID: promptCommonName},
+			want:    "typed",
+		},
+		{
+			name:    "localized note",
+			answers: `{"cn": "Meine CA"}`,
+			locale:  "de",
+			prompt:  Prompt{ID: promptCommonName},
+			want:    "Meine CA",
+			wantOut: "Meine CA (aus Antwortdatei)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			p := NewPrompter(strings.NewReader("typed\n"), &out, tt.locale)
+			if err := p.LoadAnswers(write(tt.name+".json", tt.answers)); err != nil {
+				t.Fatalf("LoadAnswers() error = %v", err)
+			}
+			got, err := p.Ask(tt.prompt)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Ask() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Ask() = %q, want %q", got, tt.want)
+			}
+			if !strings.Contains(out.String(), tt.wantOut) {
+				t.Errorf("output %q does not contain %q", out.String(), tt.wantOut)
+			}
+		})
+	}
+
+	p := NewPrompter(strings.NewReader(""), &strings.Builder{}, "en")
+	if err := p.LoadAnswers(write("bad.json", `["cn"]`)); err == nil {
+		t.Error("LoadAnswers() of a JSON array succeeded, want an error")
+	}
+	if err := p.LoadAnswers(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("LoadAnswers() of a missing file succeeded, want an error")
+	}
+}
+
+func TestPrompterNonInteractive(t *testing.T) {
+	required := (&Prompter{locale: "en"}).requiredValue
+	tests := []struct {
+		name    string
+		answers map[string]string
+		prompt  Prompt
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "default is used",
+			prompt: Prompt{ID: promptOrganization, Default: "Example Corp"},
+			want:   "Example Corp",
+		},
+		{
+			name:   "optional question without a default",
+			prompt: Prompt{ID: promptOrganization},
+			want:   "",
+		},
+		{
+			name:    "required question without a default fails",
+			prompt:  Prompt{ID: promptCommonName, Validate: required},
+			wantErr: true,
+		},
+		{
+			name:    "answers still apply",
+			answers: map[string]string{promptCommonName: "My Root CA"},
+			prompt:  Prompt{ID: promptCommonName, Validate: required},
+			want:    "My Root CA",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			// Input that must never be read.
+			p := NewPrompter(strings.NewReader("typed\n"), &out, "en")
+			p.NonInteractive = true
+			for id, answer := range tt.answers {
+				p.answers[id] = answer
+			}
+			got, err := p.Ask(tt.prompt)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Ask() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !errors.Is(err, errNonInteractive) || !strings.Contains(err.Error(), "-"+tt.prompt.ID+" is required") {
+					t.Errorf("Ask() error = %v, want errNonInteractive naming -%s", err, tt.prompt.ID)
+				}
+			}
+			if got != tt.want {
+				t.Errorf("Ask() = %q, want %q", got, tt.want)
+			}
+			if strings.Contains(out.String(), p.message(tt.prompt.ID)+":") && tt.answers == nil {
+				t.Errorf("non-interactive Ask() prompted: %q", out.String())
+			}
+		})
+	}
+}
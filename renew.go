@@ -0,0 +1,202 @@
+// renew.go
+package main
+
+import (
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/prtk1729/certA/pkg/ca"
+)
+
+// runRenew implements the renew command: it re-issues a certificate for the
+// same key with a new validity window, or moves a root CA to a new key.
+func runRenew(args []string) {
+	fs := flag.NewFlagSet("renew", flag.ExitOnError)
+	certFile := fs.String("cert", defaultCertFileName, "Certificate PEM file to renew: a root CA, or a certificate issued by -ca")
+	keyFile := fs.String("key", "", "Private key of a root CA being renewed (default: "+defaultKeyFileName+" next to -cert)")
+	caCertFile := fs.String("ca", "", "Issuing CA certificate PEM file, for a certificate that is not self-signed")
+	caKeyFile := fs.String("ca-key", defaultKeyFileName, "Issuing CA private key PEM file")
+	validityDays := fs.Int("days", 0, "New validity period in days (default: the length of the current one)")
+	outFile := fs.String("out", "", "Path to write the renewed certificate (default: -cert with a -renewed suffix)")
+	newKey := fs.Bool("new-key", false, "Roll a root CA to a new key instead, and write a transition certificate for the new key signed by the old one")
+	keyType := fs.String("key-type", defaultKeyType, "Key algorithm for -new-key: "+strings.Join(ca.SupportedKeyTypes, ", "))
+	keyBitSize := fs.Int("bits", defaultKeyBitSize, "RSA key size in bits for -new-key; ignored for other key types")
+	skidMethod := fs.String("skid-method", ca.SKIDMethodSHA1, "Subject key identifier method for -new-key: 'sha1' or 'sha256'")
+	keyOutFile := fs.String("key-out", "", "Path to write the -new-key private key (default: -out with a .key extension)")
+	transitionOutFile := fs.String("transition-out", "", "Path to write the -new-key transition certificate (default: -out with a -transition suffix)")
+	var serials serialFlags
+	serials.register(fs)
+	var db certDBFlags
+	db.register(fs)
+	var denylist denylistFlags
+	denylist.register(fs)
+	var keyPassphrase passphraseFlags
+	keyPassphrase.register(fs, "", "decrypt the -key private key with")
+	var caPassphrase passphraseFlags
+	caPassphrase.register(fs, "ca-", "decrypt the issuing CA private key with")
+	var newKeyPassphrase passphraseFlags
+	newKeyPassphrase.register(fs, "new-key-", "encrypt the -new-key private key with")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s renew -cert ca.crt [-key ca.key] [options]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s renew -cert server.crt -ca ca.crt -ca-key ca.key [options]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s renew -cert ca.crt -key ca.key -new-key [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Re-issues a certificate for the same key, subject, subject key identifier and\n")
+		fmt.Fprintf(os.Stderr, "extensions with a new serial and validity window, so existing chains keep\n")
+		fmt.Fprintf(os.Stderr, "working. With -new-key, moves a root CA to a new key instead.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if err := checkFlags(fs); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := serials.check(); err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+
+	certs, err := ca.LoadCertificates(*certFile)
+	if err != nil {
+		log.Fatalf("Error loading certificate: %v", err)
+	}
+	cert := certs[0]
+	selfSigned := isSelfSigned(cert)
+	if selfSigned && !cert.IsCA {
+		log.Fatalf("Error: %s is self-signed but not a CA; create a new one with selfsign.", cert.Subject)
+	}
+	if *newKey && !selfSigned {
+		log.Fatal("Error: -new-key only applies to root CAs; for other certificates issue a new one.")
+	}
+	if *outFile == "" {
+		*outFile = strings.TrimSuffix(*certFile, filepath.Ext(*certFile)) + "-renewed.crt"
+	}
+	outputs := []string{*outFile}
+	if *newKey {
+		if *keyOutFile == "" {
+			*keyOutFile = strings.TrimSuffix(*outFile, filepath.Ext(*outFile)) + ".key"
+		}
+		if *transitionOutFile == "" {
+			*transitionOutFile = strings.TrimSuffix(*outFile, filepath.Ext(*outFile)) + "-transition.crt"
+		}
+		outputs = append(outputs, *keyOutFile, *transitionOutFile)
+		if !ca.IsValidKeyType(*keyType) {
+			log.Fatalf("Error: unsupported -key-type %q. Supported: %s.", *keyType, strings.Join(ca.SupportedKeyTypes, ", "))
+		}
+	}
+	for _, path := range outputs {
+		if _, err := os.Stat(path); err == nil {
+			log.Fatalf("Error: %s already exists; not overwriting it.", path)
+		}
+	}
+
+	// A root signs its own renewal; anything else is renewed by its issuer.
+	var signer *ca.CA
+	var signerCertFile string
+	if selfSigned {
+		if *keyFile == "" {
+			*keyFile = filepath.Join(filepath.Dir(*certFile), defaultKeyFileName)
+		}
+		signer, err = ca.LoadCA(*certFile, *keyFile, keyPassphrase.source())
+		signerCertFile = *certFile
+	} else {
+		if *caCertFile == "" {
+			fs.Usage()
+			log.Fatalf("Error: %s is not self-signed; -ca and -ca-key are required to renew it.", cert.Subject)
+		}
+		signer, err = ca.LoadCA(*caCertFile, *caKeyFile, caPassphrase.source())
+		signerCertFile = *caCertFile
+	}
+	if err != nil {
+		log.Fatalf("Error loading CA: %v", err)
+	}
+	list, err := denylist.load(signerCertFile)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := list.Check(cert.PublicKey); err != nil {
+		log.Fatalf("Error: refusing to renew: %v", err)
+	}
+
+	dbFile := db.resolve(signerCertFile)
+	opts := ca.RenewOptions{ValidityDays: *validityDays, SerialBits: serials.bits}
+	var seq uint64
+	if opts.SerialNumber, seq, err = serials.allocate(dbFile, nil); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	perms := DefaultOutputPermissions()
+	for _, path := range outputs {
+		if err := prepareOutputDir(filepath.Dir(path), perms, false); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	}
+	if *newKey {
+		rollKey(signer, opts, *keyType, *keyBitSize, *skidMethod, *outFile, *keyOutFile, *transitionOutFile, dbFile, &newKeyPassphrase, perms)
+		return
+	}
+
+	renewed, err := signer.Renew(cert, opts)
+	if err != nil {
+		log.Fatalf("Error renewing certificate: %v", err)
+	}
+	// Roots are not recorded in their own database, as with init.
+	if !selfSigned {
+		if err := recordIssued(dbFile, renewed, *outFile, seq); err != nil {
+			log.Fatalf("Error recording certificate: %v", err)
+		}
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: renewed.Raw})
+	if err := writeFileWithPermissions(*outFile, certPEM, perms.CertMode, perms); err != nil {
+		log.Fatalf("Error writing certificate: %v", err)
+	}
+
+	fmt.Println("Renewed Certificate")
+	fmt.Printf("  Subject: %s\n", renewed.Subject)
+	fmt.Printf("  Issuer: %s\n", renewed.Issuer)
+	fmt.Printf("  Serial: %X (was %X)\n", renewed.SerialNumber, cert.SerialNumber)
+	fmt.Printf("  Validity: %s to %s (was until %s)\n", renewed.NotBefore.Format(time.RFC3339), renewed.NotAfter.Format(time.RFC3339), cert.NotAfter.Format(time.RFC3339))
+	fmt.Printf("  Key: unchanged (subject key identifier %s)\n", colonHex(renewed.SubjectKeyId))
+	fmt.Printf("\nRenewed certificate saved to: %s\n", *outFile)
+	if selfSigned {
+		fmt.Println("Certificates issued by the old root chain to the renewed one; replace the old root")
+		fmt.Println("certificate in trust stores that check the expiry of trust anchors.")
+	}
+}
+
+// rollKey implements renew -new-key: it moves root to a new key, writing the
+// new root, its key and the transition certificate signed by the old key.
+func rollKey(root *ca.CA, opts ca.RenewOptions, keyType string, keyBitSize int, skidMethod, certFile, keyFile, transitionFile, dbFile string, keyPassphrase *passphraseFlags, perms OutputPermissions) {
+	passphrase, err := keyPassphrase.read()
+	if err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+	fmt.Printf("Rolling %s to a new %s key...\n", root.Certificate.Subject, ca.DescribeKeyType(keyType, keyBitSize))
+	next, transition, err := root.RollKey(keyType, keyBitSize, skidMethod, opts)
+	if err != nil {
+		log.Fatalf("Error generating new key: %v", err)
+	}
+	if err := recordIssued(dbFile, transition, transitionFile, 0); err != nil {
+		log.Fatalf("Error recording transition certificate: %v", err)
+	}
+
+	if err := ExportToPEM(next.Certificate.Raw, next.Key, certFile, keyFile, perms, passphrase); err != nil {
+		log.Fatalf("Error exporting files: %v", err)
+	}
+	transitionPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: transition.Raw})
+	if err := writeFileWithPermissions(transitionFile, transitionPEM, perms.CertMode, perms); err != nil {
+		log.Fatalf("Error writing transition certificate: %v", err)
+	}
+
+	fmt.Printf("\nSuccess!\n")
+	fmt.Printf("  New Root Certificate saved to: %s (valid until %s)\n", certFile, next.Certificate.NotAfter.Format(time.RFC3339))
+	fmt.Printf("  New Root Private Key saved to: %s (Keep this file secure!)\n", keyFile)
+	fmt.Printf("  Transition Certificate saved to: %s (valid until %s)\n", transitionFile, transition.NotAfter.Format(time.RFC3339))
+	fmt.Println("\nIssue from the new key and serve the transition certificate as an intermediate, so")
+	fmt.Println("clients that only trust the old root keep working while the new root is distributed.")
+}
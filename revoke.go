@@ -0,0 +1,252 @@
+// revoke.go
+package main
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prtk1729/certA/pkg/ca"
+)
+
+const (
+	defaultCRLNextUpdate    = 7 * 24 * time.Hour
+	defaultRevocationReason = "unspecified"
+)
+
+// parseSerial parses a hexadecimal serial number, with or without colons.
+func parseSerial(value string) (*big.Int, error) {
+	hex := strings.ReplaceAll(strings.TrimPrefix(strings.ToLower(value), "0x"), ":", "")
+	serial, ok := new(big.Int).SetString(hex, 16)
+	if !ok || serial.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid serial number %q (expected positive hex)", value)
+	}
+	return serial, nil
+}
+
+// revocationReasonList renders the accepted -reason values.
+func revocationReasonList() string {
+	names := make([]string, 0, len(ca.RevocationReasonNames))
+	for name := range ca.RevocationReasonNames {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return ca.RevocationReasonNames[names[i]] < ca.RevocationReasonNames[names[j]]
+	})
+	return strings.Join(names, ", ")
+}
+
+// runRevoke implements the revoke command: it records a certificate as
+// revoked in the issuing CA's certificate database. The revocation takes
+// effect for relying parties once a new CRL is generated with gen-crl.
+func runRevoke(args []string) {
+	fs := flag.NewFlagSet("revoke", flag.ExitOnError)
+	caCertFile := fs.String("ca", defaultCertFileName, "Issuing CA certificate PEM file")
+	certFile := fs.String("cert", "", "Certificate PEM file to revoke (or use -serial)")
+	serialFlag := fs.String("serial", "", "Serial number (hex) to revoke (or use -cert)")
+	reason := fs.String("reason", defaultRevocationReason, "Revocation reason: "+revocationReasonList())
+	var db certDBFlags
+	db.register(fs)
+	var at clockFlags
+	at.register(fs, "Optional: record this RFC 3339 timestamp as the revocation time instead of now")
+	var denylist denylistFlags
+	denylist.register(fs)
+
+	var cfg configFlags
+	cfg.register(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s revoke -ca ca.crt (-cert server.crt | -serial <hex>) [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Records a certificate as revoked. Run gen-crl afterwards to publish the revocation.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	cfg.apply(fs)
+	if err := checkFlags(fs); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	if (*certFile == "") == (*serialFlag == "") {
+		fs.Usage()
+		log.Fatal("Error: exactly one of -cert and -serial is required.")
+	}
+	if _, ok := ca.RevocationReasonNames[*reason]; !ok {
+		log.Fatalf("Error: unknown -reason %q. Supported: %s.", *reason, revocationReasonList())
+	}
+	clock, err := at.clock()
+	if err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+	dbFile := db.resolve(*caCertFile)
+
+	caCerts, err := ca.LoadCertificates(*caCertFile)
+	if err != nil {
+		log.Fatalf("Error loading CA certificate: %v", err)
+	}
+	issuer := &ca.CA{Certificate: caCerts[0]}
+
+	unlock, err := lockCertDB(dbFile)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	defer unlock()
+	certs, err := loadCertDB(dbFile)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	var record *certRecord
+	var compromisedKey crypto.PublicKey
+	if *certFile != "" {
+		leaf, err := ca.LoadCertificates(*certFile)
+		if err != nil {
+			log.Fatalf("Error loading certificate: %v", err)
+		}
+		if !issuer.Issued(leaf[0]) {
+			log.Fatalf("Error: %s was not issued by %s.", leaf[0].Subject, issuer.Certificate.Subject)
+		}
+		if record = certs.find(fmt.Sprintf("%X", leaf[0].SerialNumber)); record == nil {
+			// Issued before the database existed.
+			record = newCertRecord(leaf[0], "")
+			certs.Certificates = append(certs.Certificates, record)
+		}
+		if *reason == "key-compromise" {
+			compromisedKey = leaf[0].PublicKey
+		}
+	} else {
+		serial, err := parseSerial(*serialFlag)
+		if err != nil {
+			log.Fatalf("Error: %v.", err)
+		}
+		if record = certs.find(fmt.Sprintf("%X", serial)); record == nil {
+			fmt.Printf("Warning: serial %X is not in %s; revoking it anyway.\n", serial, dbFile)
+			record = &certRecord{Serial: fmt.Sprintf("%X", serial)}
+			certs.Certificates = append(certs.Certificates, record)
+		}
+	}
+	if record.Status == statusRevoked {
+		log.Fatalf("Error: serial %s was already revoked at %s (%s).", record.Serial, record.RevokedAt.Format(time.RFC3339), record.Reason)
+	}
+	revokedAt := clock.Now().UTC()
+	record.Status = statusRevoked
+	record.Reason = *reason
+	record.RevokedAt = &revokedAt
+	if err := certs.save(dbFile); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	denied, deniedIn := "", ""
+	if compromisedKey != nil {
+		// A compromised key must not be certified again under a new serial.
+		if denied, deniedIn, err = denylist.denyCompromised(*caCertFile, compromisedKey, record.Serial); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	}
+
+	fmt.Println("Revoked Certificate")
+	fmt.Printf("  Serial: %s\n", record.Serial)
+	if record.Subject != "" {
+		fmt.Printf("  Subject: %s\n", record.Subject)
+	}
+	fmt.Printf("  Reason: %s\n", record.Reason)
+	fmt.Printf("  Revoked At: %s\n", record.RevokedAt.Format(time.RFC3339))
+	fmt.Printf("  Database: %s (%d revoked)\n", dbFile, len(certs.revoked()))
+	if denied != "" {
+		fmt.Printf("  Key Denied: sha256/%s (added to %s)\n", denied, deniedIn)
+	}
+	fmt.Printf("\nRun '%s gen-crl -ca %s -ca-key <key>' to publish the revocation.\n", os.Args[0], *caCertFile)
+}
+
+// runGenCRL implements the gen-crl command: it signs a CRL listing every
+// revoked certificate in the CA's certificate database.
+func runGenCRL(args []string) {
+	fs := flag.NewFlagSet("gen-crl", flag.ExitOnError)
+	caCertFile := fs.String("ca", defaultCertFileName, "Issuing CA certificate PEM file")
+	caKeyFile := fs.String("ca-key", defaultKeyFileName, "Issuing CA private key PEM file")
+	var db certDBFlags
+	db.register(fs)
+	outFile := fs.String("out", "", "Path to write the CRL (default: the CA certificate path with a .crl extension)")
+	nextUpdate := fs.Duration("next-update", defaultCRLNextUpdate, "How long the CRL stays current; publish a new one before then")
+	der := fs.Bool("der", false, "Write the CRL in DER instead of PEM")
+	var caPassphrase passphraseFlags
+	caPassphrase.register(fs, "ca-", "decrypt the issuing CA private key with")
+	var at clockFlags
+	at.register(fs, "Test support: use this RFC 3339 timestamp as the CRL issue time instead of now")
+
+	var cfg configFlags
+	cfg.register(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s gen-crl -ca ca.crt -ca-key ca.key [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Generates a signed CRL from the revoked certificates in the CA's certificate database.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	cfg.apply(fs)
+	if err := checkFlags(fs); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	clock, err := at.clock()
+	if err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+	dbFile := db.resolve(*caCertFile)
+	if *outFile == "" {
+		*outFile = strings.TrimSuffix(*caCertFile, filepath.Ext(*caCertFile)) + ".crl"
+	}
+
+	unlock, err := lockCertDB(dbFile)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	defer unlock()
+	certs, err := loadCertDB(dbFile)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	issuer, err := ca.LoadCA(*caCertFile, *caKeyFile, caPassphrase.source())
+	if err != nil {
+		log.Fatalf("Error loading issuing CA: %v", err)
+	}
+
+	crlBytes, err := certs.signCRL(issuer, *nextUpdate, clock)
+	if err != nil {
+		log.Fatalf("Error generating CRL: %v", err)
+	}
+	crl, err := x509.ParseRevocationList(crlBytes)
+	if err != nil {
+		log.Fatalf("Error parsing generated CRL: %v", err)
+	}
+
+	out := crlBytes
+	if !*der {
+		out = pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crlBytes})
+	}
+	if err := os.WriteFile(*outFile, out, defaultCertFileMode); err != nil {
+		log.Fatalf("Error writing CRL: %v", err)
+	}
+	// Only consume the CRL number once the CRL has actually been written.
+	if err := certs.save(dbFile); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	fmt.Println("Generated CRL")
+	fmt.Printf("  Issuer: %s\n", crl.Issuer)
+	fmt.Printf("  CRL Number: %d\n", crl.Number)
+	fmt.Printf("  Revoked Certificates: %d\n", len(crl.RevokedCertificateEntries))
+	fmt.Printf("  This Update: %s\n", crl.ThisUpdate.Format(time.RFC3339))
+	fmt.Printf("  Next Update: %s\n", crl.NextUpdate.Format(time.RFC3339))
+	fmt.Printf("\nCRL saved to: %s\n", *outFile)
+}
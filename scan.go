@@ -0,0 +1,201 @@
+// scan.go
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prtk1729/certA/pkg/ca"
+)
+
+// Finding severities, in increasing order of urgency.
+const (
+	severityWarning = "warning"
+	severityError   = "error"
+)
+
+// scanPolicy holds the thresholds a certificate is evaluated against.
+type scanPolicy struct {
+	MinRSABits      int
+	MaxLeafValidity int // days
+	ExpiryWarning   time.Duration
+}
+
+// scanFinding is one policy violation with a suggested fix.
+type scanFinding struct {
+	File        string `json:"file"`
+	Subject     string `json:"subject"`
+	Serial      string `json:"serial"`
+	NotAfter    string `json:"not_after"`
+	Severity    string `json:"severity"`
+	Issue       string `json:"issue"`
+	Remediation string `json:"remediation"`
+}
+
+// runScan implements the scan command: it evaluates every certificate in a
+// directory of PEM files against a policy and prints a remediation list.
+func runScan(args []string) {
+	flags := flag.NewFlagSet("scan", flag.ExitOnError)
+	dir := flags.String("dir", ".", "Directory to scan recursively for PEM certificates (.pem, .crt, .cer)")
+	minRSABits := flags.Int("min-rsa-bits", 2048, "Minimum acceptable RSA key size")
+	maxLeafDays := flags.Int("max-leaf-days", 398, "Maximum acceptable total validity for leaf certificates, in days")
+	warnDays := flags.Int("warn-days", 30, "Flag certificates expiring within this many days")
+	jsonOutput := flags.Bool("json", false, "Print findings as JSON")
+	var at clockFlags
+	at.register(flags, "Evaluate validity as of this RFC 3339 timestamp instead of now")
+
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s scan [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Checks certificates for weak keys, SHA-1, long validity, missing SANs, and expiry.\n")
+		fmt.Fprintf(os.Stderr, "Exits with status 1 if any error-level finding is reported.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	clock, err := at.clock()
+	if err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+
+	policy := scanPolicy{
+		MinRSABits:      *minRSABits,
+		MaxLeafValidity: *maxLeafDays,
+		ExpiryWarning:   time.Duration(*warnDays) * 24 * time.Hour,
+	}
+
+	var findings []scanFinding
+	scanned := 0
+	err = filepath.WalkDir(*dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".pem", ".crt", ".cer":
+		default:
+			return nil
+		}
+		certs, err := ca.LoadCertificates(path)
+		if err != nil {
+			// Key files and other PEM content share these extensions; only
+			// report files that look like certificates but fail to parse.
+			if !errors.Is(err, ca.ErrNoCertificates) {
+				log.Printf("Skipping %s: %v", path, err)
+			}
+			return nil
+		}
+		for _, cert := range certs {
+			scanned++
+			findings = append(findings, evaluateCertificate(path, cert, policy, clock.Now())...)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Error scanning %q: %v", *dir, err)
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Severity == severityError && findings[j].Severity != severityError
+	})
+
+	if *jsonOutput {
+		out, _ := json.MarshalIndent(findings, "", "  ")
+		fmt.Println(string(out))
+	} else {
+		fmt.Printf("Scanned %d certificates in %s: %d findings\n", scanned, *dir, len(findings))
+		for _, f := range findings {
+			fmt.Printf("\n[%s] %s\n", strings.ToUpper(f.Severity), f.Issue)
+			fmt.Printf("  File: %s\n", f.File)
+			fmt.Printf("  Subject: %s (serial %s, expires %s)\n", f.Subject, f.Serial, f.NotAfter)
+			fmt.Printf("  Remediation: %s\n", f.Remediation)
+		}
+	}
+
+	for _, f := range findings {
+		if f.Severity == severityError {
+			os.Exit(1)
+		}
+	}
+}
+
+// evaluateCertificate checks a single certificate against the policy.
+func evaluateCertificate(path string, cert *x509.Certificate, policy scanPolicy, now time.Time) []scanFinding {
+	var findings []scanFinding
+	add := func(severity, issue, remediation string) {
+		findings = append(findings, scanFinding{
+			File:        path,
+			Subject:     cert.Subject.String(),
+			Serial:      fmt.Sprintf("%X", cert.SerialNumber),
+			NotAfter:    cert.NotAfter.Format(time.RFC3339),
+			Severity:    severity,
+			Issue:       issue,
+			Remediation: remediation,
+		})
+	}
+
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if bits := key.N.BitLen(); bits < policy.MinRSABits {
+			add(severityError, fmt.Sprintf("RSA key is %d bits (minimum %d)", bits, policy.MinRSABits),
+				"Rekey with a larger RSA key or an ECDSA/Ed25519 key and reissue.")
+		}
+	case *ecdsa.PublicKey:
+		if key.Curve.Params().BitSize < 256 {
+			add(severityError, fmt.Sprintf("ECDSA key uses weak curve %s", key.Curve.Params().Name),
+				"Rekey on P-256 or stronger and reissue.")
+		}
+	}
+
+	switch cert.SignatureAlgorithm {
+	case x509.MD2WithRSA, x509.MD5WithRSA, x509.SHA1WithRSA, x509.DSAWithSHA1, x509.ECDSAWithSHA1:
+		// Self-signed roots are trusted by identity, not by their signature.
+		if !isSelfSigned(cert) {
+			add(severityError, fmt.Sprintf("signed with deprecated algorithm %s", cert.SignatureAlgorithm),
+				"Reissue with a SHA-256 (or stronger) signature.")
+		}
+	}
+
+	if !cert.IsCA {
+		validityDays := int(cert.NotAfter.Sub(cert.NotBefore).Hours() / 24)
+		if validityDays > policy.MaxLeafValidity {
+			add(severityWarning, fmt.Sprintf("leaf validity is %d days (maximum %d)", validityDays, policy.MaxLeafValidity),
+				"Reissue with a shorter lifetime and automate renewal.")
+		}
+		if len(cert.DNSNames) == 0 && len(cert.IPAddresses) == 0 && len(cert.EmailAddresses) == 0 && len(cert.URIs) == 0 {
+			add(severityError, "leaf certificate has no Subject Alternative Names",
+				"Reissue with the hostnames in the SAN extension; modern clients ignore the CN.")
+		}
+	}
+
+	switch {
+	case now.After(cert.NotAfter):
+		add(severityError, "certificate has expired", "Renew or remove it from deployment.")
+	case cert.NotAfter.Sub(now) < policy.ExpiryWarning:
+		add(severityWarning, fmt.Sprintf("certificate expires in %d days", int(cert.NotAfter.Sub(now).Hours()/24)),
+			"Renew before expiry.")
+	}
+
+	return findings
+}
+
+// isSelfSigned reports whether cert names itself as issuer. The signature is
+// deliberately not checked: Go refuses to verify SHA-1 signatures at all.
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject)
+}
@@ -0,0 +1,71 @@
+// selfsign.go
+package main
+
+import (
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/prtk1729/certA/pkg/ca"
+)
+
+// runSelfSign implements the selfsign command: it turns a CSR and its private
+// key into a self-signed certificate carrying the requested subject and
+// extensions.
+func runSelfSign(args []string) {
+	fs := flag.NewFlagSet("selfsign", flag.ExitOnError)
+	csrFile := fs.String("csr", "", "Required: PKCS#10 certificate request PEM file")
+	keyFile := fs.String("key", "", "Required: private key PEM file matching the CSR")
+	outFile := fs.String("out", "", "Required: path to write the certificate PEM file")
+	days := fs.Int("days", 365, "Validity period in days")
+	var keyPassphrase passphraseFlags
+	keyPassphrase.register(fs, "", "decrypt the private key with")
+	var reproducible reproducibleFlags
+	reproducible.register(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s selfsign -csr req.csr -key req.key -out cert.crt [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Creates a self-signed certificate from a CSR and its private key.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if err := checkFlags(fs); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	if *csrFile == "" || *keyFile == "" || *outFile == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	csr, err := ca.LoadCertificateRequest(*csrFile)
+	if err != nil {
+		log.Fatalf("Error loading CSR: %v", err)
+	}
+	key, err := ca.LoadPrivateKey(*keyFile, keyPassphrase.source())
+	if err != nil {
+		log.Fatalf("Error loading private key: %v", err)
+	}
+
+	random, clock, err := reproducible.source()
+	if err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+	if err := checkReproducibleSigner(random, key); err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+	certBytes, err := ca.SelfSignCSR(csr, key, *days, clock, random)
+	if err != nil {
+		log.Fatalf("Error creating certificate: %v", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certBytes})
+	if err := os.WriteFile(*outFile, certPEM, 0644); err != nil {
+		log.Fatalf("Error writing certificate: %v", err)
+	}
+
+	fmt.Printf("Self-signed certificate for %q saved to: %s\n", csr.Subject.CommonName, *outFile)
+}
@@ -0,0 +1,708 @@
+// serve.go
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prtk1729/certA/pkg/ca"
+)
+
+const (
+	defaultAPIPort      = 8000
+	maxAPIRequestSize   = 64 << 10
+	minAPITokenLength   = 16
+	apiTokenFileComment = "#"
+)
+
+// apiToken is one bearer token from the token file, stored hashed so the
+// comparison takes the same time whatever the token.
+type apiToken struct {
+	name string
+	hash [sha256.Size]byte
+}
+
+// apiServer serves the HTTP JSON API of one CA.
+type apiServer struct {
+	issuer        *ca.CA
+	caCertFile    string
+	chain         []*x509.Certificate // The CA certificate file, served by GET /ca
+	dbPath        string
+	tokens        []apiToken     // nil unless -token-file is given
+	clientCAs     *x509.CertPool // nil unless -client-ca is given
+	policy        *apiPolicyFile // nil unless -policy is given
+	days          int
+	serials       serialFlags
+	sanPolicy     ca.SANPolicy
+	denylist      denylistFlags
+	crlNextUpdate time.Duration
+
+	// mu serializes database updates and guards the CRL cache, which is
+	// signed again when a revocation is recorded or half its lifetime has
+	// passed.
+	mu         sync.Mutex
+	keys       *ca.KeyDenylist
+	crl        []byte
+	crlNumber  int64
+	crlRevoked int
+	crlRefresh time.Time
+}
+
+// apiError is the body of every error response.
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// runServe implements the serve command: an authenticated HTTP JSON API for
+// issuing and revoking certificates without shell access to the CA host.
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	caCertFile := fs.String("ca", defaultCertFileName, "Issuing CA certificate PEM file; certificates after the first are served as its chain")
+	caKeyFile := fs.String("ca-key", defaultKeyFileName, "Issuing CA private key PEM file")
+	var db certDBFlags
+	db.register(fs)
+	tokenFile := fs.String("token-file", "", "File of bearer tokens clients authenticate with, one per line, each optionally preceded by a client name")
+	clientCAFile := fs.String("client-ca", "", "Require client certificates issued by the CAs in this PEM file (e.g. -ca itself, or an admin CA); needs -tls-cert")
+	policyFile := fs.String("policy", "", "Optional: YAML file of the names and profiles each client may request")
+	host := fs.String("host", "localhost", "Interface to listen on; \"\" for all interfaces, which requires -tls-cert")
+	port := fs.Int("port", defaultAPIPort, "TCP port to listen on")
+	tlsCertFile := fs.String("tls-cert", "", "Serve HTTPS with this certificate PEM file; required unless -host is a loopback address")
+	tlsKeyFile := fs.String("tls-key", "", "Private key PEM file for -tls-cert")
+	validityDays := fs.Int("days", defaultLeafValidityDays, "Default and maximum validity of issued certificates in days")
+	nextUpdate := fs.Duration("next-update", defaultCRLNextUpdate, "How long each CRL served by GET /crl stays current")
+	var serials serialFlags
+	serials.register(fs)
+	var sanPolicy sanPolicyFlags
+	sanPolicy.register(fs)
+	var denylist denylistFlags
+	denylist.register(fs)
+	var caPassphrase passphraseFlags
+	caPassphrase.register(fs, "ca-", "decrypt the CA private key with")
+
+	var cfg configFlags
+	cfg.register(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s serve -ca ca.crt -ca-key ca.key (-token-file tokens.txt | -client-ca ca.crt -tls-cert server.crt -tls-key server.key) [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Runs an HTTP JSON API for the CA. Requests to /sign, /certs and /revoke must carry\n")
+		fmt.Fprintf(os.Stderr, "'Authorization: Bearer <token>' with a token from -token-file, a client certificate\n")
+		fmt.Fprintf(os.Stderr, "from -client-ca, or both if both are given; /ca and /crl are public. Client\n")
+		fmt.Fprintf(os.Stderr, "certificates revoked in the -ca database are refused. With -policy, each client\n")
+		fmt.Fprintf(os.Stderr, "may only request, list and revoke the names and profiles its entry allows. Clients\n")
+		fmt.Fprintf(os.Stderr, "are named by their token, and their certificates are recognised by the SPKI pins\n")
+		fmt.Fprintf(os.Stderr, "of their keys (see inspect), not by Common Name; with both -token-file and\n")
+		fmt.Fprintf(os.Stderr, "-client-ca, -policy is required and token and certificate must name one client:\n\n")
+		fmt.Fprintf(os.Stderr, "  clients:\n")
+		fmt.Fprintf(os.Stderr, "    deploy-bot:\n")
+		fmt.Fprintf(os.Stderr, "      spki: [sha256/...]\n")
+		fmt.Fprintf(os.Stderr, "      dns: [internal.example.com]\n")
+		fmt.Fprintf(os.Stderr, "      ip: [10.0.0.0/8]\n")
+		fmt.Fprintf(os.Stderr, "      profiles: [server]\n\n")
+		fmt.Fprintf(os.Stderr, "  POST /sign    {\"csr\": PEM, \"days\": n, \"profile\": name} -> certificate, chain, serial\n")
+		fmt.Fprintf(os.Stderr, "  GET  /ca      CA certificate and chain\n")
+		fmt.Fprintf(os.Stderr, "  GET  /crl     Current CRL, DER (?format=pem for PEM)\n")
+		fmt.Fprintf(os.Stderr, "  GET  /certs   Issued certificates (?status=%s)\n", strings.Join(listStatuses, "|"))
+		fmt.Fprintf(os.Stderr, "  POST /revoke  {\"serial\": hex | \"certificate\": PEM, \"reason\": name}\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+		printProfiles(os.Stderr)
+	}
+	fs.Parse(args)
+	cfg.apply(fs)
+	if err := checkFlags(fs); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := serials.check(); err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+	if *tokenFile == "" && *clientCAFile == "" {
+		fs.Usage()
+		log.Fatal("Error: -token-file or -client-ca is required.")
+	}
+	if (*tlsCertFile == "") != (*tlsKeyFile == "") {
+		log.Fatal("Error: -tls-cert and -tls-key must be given together.")
+	}
+	if *clientCAFile != "" && *tlsCertFile == "" {
+		log.Fatal("Error: -client-ca requires -tls-cert and -tls-key.")
+	}
+	// Bearer tokens and CSRs must not cross the network in clear text.
+	if *tlsCertFile == "" && !isLoopbackHost(*host) {
+		log.Fatalf("Error: serving on %q without TLS would send tokens in clear text; give -tls-cert and -tls-key, or listen on localhost.", *host)
+	}
+
+	var tokens []apiToken
+	var err error
+	if *tokenFile != "" {
+		if tokens, err = loadAPITokens(*tokenFile); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	}
+	var clientCAs *x509.CertPool
+	if *clientCAFile != "" {
+		certs, err := ca.LoadCertificates(*clientCAFile)
+		if err != nil {
+			log.Fatalf("Error loading client CAs: %v", err)
+		}
+		clientCAs = x509.NewCertPool()
+		for _, cert := range certs {
+			clientCAs.AddCert(cert)
+		}
+	}
+	var policy *apiPolicyFile
+	if *policyFile != "" {
+		if policy, err = loadAPIPolicy(*policyFile); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	} else if tokens != nil && clientCAs != nil {
+		log.Fatal("Error: -token-file with -client-ca requires -policy, which binds each client's token to its certificate keys.")
+	}
+	issuer, err := ca.LoadCA(*caCertFile, *caKeyFile, caPassphrase.source())
+	if err != nil {
+		log.Fatalf("Error loading issuing CA: %v", err)
+	}
+	chain, err := ca.LoadCertificates(*caCertFile)
+	if err != nil {
+		log.Fatalf("Error loading CA chain: %v", err)
+	}
+	server := &apiServer{
+		issuer:        issuer,
+		caCertFile:    *caCertFile,
+		chain:         chain,
+		dbPath:        db.resolve(*caCertFile),
+		tokens:        tokens,
+		clientCAs:     clientCAs,
+		policy:        policy,
+		days:          *validityDays,
+		serials:       serials,
+		denylist:      denylist,
+		crlNextUpdate: *nextUpdate,
+	}
+	if server.sanPolicy, err = sanPolicy.policy(); err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+	if server.keys, err = denylist.load(*caCertFile); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	// Fail at startup rather than on the first request.
+	if _, err := loadCertDB(server.dbPath); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	addr := net.JoinHostPort(*host, strconv.Itoa(*port))
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           server.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if clientCAs != nil {
+		// Certificates are checked per request rather than in the handshake,
+		// so that /ca and /crl stay public.
+		httpServer.TLSConfig = &tls.Config{
+			ClientCAs:  clientCAs,
+			ClientAuth: tls.VerifyClientCertIfGiven,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+
+	fmt.Printf("Serving the CA API on %s for %s\n", addr, issuer.Certificate.Subject)
+	if *tokenFile != "" {
+		fmt.Printf("  Clients: %d tokens from %s\n", len(tokens), *tokenFile)
+	}
+	if *clientCAFile != "" {
+		fmt.Printf("  Clients: certificates issued by %s\n", *clientCAFile)
+	}
+	if policy != nil {
+		fmt.Printf("  Client policy: %d clients from %s\n", len(policy.Clients), *policyFile)
+	}
+	fmt.Printf("  Certificate validity: up to %d days\n", *validityDays)
+	fmt.Printf("  Certificate database: %s (press Ctrl+C to stop)\n", server.dbPath)
+	if err := serveUntilInterrupted(httpServer, *tlsCertFile, *tlsKeyFile); err != nil {
+		log.Fatalf("Error running API server: %v", err)
+	}
+	fmt.Println("API server stopped.")
+}
+
+// serveUntilInterrupted runs srv until Ctrl+C, then shuts it down cleanly so
+// the port is released immediately. It serves TLS if certFile is set or
+// srv.TLSConfig carries the certificate, and plain HTTP otherwise.
+func serveUntilInterrupted(srv *http.Server, certFile, keyFile string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	var err error
+	if certFile != "" || (srv.TLSConfig != nil && (len(srv.TLSConfig.Certificates) > 0 || srv.TLSConfig.GetCertificate != nil)) {
+		err = srv.ListenAndServeTLS(certFile, keyFile)
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// loadAPITokens reads a token file: one token per line, optionally preceded
+// by a client name for the log. Blank lines and # comments are skipped.
+func loadAPITokens(path string) ([]apiToken, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token file: %w", err)
+	}
+	defer f.Close()
+
+	var tokens []apiToken
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], apiTokenFileComment) {
+			continue
+		}
+		token := apiToken{name: fmt.Sprintf("token on line %d", line)}
+		switch len(fields) {
+		case 1:
+		case 2:
+			token.name = fields[0]
+		default:
+			return nil, fmt.Errorf("%s:%d: expected a token, optionally preceded by a client name", path, line)
+		}
+		secret := fields[len(fields)-1]
+		if len(secret) < minAPITokenLength {
+			return nil, fmt.Errorf("%s:%d: tokens must be at least %d characters", path, line, minAPITokenLength)
+		}
+		token.hash = sha256.Sum256([]byte(secret))
+		tokens = append(tokens, token)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read token file: %w", err)
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("no tokens in %s", path)
+	}
+	return tokens, nil
+}
+
+func (s *apiServer) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ca", s.public(http.MethodGet, s.handleCA))
+	mux.HandleFunc("/crl", s.public(http.MethodGet, s.handleCRL))
+	mux.HandleFunc("/sign", s.authenticated(http.MethodPost, s.handleSign))
+	mux.HandleFunc("/certs", s.authenticated(http.MethodGet, s.handleCerts))
+	mux.HandleFunc("/revoke", s.authenticated(http.MethodPost, s.handleRevoke))
+	return mux
+}
+
+// apiFile is a response body that is not JSON.
+type apiFile struct {
+	contentType string
+	data        []byte
+}
+
+// apiClient is the authenticated sender of a request.
+type apiClient struct {
+	name   string
+	policy *apiClientPolicy // nil when serving without -policy
+}
+
+func (c apiClient) String() string { return c.name }
+
+// apiHandler handles one request for client and returns the response status
+// and body: an apiFile, or anything else to be encoded as JSON.
+type apiHandler func(r *http.Request, client apiClient) (int, any)
+
+func (s *apiServer) public(method string, handler apiHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.serve(w, r, method, apiClient{name: "anonymous"}, handler)
+	}
+}
+
+func (s *apiServer) authenticated(method string, handler apiHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name, err := s.authenticate(r)
+		if err != nil {
+			if s.tokens != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="ca"`)
+			}
+			writeJSON(w, http.StatusUnauthorized, apiError{err.Error()})
+			log.Printf("%s %s %s: unauthorized: %v", r.RemoteAddr, r.Method, r.URL.Path, err)
+			return
+		}
+		client := apiClient{name: name}
+		if s.policy != nil {
+			if client.policy = s.policy.Clients[name]; client.policy == nil {
+				writeJSON(w, http.StatusForbidden, apiError{fmt.Sprintf("client %q is not in the policy file", name)})
+				log.Printf("%s %s %s (%s): not in the policy file", r.RemoteAddr, r.Method, r.URL.Path, name)
+				return
+			}
+		}
+		s.serve(w, r, method, client, handler)
+	}
+}
+
+// authenticate returns the name of the client r comes from. A certificate is
+// named by the -policy entry listing its key, or without -policy by its key
+// pin; a token by its name. When both are configured, both are required and
+// must name the same client.
+func (s *apiServer) authenticate(r *http.Request) (string, error) {
+	var name string
+	if s.clientCAs != nil {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			return "", errors.New("a client certificate is required")
+		}
+		cert := r.TLS.VerifiedChains[0][0]
+		pin, err := ca.SPKIPin(cert.PublicKey)
+		if err != nil {
+			return "", fmt.Errorf("client certificate key: %w", err)
+		}
+		name = "sha256/" + pin
+		if s.policy != nil {
+			if client, ok := s.policy.clientForKey(pin); ok {
+				name = client
+			}
+		}
+		// Only revocations by this CA are known; certificates from a
+		// separate admin CA are trusted until they expire.
+		if s.issuer.Issued(cert) {
+			certs, err := loadCertDB(s.dbPath)
+			if err != nil {
+				log.Printf("Error: %v", err)
+				return "", errors.New("failed to check the client certificate")
+			}
+			if record := certs.find(fmt.Sprintf("%X", cert.SerialNumber)); record != nil && record.Status == statusRevoked {
+				return "", errors.New("client certificate has been revoked")
+			}
+		}
+	}
+	if s.tokens != nil {
+		tokenName, ok := s.checkToken(r)
+		if !ok {
+			return "", errors.New("missing or unknown bearer token")
+		}
+		if name != "" && name != tokenName {
+			return "", fmt.Errorf("bearer token of %q does not belong with the client certificate of %q", tokenName, name)
+		}
+		name = tokenName
+	}
+	return name, nil
+}
+
+// checkToken returns the name of the client whose token r carries.
+func (s *apiServer) checkToken(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return "", false
+	}
+	hash := sha256.Sum256([]byte(strings.TrimSpace(token)))
+	for _, t := range s.tokens {
+		if subtle.ConstantTimeCompare(hash[:], t.hash[:]) == 1 {
+			return t.name, true
+		}
+	}
+	return "", false
+}
+
+func (s *apiServer) serve(w http.ResponseWriter, r *http.Request, method string, client apiClient, handler apiHandler) {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		writeJSON(w, http.StatusMethodNotAllowed, apiError{fmt.Sprintf("%s requires %s", r.URL.Path, method)})
+		return
+	}
+	status, body := handler(r, client)
+	switch body := body.(type) {
+	case apiFile:
+		w.Header().Set("Content-Type", body.contentType)
+		w.WriteHeader(status)
+		w.Write(body.data)
+	case apiError:
+		writeJSON(w, status, body)
+		log.Printf("%s %s %s (%s): %d %s", r.RemoteAddr, r.Method, r.URL.Path, client, status, body.Error)
+		return
+	default:
+		writeJSON(w, status, body)
+	}
+	log.Printf("%s %s %s (%s): %d", r.RemoteAddr, r.Method, r.URL.Path, client, status)
+}
+
+// decodeAPIRequest reads a JSON request body into v.
+func decodeAPIRequest(r *http.Request, v any) error {
+	decoder := json.NewDecoder(io.LimitReader(r.Body, maxAPIRequestSize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("request body is not valid JSON: %w", err)
+	}
+	return nil
+}
+
+func (s *apiServer) handleCA(r *http.Request, _ apiClient) (int, any) {
+	var chain []byte
+	for _, cert := range s.chain {
+		chain = append(chain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	cert := s.issuer.Certificate
+	return http.StatusOK, map[string]any{
+		"subject":     cert.Subject.String(),
+		"serial":      fmt.Sprintf("%X", cert.SerialNumber),
+		"not_after":   cert.NotAfter.UTC(),
+		"certificate": string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})),
+		"chain":       string(chain),
+	}
+}
+
+// handleCRL serves the current CRL, signing a new one when the cached one
+// is stale.
+func (s *apiServer) handleCRL(r *http.Request, _ apiClient) (int, any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := lockCertDB(s.dbPath)
+	if err != nil {
+		log.Printf("Error: %v", err)
+		return http.StatusInternalServerError, apiError{"failed to lock the certificate database"}
+	}
+	defer unlock()
+	certs, err := loadCertDB(s.dbPath)
+	if err != nil {
+		log.Printf("Error: %v", err)
+		return http.StatusInternalServerError, apiError{"failed to read the certificate database"}
+	}
+	revoked := len(certs.revoked())
+	if s.crl == nil || revoked != s.crlRevoked || certs.CRLNumber != s.crlNumber || time.Now().After(s.crlRefresh) {
+		crl, err := certs.signCRL(s.issuer, s.crlNextUpdate, nil)
+		if err != nil {
+			log.Printf("Error generating CRL: %v", err)
+			return http.StatusInternalServerError, apiError{"failed to generate the CRL"}
+		}
+		if err := certs.save(s.dbPath); err != nil {
+			log.Printf("Error: %v", err)
+			return http.StatusInternalServerError, apiError{"failed to update the certificate database"}
+		}
+		s.crl, s.crlNumber, s.crlRevoked = crl, certs.CRLNumber, revoked
+		s.crlRefresh = time.Now().Add(s.crlNextUpdate / 2)
+	}
+
+	if r.URL.Query().Get("format") == "pem" {
+		return http.StatusOK, apiFile{"application/x-pem-file", pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: s.crl})}
+	}
+	return http.StatusOK, apiFile{"application/pkix-crl", s.crl}
+}
+
+// apiSignRequest is the body of POST /sign.
+type apiSignRequest struct {
+	CSR     string `json:"csr"`     // PEM, or base64 DER
+	Days    int    `json:"days"`    // Optional: at most -days
+	Profile string `json:"profile"` // Optional: issuance profile name, or "default" for none
+}
+
+func (s *apiServer) handleSign(r *http.Request, client apiClient) (int, any) {
+	var request apiSignRequest
+	if err := decodeAPIRequest(r, &request); err != nil {
+		return http.StatusBadRequest, apiError{err.Error()}
+	}
+	csr, err := ca.ParseCertificateRequest([]byte(request.CSR))
+	if err != nil {
+		return http.StatusBadRequest, apiError{err.Error()}
+	}
+	if request.Days == 0 {
+		request.Days = s.days
+	}
+	if request.Days < 0 || request.Days > s.days {
+		return http.StatusBadRequest, apiError{fmt.Sprintf("days must be between 1 and %d", s.days)}
+	}
+	opts := ca.IssueOptions{
+		ValidityDays: request.Days,
+		SerialBits:   s.serials.bits,
+		ExtKeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		SANPolicy:    s.sanPolicy,
+	}
+	if request.Profile == apiDefaultProfile {
+		request.Profile = ""
+	}
+	if request.Profile != "" {
+		if opts.Profile, err = ca.LookupProfile(request.Profile); err != nil {
+			return http.StatusBadRequest, apiError{err.Error()}
+		}
+	}
+	if client.policy != nil {
+		names := csrNames(csr.Subject.CommonName, ca.IssueOptions{
+			DNSNames:       csr.DNSNames,
+			IPAddresses:    csr.IPAddresses,
+			EmailAddresses: csr.EmailAddresses,
+			URIs:           csr.URIs,
+		})
+		if err := client.policy.check(names, request.Profile); err != nil {
+			return http.StatusForbidden, apiError{fmt.Sprintf("not allowed for client %q: %v", client.name, err)}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	opts.Denylist = s.keys
+	var seq uint64
+	if opts.SerialNumber, seq, err = s.serials.allocate(s.dbPath, nil); err != nil {
+		log.Printf("Error: %v", err)
+		return http.StatusInternalServerError, apiError{"failed to allocate a serial number"}
+	}
+	cert, err := s.issuer.SignCSR(csr, opts)
+	if err != nil {
+		return http.StatusUnprocessableEntity, apiError{err.Error()}
+	}
+	if err := recordIssued(s.dbPath, cert, "", seq); err != nil {
+		log.Printf("Error recording certificate: %v", err)
+		return http.StatusInternalServerError, apiError{"failed to record the certificate"}
+	}
+	log.Printf("Issued serial %X for %s to %s", cert.SerialNumber, cert.Subject, client)
+
+	var chain []byte
+	for _, c := range s.chain {
+		chain = append(chain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})...)
+	}
+	return http.StatusCreated, map[string]any{
+		"serial":      fmt.Sprintf("%X", cert.SerialNumber),
+		"subject":     cert.Subject.String(),
+		"not_before":  cert.NotBefore.UTC(),
+		"not_after":   cert.NotAfter.UTC(),
+		"certificate": string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})),
+		"chain":       string(chain),
+	}
+}
+
+func (s *apiServer) handleCerts(r *http.Request, client apiClient) (int, any) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = "all"
+	}
+	if !slices.Contains(listStatuses, status) {
+		return http.StatusBadRequest, apiError{fmt.Sprintf("unknown status %q (use %s)", status, strings.Join(listStatuses, ", "))}
+	}
+	certs, err := loadCertDB(s.dbPath)
+	if err != nil {
+		log.Printf("Error: %v", err)
+		return http.StatusInternalServerError, apiError{"failed to read the certificate database"}
+	}
+	now := time.Now()
+	var records []*certRecord
+	for _, record := range certs.Certificates {
+		if client.policy != nil && !client.policy.allowsRecord(record) {
+			continue
+		}
+		if status == "all" || record.status(now) == status {
+			records = append(records, record)
+		}
+	}
+	return http.StatusOK, listedRecords(records, now)
+}
+
+// apiRevokeRequest is the body of POST /revoke.
+type apiRevokeRequest struct {
+	Serial      string `json:"serial"`      // Hex serial number, or
+	Certificate string `json:"certificate"` // the certificate in PEM
+	Reason      string `json:"reason"`      // Optional: a revoke -reason name
+}
+
+func (s *apiServer) handleRevoke(r *http.Request, client apiClient) (int, any) {
+	var request apiRevokeRequest
+	if err := decodeAPIRequest(r, &request); err != nil {
+		return http.StatusBadRequest, apiError{err.Error()}
+	}
+	if (request.Serial == "") == (request.Certificate == "") {
+		return http.StatusBadRequest, apiError{"exactly one of serial and certificate is required"}
+	}
+	if request.Reason == "" {
+		request.Reason = defaultRevocationReason
+	}
+	if _, ok := ca.RevocationReasonNames[request.Reason]; !ok {
+		return http.StatusBadRequest, apiError{fmt.Sprintf("unknown reason %q (use %s)", request.Reason, revocationReasonList())}
+	}
+
+	var serial string
+	var key crypto.PublicKey
+	if request.Certificate != "" {
+		block, _ := pem.Decode([]byte(request.Certificate))
+		if block == nil || block.Type != "CERTIFICATE" {
+			return http.StatusBadRequest, apiError{"certificate is not a PEM certificate"}
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return http.StatusBadRequest, apiError{err.Error()}
+		}
+		if !s.issuer.Issued(cert) {
+			return http.StatusBadRequest, apiError{fmt.Sprintf("%s was not issued by %s", cert.Subject, s.issuer.Certificate.Subject)}
+		}
+		serial, key = fmt.Sprintf("%X", cert.SerialNumber), cert.PublicKey
+	} else {
+		n, err := parseSerial(request.Serial)
+		if err != nil {
+			return http.StatusBadRequest, apiError{err.Error()}
+		}
+		serial = fmt.Sprintf("%X", n)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := lockCertDB(s.dbPath)
+	if err != nil {
+		log.Printf("Error: %v", err)
+		return http.StatusInternalServerError, apiError{"failed to lock the certificate database"}
+	}
+	defer unlock()
+	certs, err := loadCertDB(s.dbPath)
+	if err != nil {
+		log.Printf("Error: %v", err)
+		return http.StatusInternalServerError, apiError{"failed to read the certificate database"}
+	}
+	record := certs.find(serial)
+	if record == nil {
+		return http.StatusNotFound, apiError{fmt.Sprintf("serial %s is not in the certificate database", serial)}
+	}
+	if client.policy != nil && !client.policy.allowsRecord(record) {
+		return http.StatusForbidden, apiError{fmt.Sprintf("client %q may not revoke serial %s", client.name, serial)}
+	}
+	if record.Status == statusRevoked {
+		return http.StatusConflict, apiError{fmt.Sprintf("serial %s was already revoked at %s (%s)", serial, record.RevokedAt.Format(time.RFC3339), record.Reason)}
+	}
+	revokedAt := time.Now().UTC()
+	record.Status = statusRevoked
+	record.Reason = request.Reason
+	record.RevokedAt = &revokedAt
+	if err := certs.save(s.dbPath); err != nil {
+		log.Printf("Error: %v", err)
+		return http.StatusInternalServerError, apiError{"failed to update the certificate database"}
+	}
+	log.Printf("Revoked serial %s (%s) for %s", serial, request.Reason, client)
+
+	if key != nil && request.Reason == "key-compromise" {
+		// A compromised key must not be certified again under a new serial.
+		if _, _, err := s.denylist.denyCompromised(s.caCertFile, key, serial); err != nil {
+			log.Printf("Error: %v", err)
+		} else if keys, err := s.denylist.load(s.caCertFile); err == nil {
+			s.keys = keys
+		}
+	}
+	return http.StatusOK, listedRecord{record, record.status(revokedAt)}
+}
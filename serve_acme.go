@@ -0,0 +1,1014 @@
+// serve_acme.go
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prtk1729/certA/pkg/ca"
+)
+
+const (
+	defaultACMEPort         = 14000
+	defaultACMEValidityDays = 90
+	defaultACMEHTTPPort     = 80 // Where http-01 challenges are fetched from
+	acmeOrderLifetime       = 7 * 24 * time.Hour
+	acmeValidationTimeout   = 10 * time.Second
+	maxACMERedirects        = 5 // Redirects followed while fetching an http-01 challenge
+	maxACMERequestSize      = 64 << 10
+	maxACMENonces           = 10000 // Outstanding nonces; older ones are dropped
+	maxKeyAuthorizationSize = 1 << 10
+)
+
+// ACME object statuses (RFC 8555, section 7.1.6).
+const (
+	acmeStatusPending     = "pending"
+	acmeStatusProcessing  = "processing"
+	acmeStatusReady       = "ready"
+	acmeStatusValid       = "valid"
+	acmeStatusInvalid     = "invalid"
+	acmeStatusDeactivated = "deactivated"
+	acmeStatusExpired     = "expired"
+)
+
+// Challenge types. tls-alpn-01 is not offered.
+const (
+	acmeChallengeHTTP01 = "http-01"
+	acmeChallengeDNS01  = "dns-01"
+)
+
+// acmeProblem is an RFC 7807 problem document with an ACME error type.
+type acmeProblem struct {
+	Type   string `json:"type"`
+	Detail string `json:"detail"`
+	Status int    `json:"status,omitempty"`
+}
+
+func acmeError(status int, kind, format string, args ...any) *acmeProblem {
+	return &acmeProblem{Type: "urn:ietf:params:acme:error:" + kind, Detail: fmt.Sprintf(format, args...), Status: status}
+}
+
+type acmeIdentifier struct {
+	Type  string `json:"type"` // "dns" or "ip" (RFC 8738)
+	Value string `json:"value"`
+}
+
+type acmeAccount struct {
+	id         string
+	key        crypto.PublicKey
+	thumbprint string
+
+	Status  string   `json:"status"`
+	Contact []string `json:"contact,omitempty"`
+}
+
+type acmeOrder struct {
+	id      string
+	account string
+	authzs  []*acmeAuthz
+
+	Status         string           `json:"status"`
+	Expires        time.Time        `json:"expires"`
+	Identifiers    []acmeIdentifier `json:"identifiers"`
+	Authorizations []string         `json:"authorizations"`
+	Finalize       string           `json:"finalize"`
+	Certificate    string           `json:"certificate,omitempty"`
+	Error          *acmeProblem     `json:"error,omitempty"`
+}
+
+type acmeAuthz struct {
+	id      string
+	account string
+
+	Status     string           `json:"status"`
+	Expires    time.Time        `json:"expires"`
+	Identifier acmeIdentifier   `json:"identifier"`
+	Challenges []*acmeChallenge `json:"challenges"`
+	Wildcard   bool             `json:"wildcard,omitempty"`
+}
+
+type acmeChallenge struct {
+	id    string
+	authz *acmeAuthz
+
+	Type      string       `json:"type"`
+	URL       string       `json:"url"`
+	Status    string       `json:"status"`
+	Token     string       `json:"token"`
+	Validated *time.Time   `json:"validated,omitempty"`
+	Error     *acmeProblem `json:"error,omitempty"`
+}
+
+type acmeCert struct {
+	account string
+	der     []byte
+}
+
+// acmeServer is an RFC 8555 ACME server issuing from one CA. Accounts,
+// orders and authorizations live in memory; issued certificates are
+// recorded in the CA's certificate database like any other.
+type acmeServer struct {
+	issuer     *ca.CA
+	caCertFile string
+	chainPEM   []byte // Certificates served after the leaf
+	dbPath     string
+	baseURL    string
+	days       int
+	serials    serialFlags
+	sanPolicy  ca.SANPolicy
+	denylist   denylistFlags
+	keys       *ca.KeyDenylist
+	dev        bool // Accept every challenge without checking it
+	httpPort   int
+	client     *http.Client // Fetches http-01 challenges
+	resolver   *net.Resolver
+
+	mu         sync.Mutex
+	nonces     map[string]time.Time
+	accounts   map[string]*acmeAccount
+	orders     map[string]*acmeOrder
+	authzs     map[string]*acmeAuthz
+	challenges map[string]*acmeChallenge
+	certs      map[string]*acmeCert
+}
+
+// acmeRequest is a verified POST request.
+type acmeRequest struct {
+	payload []byte
+	account *acmeAccount     // Set for requests signed with an account URL (kid)
+	jwk     *acmeJWK         // Set for requests signed with an embedded key
+	key     crypto.PublicKey // The key the request was signed with
+}
+
+// isLoopbackHost reports whether listening on host only accepts connections
+// from this machine.
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// runServeACME implements the serve-acme command: a minimal ACME server
+// backed by the CA, for local development with ACME clients.
+func runServeACME(args []string) {
+	fs := flag.NewFlagSet("serve-acme", flag.ExitOnError)
+	caCertFile := fs.String("ca", defaultCertFileName, "Issuing CA certificate PEM file; certificates after the first are served as its chain")
+	caKeyFile := fs.String("ca-key", defaultKeyFileName, "Issuing CA private key PEM file")
+	var db certDBFlags
+	db.register(fs)
+	host := fs.String("host", "localhost", "Interface to listen on; \"\" for all interfaces")
+	port := fs.Int("port", defaultACMEPort, "TCP port to listen on")
+	baseURL := fs.String("base-url", "", "URL clients reach the server at (default: http(s)://localhost:<port>)")
+	tlsCertFile := fs.String("tls-cert", "", "Optional: serve HTTPS with this certificate PEM file, e.g. one issued by this CA; most ACME clients require HTTPS")
+	tlsKeyFile := fs.String("tls-key", "", "Private key PEM file for -tls-cert")
+	validityDays := fs.Int("days", defaultACMEValidityDays, "Validity period of issued certificates in days")
+	dev := fs.Bool("dev", false, "Accept every challenge without checking it; for local development only, so -host must be a loopback address")
+	httpPort := fs.Int("http-port", defaultACMEHTTPPort, "Port http-01 challenges are fetched from")
+	dnsServer := fs.String("dns-server", "", "DNS server (host:port) to query for dns-01 challenges (default: the system resolver)")
+	var serials serialFlags
+	serials.register(fs)
+	var sanPolicy sanPolicyFlags
+	sanPolicy.register(fs)
+	var denylist denylistFlags
+	denylist.register(fs)
+	var caPassphrase passphraseFlags
+	caPassphrase.register(fs, "ca-", "decrypt the CA private key with")
+
+	var cfg configFlags
+	cfg.register(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s serve-acme -ca ca.crt -ca-key ca.key [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Runs an ACME (RFC 8555) server backed by the CA, so clients such as cert-manager,\n")
+		fmt.Fprintf(os.Stderr, "Caddy and Traefik can obtain certificates automatically. Point them at the\n")
+		fmt.Fprintf(os.Stderr, "directory URL <base-url>/directory. Identifiers are validated with http-01 or\n")
+		fmt.Fprintf(os.Stderr, "dns-01 challenges, or not at all with -dev. Accounts and orders are kept in\n")
+		fmt.Fprintf(os.Stderr, "memory and lost on restart; issued certificates are recorded in the database.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	cfg.apply(fs)
+	if err := checkFlags(fs); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if err := serials.check(); err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+	if (*tlsCertFile == "") != (*tlsKeyFile == "") {
+		log.Fatal("Error: -tls-cert and -tls-key must be given together.")
+	}
+	// Without validation anyone who reaches the port gets certificates from
+	// the real CA key, so -dev never listens beyond this machine.
+	if *dev && !isLoopbackHost(*host) {
+		log.Fatalf("Error: -dev accepts every challenge, so -host must be localhost or a loopback address, got %q.", *host)
+	}
+
+	issuer, err := ca.LoadCA(*caCertFile, *caKeyFile, caPassphrase.source())
+	if err != nil {
+		log.Fatalf("Error loading issuing CA: %v", err)
+	}
+	chain, err := ca.LoadCertificates(*caCertFile)
+	if err != nil {
+		log.Fatalf("Error loading CA chain: %v", err)
+	}
+	server := &acmeServer{
+		issuer:     issuer,
+		caCertFile: *caCertFile,
+		denylist:   denylist,
+		dbPath:     db.resolve(*caCertFile),
+		days:       *validityDays,
+		serials:    serials,
+		dev:        *dev,
+		httpPort:   *httpPort,
+		client:     newHTTP01Client(*dev),
+		resolver:   net.DefaultResolver,
+		nonces:     map[string]time.Time{},
+		accounts:   map[string]*acmeAccount{},
+		orders:     map[string]*acmeOrder{},
+		authzs:     map[string]*acmeAuthz{},
+		challenges: map[string]*acmeChallenge{},
+		certs:      map[string]*acmeCert{},
+	}
+	// Relying parties already have the root; serve only what leads to it.
+	for _, cert := range chain {
+		if !isSelfSigned(cert) {
+			server.chainPEM = append(server.chainPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+		}
+	}
+	if server.sanPolicy, err = sanPolicy.policy(); err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+	if server.keys, err = denylist.load(*caCertFile); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if *dnsServer != "" {
+		dialer := &net.Dialer{Timeout: acmeValidationTimeout}
+		server.resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, *dnsServer)
+			},
+		}
+	}
+	// Fail at startup rather than on the first issuance.
+	if _, err := loadCertDB(server.dbPath); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	addr := net.JoinHostPort(*host, strconv.Itoa(*port))
+	server.baseURL = strings.TrimSuffix(*baseURL, "/")
+	if server.baseURL == "" {
+		scheme := "http"
+		if *tlsCertFile != "" {
+			scheme = "https"
+		}
+		server.baseURL = fmt.Sprintf("%s://localhost:%d", scheme, *port)
+	}
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           server.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	fmt.Printf("Serving ACME on %s for %s\n", addr, issuer.Certificate.Subject)
+	fmt.Printf("  Directory: %s/directory\n", server.baseURL)
+	if *dev {
+		fmt.Println("  Challenges: accepted without validation (-dev)")
+	} else {
+		fmt.Printf("  Challenges: http-01 (port %d), dns-01\n", *httpPort)
+	}
+	fmt.Printf("  Certificate validity: %d days\n", *validityDays)
+	fmt.Printf("  Certificate database: %s (press Ctrl+C to stop)\n", server.dbPath)
+	if err := serveUntilInterrupted(httpServer, *tlsCertFile, *tlsKeyFile); err != nil {
+		log.Fatalf("Error running ACME server: %v", err)
+	}
+	fmt.Println("ACME server stopped.")
+}
+
+func (s *acmeServer) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/directory", s.handleDirectory)
+	mux.HandleFunc("/acme/new-nonce", s.handleNewNonce)
+	mux.HandleFunc("/acme/new-account", s.post(true, s.newAccount))
+	mux.HandleFunc("/acme/account/", s.post(false, s.updateAccount))
+	mux.HandleFunc("/acme/new-order", s.post(false, s.newOrder))
+	mux.HandleFunc("/acme/order/", s.post(false, s.getOrder))
+	mux.HandleFunc("/acme/authz/", s.post(false, s.getAuthz))
+	mux.HandleFunc("/acme/chall/", s.post(false, s.respondChallenge))
+	mux.HandleFunc("/acme/finalize/", s.post(false, s.finalizeOrder))
+	mux.HandleFunc("/acme/cert/", s.post(false, s.getCertificate))
+	mux.HandleFunc("/acme/revoke-cert", s.post(false, s.revokeCertificate))
+	return mux
+}
+
+func (s *acmeServer) handleDirectory(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"newNonce":   s.baseURL + "/acme/new-nonce",
+		"newAccount": s.baseURL + "/acme/new-account",
+		"newOrder":   s.baseURL + "/acme/new-order",
+		"revokeCert": s.baseURL + "/acme/revoke-cert",
+		"meta":       map[string]any{"externalAccountRequired": false},
+	})
+}
+
+func (s *acmeServer) handleNewNonce(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.addNonce(w)
+	s.mu.Unlock()
+	w.Header().Set("Cache-Control", "no-store")
+	if r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// addNonce sets a fresh Replay-Nonce header. The caller holds s.mu.
+func (s *acmeServer) addNonce(w http.ResponseWriter) {
+	if len(s.nonces) >= maxACMENonces {
+		// Clients retry on badNonce, so dropping the oldest half is safe.
+		cutoff := make([]time.Time, 0, len(s.nonces))
+		for _, issued := range s.nonces {
+			cutoff = append(cutoff, issued)
+		}
+		sort.Slice(cutoff, func(i, j int) bool { return cutoff[i].Before(cutoff[j]) })
+		for nonce, issued := range s.nonces {
+			if !issued.After(cutoff[len(cutoff)/2]) {
+				delete(s.nonces, nonce)
+			}
+		}
+	}
+	nonce := randomID()
+	s.nonces[nonce] = time.Now()
+	w.Header().Set("Replay-Nonce", nonce)
+}
+
+// post wraps a handler for a JWS-signed POST request: it checks the nonce,
+// URL and signature, and for kid-signed requests resolves the account.
+// embeddedKey selects requests signed with a jwk rather than an account.
+func (s *acmeServer) post(embeddedKey bool, handler func(w http.ResponseWriter, r *http.Request, req *acmeRequest) *acmeProblem) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.addNonce(w)
+		w.Header().Add("Link", fmt.Sprintf("<%s/directory>;rel=\"index\"", s.baseURL))
+
+		problem := s.verifyRequest(r, embeddedKey, func(req *acmeRequest) *acmeProblem {
+			return handler(w, r, req)
+		})
+		if problem != nil {
+			writeProblem(w, problem)
+			log.Printf("%s POST %s: %s", r.RemoteAddr, r.URL.Path, problem.Detail)
+		} else {
+			log.Printf("%s POST %s: ok", r.RemoteAddr, r.URL.Path)
+		}
+	}
+}
+
+func (s *acmeServer) verifyRequest(r *http.Request, embeddedKey bool, handle func(req *acmeRequest) *acmeProblem) *acmeProblem {
+	if r.Method != http.MethodPost {
+		return acmeError(http.StatusMethodNotAllowed, "malformed", "ACME requests must use POST")
+	}
+	if contentType := r.Header.Get("Content-Type"); contentType != "application/jose+json" {
+		return acmeError(http.StatusUnsupportedMediaType, "malformed", "Content-Type must be application/jose+json, got %q", contentType)
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxACMERequestSize))
+	if err != nil {
+		return acmeError(http.StatusBadRequest, "malformed", "failed to read request: %v", err)
+	}
+	jws, header, err := parseJWS(body)
+	if err != nil {
+		return acmeError(http.StatusBadRequest, "malformed", "%v", err)
+	}
+	if _, ok := s.nonces[header.Nonce]; !ok {
+		return acmeError(http.StatusBadRequest, "badNonce", "nonce %q is unknown or was already used", header.Nonce)
+	}
+	delete(s.nonces, header.Nonce)
+	if header.URL != s.baseURL+r.URL.Path {
+		return acmeError(http.StatusUnauthorized, "unauthorized", "url %q in the protected header does not match the request URL", header.URL)
+	}
+
+	req := &acmeRequest{}
+	if embeddedKey {
+		if len(header.JWK) == 0 {
+			return acmeError(http.StatusBadRequest, "malformed", "this request must be signed with a jwk, not a kid")
+		}
+		if req.jwk, req.key, err = parseJWK(header.JWK); err != nil {
+			return acmeError(http.StatusBadRequest, "badPublicKey", "%v", err)
+		}
+	} else {
+		if header.KID == "" {
+			return acmeError(http.StatusBadRequest, "malformed", "this request must be signed with an account kid, not a jwk")
+		}
+		account := s.accounts[strings.TrimPrefix(header.KID, s.baseURL+"/acme/account/")]
+		if account == nil {
+			return acmeError(http.StatusBadRequest, "accountDoesNotExist", "no account %q", header.KID)
+		}
+		if account.Status != acmeStatusValid {
+			return acmeError(http.StatusUnauthorized, "unauthorized", "account is %s", account.Status)
+		}
+		req.account, req.key = account, account.key
+	}
+	if err := jws.verify(header.Alg, req.key); err != nil {
+		return acmeError(http.StatusBadRequest, "badSignatureAlgorithm", "%v", err)
+	}
+	if req.payload, err = jws.payload(); err != nil {
+		return acmeError(http.StatusBadRequest, "malformed", "%v", err)
+	}
+	return handle(req)
+}
+
+func (s *acmeServer) newAccount(w http.ResponseWriter, r *http.Request, req *acmeRequest) *acmeProblem {
+	var payload struct {
+		Contact              []string `json:"contact"`
+		TermsOfServiceAgreed bool     `json:"termsOfServiceAgreed"`
+		OnlyReturnExisting   bool     `json:"onlyReturnExisting"`
+	}
+	if err := json.Unmarshal(req.payload, &payload); err != nil {
+		return acmeError(http.StatusBadRequest, "malformed", "account request is not JSON: %v", err)
+	}
+	thumbprint := req.jwk.thumbprint()
+	for _, account := range s.accounts {
+		if account.thumbprint == thumbprint {
+			w.Header().Set("Location", s.accountURL(account))
+			writeJSON(w, http.StatusOK, account)
+			return nil
+		}
+	}
+	if payload.OnlyReturnExisting {
+		return acmeError(http.StatusBadRequest, "accountDoesNotExist", "no account exists for this key")
+	}
+	if problem := checkContacts(payload.Contact); problem != nil {
+		return problem
+	}
+
+	account := &acmeAccount{id: randomID(), key: req.key, thumbprint: thumbprint, Status: acmeStatusValid, Contact: payload.Contact}
+	s.accounts[account.id] = account
+	w.Header().Set("Location", s.accountURL(account))
+	writeJSON(w, http.StatusCreated, account)
+	return nil
+}
+
+// checkContacts accepts mailto: contacts only, as Let's Encrypt does.
+func checkContacts(contacts []string) *acmeProblem {
+	for _, contact := range contacts {
+		if !strings.HasPrefix(contact, "mailto:") || !strings.Contains(contact, "@") {
+			return acmeError(http.StatusBadRequest, "unsupportedContact", "contact %q is not a mailto: URL", contact)
+		}
+	}
+	return nil
+}
+
+func (s *acmeServer) updateAccount(w http.ResponseWriter, r *http.Request, req *acmeRequest) *acmeProblem {
+	if s.accountURL(req.account) != s.baseURL+r.URL.Path {
+		return acmeError(http.StatusUnauthorized, "unauthorized", "request signed by a different account")
+	}
+	if len(req.payload) > 0 {
+		var payload struct {
+			Status  string   `json:"status"`
+			Contact []string `json:"contact"`
+		}
+		if err := json.Unmarshal(req.payload, &payload); err != nil {
+			return acmeError(http.StatusBadRequest, "malformed", "account update is not JSON: %v", err)
+		}
+		if payload.Contact != nil {
+			if problem := checkContacts(payload.Contact); problem != nil {
+				return problem
+			}
+			req.account.Contact = payload.Contact
+		}
+		switch payload.Status {
+		case "":
+		case acmeStatusDeactivated:
+			req.account.Status = acmeStatusDeactivated
+		default:
+			return acmeError(http.StatusBadRequest, "malformed", "account status can only be changed to deactivated")
+		}
+	}
+	writeJSON(w, http.StatusOK, req.account)
+	return nil
+}
+
+func (s *acmeServer) newOrder(w http.ResponseWriter, r *http.Request, req *acmeRequest) *acmeProblem {
+	var payload struct {
+		Identifiers []acmeIdentifier `json:"identifiers"`
+		NotBefore   string           `json:"notBefore"`
+		NotAfter    string           `json:"notAfter"`
+	}
+	if err := json.Unmarshal(req.payload, &payload); err != nil {
+		return acmeError(http.StatusBadRequest, "malformed", "order is not JSON: %v", err)
+	}
+	if payload.NotBefore != "" || payload.NotAfter != "" {
+		return acmeError(http.StatusBadRequest, "malformed", "notBefore and notAfter are not supported; certificates are valid for %d days", s.days)
+	}
+	if len(payload.Identifiers) == 0 {
+		return acmeError(http.StatusBadRequest, "malformed", "order has no identifiers")
+	}
+	opts, problem := identifierOptions(payload.Identifiers)
+	if problem != nil {
+		return problem
+	}
+	if err := s.sanPolicy.Validate(opts); err != nil {
+		return acmeError(http.StatusBadRequest, "rejectedIdentifier", "%v", err)
+	}
+	if err := ca.CertificateNameConstraints(s.issuer.Certificate).Check(opts); err != nil {
+		return acmeError(http.StatusBadRequest, "rejectedIdentifier", "outside the CA's name constraints: %v", err)
+	}
+
+	expires := time.Now().UTC().Add(acmeOrderLifetime).Truncate(time.Second)
+	order := &acmeOrder{id: randomID(), account: req.account.id, Status: acmeStatusPending, Expires: expires, Identifiers: payload.Identifiers}
+	order.Finalize = s.baseURL + "/acme/finalize/" + order.id
+	for _, identifier := range payload.Identifiers {
+		authz := &acmeAuthz{id: randomID(), account: req.account.id, Status: acmeStatusPending, Expires: expires, Identifier: identifier}
+		types := []string{acmeChallengeHTTP01, acmeChallengeDNS01}
+		switch {
+		case identifier.Type == "ip":
+			types = []string{acmeChallengeHTTP01}
+		case strings.HasPrefix(identifier.Value, "*."):
+			// Only a DNS record proves control of a whole domain.
+			authz.Identifier.Value = strings.TrimPrefix(identifier.Value, "*.")
+			authz.Wildcard = true
+			types = []string{acmeChallengeDNS01}
+		}
+		for _, kind := range types {
+			challenge := &acmeChallenge{id: randomID(), authz: authz, Type: kind, Status: acmeStatusPending, Token: randomID()}
+			challenge.URL = s.baseURL + "/acme/chall/" + challenge.id
+			authz.Challenges = append(authz.Challenges, challenge)
+			s.challenges[challenge.id] = challenge
+		}
+		s.authzs[authz.id] = authz
+		order.authzs = append(order.authzs, authz)
+		order.Authorizations = append(order.Authorizations, s.baseURL+"/acme/authz/"+authz.id)
+	}
+	s.orders[order.id] = order
+
+	w.Header().Set("Location", s.baseURL+"/acme/order/"+order.id)
+	writeJSON(w, http.StatusCreated, order)
+	return nil
+}
+
+// identifierOptions converts order identifiers into the SANs they request.
+func identifierOptions(identifiers []acmeIdentifier) (ca.IssueOptions, *acmeProblem) {
+	var opts ca.IssueOptions
+	for _, identifier := range identifiers {
+		switch identifier.Type {
+		case "dns":
+			opts.DNSNames = append(opts.DNSNames, strings.ToLower(identifier.Value))
+		case "ip":
+			ip := net.ParseIP(identifier.Value)
+			if ip == nil {
+				return opts, acmeError(http.StatusBadRequest, "malformed", "%q is not an IP address", identifier.Value)
+			}
+			opts.IPAddresses = append(opts.IPAddresses, ip)
+		default:
+			return opts, acmeError(http.StatusBadRequest, "unsupportedIdentifier", "identifier type %q is not supported (use dns or ip)", identifier.Type)
+		}
+	}
+	return opts, nil
+}
+
+// refreshOrder moves a pending order on once its authorizations are
+// decided. The caller holds s.mu.
+func (s *acmeServer) refreshOrder(order *acmeOrder) {
+	if order.Status != acmeStatusPending {
+		return
+	}
+	if time.Now().After(order.Expires) {
+		order.Status = acmeStatusInvalid
+		order.Error = acmeError(http.StatusForbidden, "unauthorized", "order expired")
+		return
+	}
+	ready := true
+	for _, authz := range order.authzs {
+		switch authz.Status {
+		case acmeStatusValid:
+		case acmeStatusPending:
+			ready = false
+		default:
+			order.Status = acmeStatusInvalid
+			order.Error = acmeError(http.StatusForbidden, "unauthorized", "authorization for %s is %s", authz.Identifier.Value, authz.Status)
+			return
+		}
+	}
+	if ready {
+		order.Status = acmeStatusReady
+	}
+}
+
+// ownedOrder returns the order at the end of r's path if it belongs to req's
+// account.
+func (s *acmeServer) ownedOrder(r *http.Request, prefix string, req *acmeRequest) (*acmeOrder, *acmeProblem) {
+	order := s.orders[strings.TrimPrefix(r.URL.Path, prefix)]
+	if order == nil || order.account != req.account.id {
+		return nil, acmeError(http.StatusNotFound, "malformed", "no such order")
+	}
+	s.refreshOrder(order)
+	return order, nil
+}
+
+func (s *acmeServer) getOrder(w http.ResponseWriter, r *http.Request, req *acmeRequest) *acmeProblem {
+	order, problem := s.ownedOrder(r, "/acme/order/", req)
+	if problem != nil {
+		return problem
+	}
+	writeJSON(w, http.StatusOK, order)
+	return nil
+}
+
+func (s *acmeServer) getAuthz(w http.ResponseWriter, r *http.Request, req *acmeRequest) *acmeProblem {
+	authz := s.authzs[strings.TrimPrefix(r.URL.Path, "/acme/authz/")]
+	if authz == nil || authz.account != req.account.id {
+		return acmeError(http.StatusNotFound, "malformed", "no such authorization")
+	}
+	if len(req.payload) > 0 {
+		var payload struct {
+			Status string `json:"status"`
+		}
+		if err := json.Unmarshal(req.payload, &payload); err != nil || payload.Status != acmeStatusDeactivated {
+			return acmeError(http.StatusBadRequest, "malformed", "authorization status can only be changed to deactivated")
+		}
+		authz.Status = acmeStatusDeactivated
+	}
+	if authz.Status == acmeStatusPending && time.Now().After(authz.Expires) {
+		authz.Status = acmeStatusExpired
+	}
+	writeJSON(w, http.StatusOK, authz)
+	return nil
+}
+
+func (s *acmeServer) respondChallenge(w http.ResponseWriter, r *http.Request, req *acmeRequest) *acmeProblem {
+	challenge := s.challenges[strings.TrimPrefix(r.URL.Path, "/acme/chall/")]
+	if challenge == nil || challenge.authz.account != req.account.id {
+		return acmeError(http.StatusNotFound, "malformed", "no such challenge")
+	}
+	// An empty payload only fetches the challenge; "{}" asks for validation.
+	if len(req.payload) > 0 && challenge.Status == acmeStatusPending && challenge.authz.Status == acmeStatusPending {
+		challenge.Status = acmeStatusProcessing
+		keyAuthorization := challenge.Token + "." + req.account.thumbprint
+		go s.validate(challenge, keyAuthorization)
+	}
+	w.Header().Add("Link", fmt.Sprintf("<%s/acme/authz/%s>;rel=\"up\"", s.baseURL, challenge.authz.id))
+	writeJSON(w, http.StatusOK, challenge)
+	return nil
+}
+
+// validate checks a challenge and records the outcome on it and its
+// authorization.
+func (s *acmeServer) validate(challenge *acmeChallenge, keyAuthorization string) {
+	var problem *acmeProblem
+	if !s.dev {
+		ctx, cancel := context.WithTimeout(context.Background(), acmeValidationTimeout)
+		defer cancel()
+		if challenge.Type == acmeChallengeHTTP01 {
+			problem = s.validateHTTP01(ctx, challenge.authz.Identifier.Value, challenge.Token, keyAuthorization)
+		} else {
+			problem = s.validateDNS01(ctx, challenge.authz.Identifier.Value, keyAuthorization)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC().Truncate(time.Second)
+	challenge.Validated = &now
+	if problem != nil {
+		challenge.Status, challenge.Error = acmeStatusInvalid, problem
+		challenge.authz.Status = acmeStatusInvalid
+		log.Printf("%s challenge for %s failed: %s", challenge.Type, challenge.authz.Identifier.Value, problem.Detail)
+		return
+	}
+	challenge.Status = acmeStatusValid
+	challenge.authz.Status = acmeStatusValid
+	log.Printf("%s challenge for %s succeeded", challenge.Type, challenge.authz.Identifier.Value)
+}
+
+// validateHTTP01 fetches the key authorization from the identifier's web
+// server (RFC 8555, section 8.3).
+func (s *acmeServer) validateHTTP01(ctx context.Context, host, token, keyAuthorization string) *acmeProblem {
+	url := fmt.Sprintf("http://%s/.well-known/acme-challenge/%s", net.JoinHostPort(host, strconv.Itoa(s.httpPort)), token)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return acmeError(http.StatusBadRequest, "malformed", "%v", err)
+	}
+	response, err := s.client.Do(request)
+	if err != nil {
+		return acmeError(http.StatusBadRequest, "connection", "fetching %s: %v", url, err)
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxKeyAuthorizationSize))
+	if err != nil {
+		return acmeError(http.StatusBadRequest, "connection", "reading %s: %v", url, err)
+	}
+	if response.StatusCode != http.StatusOK {
+		return acmeError(http.StatusForbidden, "unauthorized", "%s returned %s", url, response.Status)
+	}
+	// The body is never echoed: a redirect may have led anywhere the
+	// identifier's owner chose.
+	if got := strings.TrimSpace(string(body)); got != keyAuthorization {
+		return acmeError(http.StatusForbidden, "incorrectResponse", "%s returned %d bytes that do not match the key authorization", url, len(body))
+	}
+	return nil
+}
+
+// newHTTP01Client returns the client http-01 challenges are fetched with.
+// Whoever creates an order chooses the host, so unless dev is set it never
+// connects to loopback, link-local or private addresses, and it follows only
+// a few redirects, to http or https on ports 80 and 443 (RFC 8555, section
+// 8.3).
+func newHTTP01Client(dev bool) *http.Client {
+	dialer := &net.Dialer{Timeout: acmeValidationTimeout}
+	if !dev {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return fmt.Errorf("refusing to connect to non-public address %s", host)
+			}
+			return nil
+		}
+	}
+	return &http.Client{
+		Timeout: acmeValidationTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: acmeValidationTimeout,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxACMERedirects {
+				return fmt.Errorf("stopped after %d redirects", maxACMERedirects)
+			}
+			port := req.URL.Port()
+			switch req.URL.Scheme {
+			case "http":
+				if port == "" {
+					port = "80"
+				}
+			case "https":
+				if port == "" {
+					port = "443"
+				}
+			default:
+				return fmt.Errorf("refusing to follow a redirect to scheme %q", req.URL.Scheme)
+			}
+			if port != "80" && port != "443" {
+				return fmt.Errorf("refusing to follow a redirect to port %s", port)
+			}
+			return nil
+		},
+	}
+}
+
+// isPublicIP reports whether ip is a globally routable unicast address.
+func isPublicIP(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate()
+}
+
+// validateDNS01 looks for the key authorization digest in a TXT record
+// (RFC 8555, section 8.4).
+func (s *acmeServer) validateDNS01(ctx context.Context, domain, keyAuthorization string) *acmeProblem {
+	sum := sha256.Sum256([]byte(keyAuthorization))
+	expected := base64URL.EncodeToString(sum[:])
+	name := "_acme-challenge." + domain
+	records, err := s.resolver.LookupTXT(ctx, name)
+	if err != nil {
+		return acmeError(http.StatusBadRequest, "dns", "looking up TXT %s: %v", name, err)
+	}
+	for _, record := range records {
+		if record == expected {
+			return nil
+		}
+	}
+	return acmeError(http.StatusForbidden, "incorrectResponse", "no TXT record %s with value %q (found %d)", name, expected, len(records))
+}
+
+func (s *acmeServer) finalizeOrder(w http.ResponseWriter, r *http.Request, req *acmeRequest) *acmeProblem {
+	order, problem := s.ownedOrder(r, "/acme/finalize/", req)
+	if problem != nil {
+		return problem
+	}
+	if order.Status != acmeStatusReady {
+		return acmeError(http.StatusForbidden, "orderNotReady", "order is %s, not ready", order.Status)
+	}
+	var payload struct {
+		CSR string `json:"csr"`
+	}
+	if err := json.Unmarshal(req.payload, &payload); err != nil {
+		return acmeError(http.StatusBadRequest, "malformed", "finalize request is not JSON: %v", err)
+	}
+	der, err := base64URL.DecodeString(payload.CSR)
+	if err != nil {
+		return acmeError(http.StatusBadRequest, "badCSR", "csr is not base64url: %v", err)
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return acmeError(http.StatusBadRequest, "badCSR", "%v", err)
+	}
+	if problem := checkCSRIdentifiers(csr, order.Identifiers); problem != nil {
+		return problem
+	}
+	if accountKey, err := x509.MarshalPKIXPublicKey(req.account.key); err == nil && bytes.Equal(accountKey, csr.RawSubjectPublicKeyInfo) {
+		return acmeError(http.StatusBadRequest, "badCSR", "the certificate key must differ from the account key")
+	}
+
+	opts, _ := identifierOptions(order.Identifiers)
+	opts.ValidityDays = s.days
+	opts.SerialBits = s.serials.bits
+	opts.ExtKeyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	opts.Denylist = s.keys
+	var seq uint64
+	if opts.SerialNumber, seq, err = s.serials.allocate(s.dbPath, nil); err != nil {
+		return acmeError(http.StatusInternalServerError, "serverInternal", "%v", err)
+	}
+	cert, err := s.issuer.SignCSR(csr, opts)
+	if err != nil {
+		return acmeError(http.StatusBadRequest, "badCSR", "%v", err)
+	}
+	if err := recordIssued(s.dbPath, cert, "", seq); err != nil {
+		return acmeError(http.StatusInternalServerError, "serverInternal", "%v", err)
+	}
+
+	id := randomID()
+	s.certs[id] = &acmeCert{account: req.account.id, der: cert.Raw}
+	order.Status = acmeStatusValid
+	order.Certificate = s.baseURL + "/acme/cert/" + id
+	log.Printf("Issued serial %X to %s for %s", cert.SerialNumber, s.accountURL(req.account), strings.Join(identifierValues(order.Identifiers), ", "))
+
+	w.Header().Set("Location", s.baseURL+"/acme/order/"+order.id)
+	writeJSON(w, http.StatusOK, order)
+	return nil
+}
+
+// checkCSRIdentifiers checks that a CSR asks for exactly the order's
+// identifiers (RFC 8555, section 7.4); its Common Name, if any, must be one
+// of them.
+func checkCSRIdentifiers(csr *x509.CertificateRequest, identifiers []acmeIdentifier) *acmeProblem {
+	requested := map[string]bool{}
+	for _, name := range csr.DNSNames {
+		requested[strings.ToLower(name)] = true
+	}
+	for _, ip := range csr.IPAddresses {
+		requested[ip.String()] = true
+	}
+	ordered := map[string]bool{}
+	for _, value := range identifierValues(identifiers) {
+		ordered[value] = true
+	}
+	if cn := strings.ToLower(csr.Subject.CommonName); cn != "" && !ordered[cn] {
+		return acmeError(http.StatusBadRequest, "badCSR", "CSR Common Name %q is not an identifier of the order", csr.Subject.CommonName)
+	}
+	for name := range requested {
+		if !ordered[name] {
+			return acmeError(http.StatusBadRequest, "badCSR", "CSR names %q, which is not an identifier of the order", name)
+		}
+	}
+	for name := range ordered {
+		if !requested[name] {
+			return acmeError(http.StatusBadRequest, "badCSR", "CSR is missing the order's identifier %q", name)
+		}
+	}
+	return nil
+}
+
+// identifierValues returns the identifiers in the form SANs are compared in.
+func identifierValues(identifiers []acmeIdentifier) []string {
+	values := make([]string, len(identifiers))
+	for i, identifier := range identifiers {
+		values[i] = strings.ToLower(identifier.Value)
+		if ip := net.ParseIP(identifier.Value); identifier.Type == "ip" && ip != nil {
+			values[i] = ip.String()
+		}
+	}
+	return values
+}
+
+func (s *acmeServer) getCertificate(w http.ResponseWriter, r *http.Request, req *acmeRequest) *acmeProblem {
+	cert := s.certs[strings.TrimPrefix(r.URL.Path, "/acme/cert/")]
+	if cert == nil || cert.account != req.account.id {
+		return acmeError(http.StatusNotFound, "malformed", "no such certificate")
+	}
+	w.Header().Set("Content-Type", "application/pem-certificate-chain")
+	w.Write(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.der}))
+	w.Write(s.chainPEM)
+	return nil
+}
+
+// revokeCertificate revokes a certificate issued to the requesting account
+// in the certificate database; gen-crl and serve-ocsp publish it.
+func (s *acmeServer) revokeCertificate(w http.ResponseWriter, r *http.Request, req *acmeRequest) *acmeProblem {
+	var payload struct {
+		Certificate string `json:"certificate"`
+		Reason      *int   `json:"reason"`
+	}
+	if err := json.Unmarshal(req.payload, &payload); err != nil {
+		return acmeError(http.StatusBadRequest, "malformed", "revocation request is not JSON: %v", err)
+	}
+	der, err := base64URL.DecodeString(payload.Certificate)
+	if err != nil {
+		return acmeError(http.StatusBadRequest, "malformed", "certificate is not base64url: %v", err)
+	}
+	owned := false
+	for _, cert := range s.certs {
+		if cert.account == req.account.id && bytes.Equal(cert.der, der) {
+			owned = true
+		}
+	}
+	if !owned {
+		return acmeError(http.StatusForbidden, "unauthorized", "the certificate was not issued to this account")
+	}
+	reason := ca.ReasonUnspecified
+	if payload.Reason != nil {
+		reason = *payload.Reason
+	}
+	name := ca.RevocationReasonName(reason)
+	if _, ok := ca.RevocationReasonNames[name]; !ok {
+		return acmeError(http.StatusBadRequest, "badRevocationReason", "unknown revocation reason %d", reason)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return acmeError(http.StatusBadRequest, "malformed", "%v", err)
+	}
+	unlock, err := lockCertDB(s.dbPath)
+	if err != nil {
+		return acmeError(http.StatusInternalServerError, "serverInternal", "%v", err)
+	}
+	defer unlock()
+	db, err := loadCertDB(s.dbPath)
+	if err != nil {
+		return acmeError(http.StatusInternalServerError, "serverInternal", "%v", err)
+	}
+	record := db.find(fmt.Sprintf("%X", cert.SerialNumber))
+	if record == nil {
+		return acmeError(http.StatusInternalServerError, "serverInternal", "serial %X is not in the certificate database", cert.SerialNumber)
+	}
+	if record.Status == statusRevoked {
+		return acmeError(http.StatusBadRequest, "alreadyRevoked", "serial %s was revoked at %s", record.Serial, record.RevokedAt.Format(time.RFC3339))
+	}
+	revokedAt := time.Now().UTC()
+	record.Status = statusRevoked
+	record.Reason = name
+	record.RevokedAt = &revokedAt
+	if err := db.save(s.dbPath); err != nil {
+		return acmeError(http.StatusInternalServerError, "serverInternal", "%v", err)
+	}
+	log.Printf("Revoked serial %s (%s)", record.Serial, name)
+
+	if reason == ca.ReasonKeyCompromise {
+		// A compromised key must not be certified again under a new serial.
+		if _, _, err := s.denylist.denyCompromised(s.caCertFile, cert.PublicKey, record.Serial); err != nil {
+			log.Printf("Error: %v", err)
+		} else if keys, err := s.denylist.load(s.caCertFile); err == nil {
+			s.keys = keys
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (s *acmeServer) accountURL(account *acmeAccount) string {
+	return s.baseURL + "/acme/account/" + account.id
+}
+
+// randomID returns an unguessable identifier for URLs, tokens and nonces.
+func randomID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64URL.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeProblem(w http.ResponseWriter, problem *acmeProblem) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
@@ -0,0 +1,139 @@
+// serve_acme_test.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/prtk1729/certA/pkg/ca"
+)
+
+func TestHTTP01ClientRefusesInternalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "secret")
+	}))
+	defer server.Close()
+
+	if _, err := newHTTP01Client(false).Get(server.URL); err == nil || !strings.Contains(err.Error(), "non-public address") {
+		t.Errorf("fetching %s without -dev: error = %v, want a refusal", server.URL, err)
+	}
+	response, err := newHTTP01Client(true).Get(server.URL)
+	if err != nil {
+		t.Fatalf("fetching %s with -dev: %v", server.URL, err)
+	}
+	response.Body.Close()
+}
+
+func TestHTTP01ClientRedirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/port":
+			http.Redirect(w, r, "http://127.0.0.1:8080/", http.StatusFound)
+		case "/scheme":
+			http.Redirect(w, r, "ftp://127.0.0.1/", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/port", "port 8080"},
+		{"/scheme", `scheme "ftp"`},
+	}
+	for _, tt := range tests {
+		// The test server listens on loopback, so only -dev reaches it.
+		_, err := newHTTP01Client(true).Get(server.URL + tt.path)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("following %s: error = %v, want one mentioning %q", tt.path, err, tt.want)
+		}
+	}
+
+	check := newHTTP01Client(false).CheckRedirect
+	next, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	if err := check(next, make([]*http.Request, maxACMERedirects)); err != nil {
+		t.Errorf("redirect %d: %v", maxACMERedirects, err)
+	}
+	if err := check(next, make([]*http.Request, maxACMERedirects+1)); err == nil {
+		t.Errorf("redirect %d was followed", maxACMERedirects+1)
+	}
+}
+
+func TestValidateHTTP01(t *testing.T) {
+	const token, keyAuthorization = "token", "token.thumbprint"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/.well-known/acme-challenge/"+token {
+			fmt.Fprintln(w, keyAuthorization)
+			return
+		}
+		fmt.Fprint(w, "internal metadata")
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	s := &acmeServer{client: newHTTP01Client(true)}
+	s.httpPort, _ = strconv.Atoi(port)
+
+	if problem := s.validateHTTP01(context.Background(), "127.0.0.1", token, keyAuthorization); problem != nil {
+		t.Errorf("validateHTTP01 with the right key authorization: %s", problem.Detail)
+	}
+	problem := s.validateHTTP01(context.Background(), "127.0.0.1", "other", keyAuthorization)
+	if problem == nil {
+		t.Fatal("validateHTTP01 with a wrong response succeeded")
+	}
+	if strings.Contains(problem.Detail, "metadata") {
+		t.Errorf("problem detail %q echoes the fetched body", problem.Detail)
+	}
+}
+
+func TestACMERevokeKeyCompromise(t *testing.T) {
+	root, err := ca.NewRootCA(ca.Config{CommonName: "Test Root", ValidityDays: 365, KeyType: ca.KeyTypeEd25519})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		reason int
+		denied bool
+	}{
+		{ca.ReasonUnspecified, false},
+		{ca.ReasonKeyCompromise, true},
+	} {
+		dir := t.TempDir()
+		s := &acmeServer{
+			caCertFile: filepath.Join(dir, defaultCertFileName),
+			dbPath:     filepath.Join(dir, certDBFileName),
+			certs:      map[string]*acmeCert{},
+		}
+		cert, _, err := root.Issue(ca.IssueOptions{CommonName: "app.example.com", DNSNames: []string{"app.example.com"}, ValidityDays: 30, KeyType: ca.KeyTypeEd25519})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := recordIssued(s.dbPath, cert, "", 0); err != nil {
+			t.Fatal(err)
+		}
+		s.certs["cert"] = &acmeCert{account: "account", der: cert.Raw}
+
+		payload := fmt.Sprintf(`{"certificate": %q, "reason": %d}`, base64URL.EncodeToString(cert.Raw), tt.reason)
+		req := &acmeRequest{payload: []byte(payload), account: &acmeAccount{id: "account"}}
+		if problem := s.revokeCertificate(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/acme/revoke-cert", nil), req); problem != nil {
+			t.Fatalf("revokeCertificate with reason %d: %s", tt.reason, problem.Detail)
+		}
+		db, err := loadCertDB(s.dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if record := db.find(fmt.Sprintf("%X", cert.SerialNumber)); record == nil || record.Status != statusRevoked {
+			t.Errorf("reason %d: certificate is not revoked in the database", tt.reason)
+		}
+		if denied := s.keys.Check(cert.PublicKey) != nil; denied != tt.denied {
+			t.Errorf("reason %d: key denied = %v, want %v", tt.reason, denied, tt.denied)
+		}
+	}
+}
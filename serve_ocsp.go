@@ -0,0 +1,262 @@
+// serve_ocsp.go
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prtk1729/certA/pkg/ca"
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	defaultOCSPPort     = 8080
+	defaultOCSPValidity = time.Hour
+	maxOCSPRequestSize  = 10 << 10 // Requests are a few hundred bytes
+)
+
+// ocspResponder answers OCSP requests for certificates issued by one CA.
+type ocspResponder struct {
+	issuer    *x509.Certificate
+	signer    crypto.Signer
+	delegated *x509.Certificate // Responder certificate, nil when the CA key signs
+	issuerKey []byte            // The issuer's subjectPublicKey bits, for key hash matching
+	dbPath    string
+	validity  time.Duration
+}
+
+// runServeOCSP implements the serve-ocsp command: an HTTP OCSP responder
+// (RFC 6960) backed by the CA's certificate database.
+func runServeOCSP(args []string) {
+	fs := flag.NewFlagSet("serve-ocsp", flag.ExitOnError)
+	caCertFile := fs.String("ca", defaultCertFileName, "Issuing CA certificate PEM file")
+	caKeyFile := fs.String("ca-key", "", "CA private key PEM file; signs responses directly (or use -responder-cert)")
+	responderCertFile := fs.String("responder-cert", "", "Delegated responder certificate PEM file, issued by the CA with -eku ocsp")
+	responderKeyFile := fs.String("responder-key", "", "Delegated responder private key PEM file")
+	var db certDBFlags
+	db.register(fs)
+	host := fs.String("host", "", "Interface to listen on (default: all interfaces)")
+	port := fs.Int("port", defaultOCSPPort, "TCP port to listen on")
+	validity := fs.Duration("validity", defaultOCSPValidity, "How long each response stays current (its nextUpdate)")
+	var caPassphrase, responderPassphrase passphraseFlags
+	caPassphrase.register(fs, "ca-", "decrypt the CA private key with")
+	responderPassphrase.register(fs, "responder-", "decrypt the responder private key with")
+
+	var cfg configFlags
+	cfg.register(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s serve-ocsp -ca ca.crt (-ca-key ca.key | -responder-cert ocsp.crt -responder-key ocsp.key) [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Runs an HTTP OCSP responder backed by the CA's certificate database. Revocations\n")
+		fmt.Fprintf(os.Stderr, "recorded with the revoke command are served immediately, without a restart.\n")
+		fmt.Fprintf(os.Stderr, "Serials the database has no record of are reported as unknown.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	cfg.apply(fs)
+	if err := checkFlags(fs); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	delegated := *responderCertFile != "" || *responderKeyFile != ""
+	if delegated == (*caKeyFile != "") {
+		fs.Usage()
+		log.Fatal("Error: exactly one of -ca-key and -responder-cert/-responder-key is required.")
+	}
+	if delegated && (*responderCertFile == "" || *responderKeyFile == "") {
+		log.Fatal("Error: -responder-cert and -responder-key must be given together.")
+	}
+	dbFile := db.resolve(*caCertFile)
+
+	responder := &ocspResponder{dbPath: dbFile, validity: *validity}
+	if delegated {
+		caCerts, err := ca.LoadCertificates(*caCertFile)
+		if err != nil {
+			log.Fatalf("Error loading CA certificate: %v", err)
+		}
+		responder.issuer = caCerts[0]
+		pair, err := ca.LoadKeyPair(*responderCertFile, *responderKeyFile, responderPassphrase.source())
+		if err != nil {
+			log.Fatalf("Error loading responder certificate and key: %v", err)
+		}
+		if err := checkOCSPResponderCert(responder.issuer, pair.Leaf); err != nil {
+			log.Fatalf("Error: %v.", err)
+		}
+		responder.delegated = pair.Leaf
+		responder.signer = pair.PrivateKey.(crypto.Signer)
+	} else {
+		issuer, err := ca.LoadCA(*caCertFile, *caKeyFile, caPassphrase.source())
+		if err != nil {
+			log.Fatalf("Error loading issuing CA: %v", err)
+		}
+		responder.issuer = issuer.Certificate
+		responder.signer = issuer.Key
+	}
+	var err error
+	if responder.issuerKey, err = subjectPublicKeyBits(responder.issuer); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	// Fail at startup rather than on the first request.
+	if _, err := loadCertDB(dbFile); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	addr := net.JoinHostPort(*host, strconv.Itoa(*port))
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           responder,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	fmt.Printf("Serving OCSP on %s for %s\n", addr, responder.issuer.Subject)
+	if delegated {
+		fmt.Printf("  Signing with delegated responder %s (expires %s)\n", responder.delegated.Subject, responder.delegated.NotAfter.Format(time.RFC3339))
+	} else {
+		fmt.Println("  Signing with the CA key")
+	}
+	fmt.Printf("  Certificate database: %s\n", dbFile)
+	fmt.Printf("  Response validity: %s (press Ctrl+C to stop)\n", *validity)
+	if err := serveUntilInterrupted(server, "", ""); err != nil {
+		log.Fatalf("Error running OCSP responder: %v", err)
+	}
+	fmt.Println("OCSP responder stopped.")
+}
+
+// checkOCSPResponderCert checks that a delegated responder certificate may
+// sign responses on the CA's behalf (RFC 6960, section 4.2.2.2).
+func checkOCSPResponderCert(issuer, responder *x509.Certificate) error {
+	if !(&ca.CA{Certificate: issuer}).Issued(responder) {
+		return fmt.Errorf("responder certificate %s was not issued by %s", responder.Subject, issuer.Subject)
+	}
+	for _, usage := range responder.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageOCSPSigning {
+			if time.Now().After(responder.NotAfter) {
+				return fmt.Errorf("responder certificate %s expired at %s", responder.Subject, responder.NotAfter.Format(time.RFC3339))
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("responder certificate %s lacks the OCSP signing extended key usage (issue it with sign-csr -eku ocsp)", responder.Subject)
+}
+
+// subjectPublicKeyBits returns the subjectPublicKey BIT STRING contents of
+// cert, which OCSP requests identify the issuer's key by.
+func subjectPublicKeyBits(cert *x509.Certificate) ([]byte, error) {
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(cert.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return nil, fmt.Errorf("failed to parse CA public key: %w", err)
+	}
+	return spki.PublicKey.RightAlign(), nil
+}
+
+// ServeHTTP handles OCSP requests sent by POST or, base64 encoded in the
+// path, by GET (RFC 6960, appendix A.1).
+func (o *ocspResponder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var der []byte
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		der, err = base64.StdEncoding.DecodeString(strings.TrimPrefix(r.URL.Path, "/"))
+	case http.MethodPost:
+		der, err = io.ReadAll(io.LimitReader(r.Body, maxOCSPRequestSize))
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "OCSP requests must use GET or POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	response, summary, signed := ocsp.MalformedRequestErrorResponse, "malformed request", false
+	if err == nil {
+		response, summary, signed = o.respond(der)
+	}
+
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	if r.Method == http.MethodGet && signed {
+		// GET responses may be cached by HTTP proxies (RFC 5019, section 6).
+		w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d, public, no-transform, must-revalidate", int(o.validity.Seconds())))
+	}
+	w.Write(response)
+	log.Printf("%s %s: %s", r.RemoteAddr, r.Method, summary)
+}
+
+// respond builds the response to a DER-encoded request and a short
+// description of it for the log. signed is false for error responses.
+func (o *ocspResponder) respond(der []byte) (response []byte, summary string, signed bool) {
+	req, err := ocsp.ParseRequest(der)
+	if err != nil || !req.HashAlgorithm.Available() {
+		return ocsp.MalformedRequestErrorResponse, "malformed request", false
+	}
+	serial := fmt.Sprintf("%X", req.SerialNumber)
+	if !o.isIssuer(req) {
+		return ocsp.UnauthorizedErrorResponse, "serial " + serial + ": not issued by this CA", false
+	}
+
+	// Reading the database on every request keeps revocations current;
+	// OCSP traffic for a private CA is modest.
+	db, err := loadCertDB(o.dbPath)
+	if err != nil {
+		log.Printf("Error: %v", err)
+		return ocsp.InternalErrorErrorResponse, "serial " + serial + ": internal error", false
+	}
+
+	now := time.Now().UTC().Truncate(time.Minute)
+	template := ocsp.Response{
+		Status:       ocsp.Unknown,
+		SerialNumber: req.SerialNumber,
+		ThisUpdate:   now,
+		NextUpdate:   now.Add(o.validity),
+		Certificate:  o.delegated,
+	}
+	switch record := db.find(serial); {
+	case record == nil:
+		// Not issued by this CA as far as the database knows.
+	case record.Status == statusRevoked:
+		reason, ok := ca.RevocationReasonNames[record.Reason]
+		if !ok {
+			reason = ca.ReasonUnspecified
+		}
+		template.Status = ocsp.Revoked
+		template.RevokedAt = record.RevokedAt.UTC()
+		template.RevocationReason = reason
+	default:
+		template.Status = ocsp.Good
+	}
+
+	responderCert := o.issuer
+	if o.delegated != nil {
+		responderCert = o.delegated
+	}
+	response, err = ocsp.CreateResponse(o.issuer, responderCert, template, o.signer)
+	if err != nil {
+		log.Printf("Error signing OCSP response: %v", err)
+		return ocsp.InternalErrorErrorResponse, "serial " + serial + ": internal error", false
+	}
+	return response, "serial " + serial + ": " + ocspStatusName(template.Status), true
+}
+
+// isIssuer reports whether req asks about a certificate issued by o's CA.
+func (o *ocspResponder) isIssuer(req *ocsp.Request) bool {
+	nameHash := req.HashAlgorithm.New()
+	nameHash.Write(o.issuer.RawSubject)
+	keyHash := req.HashAlgorithm.New()
+	keyHash.Write(o.issuerKey)
+	return bytes.Equal(req.IssuerNameHash, nameHash.Sum(nil)) && bytes.Equal(req.IssuerKeyHash, keyHash.Sum(nil))
+}
@@ -0,0 +1,191 @@
+// serve_test.go
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prtk1729/certA/pkg/ca"
+)
+
+const testAPIToken = "deploy-bot-token-0123456789abcdef"
+
+// newTestAPIServer returns an API server for a new root CA accepting
+// testAPIToken for the client "deploy-bot", and an HTTP server for it.
+func newTestAPIServer(t *testing.T, policy *apiPolicyFile) (*apiServer, *httptest.Server) {
+	t.Helper()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	root, err := ca.NewRootCA(ca.Config{CommonName: "Test Root", ValidityDays: 365, KeyType: ca.KeyTypeEd25519})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	s := &apiServer{
+		issuer:        root,
+		caCertFile:    filepath.Join(dir, defaultCertFileName),
+		chain:         []*x509.Certificate{root.Certificate},
+		dbPath:        filepath.Join(dir, certDBFileName),
+		tokens:        []apiToken{{name: "deploy-bot", hash: sha256.Sum256([]byte(testAPIToken))}},
+		policy:        policy,
+		days:          30,
+		serials:       serialFlags{bits: ca.DefaultSerialBits, mode: serialModeRandom},
+		crlNextUpdate: defaultCRLNextUpdate,
+	}
+	server := httptest.NewServer(s.routes())
+	t.Cleanup(server.Close)
+	return s, server
+}
+
+// apiCall sends body to path with token, if any, and decodes the response.
+func apiCall(t *testing.T, server *httptest.Server, path, token string, body any) (int, map[string]any) {
+	t.Helper()
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request, err := http.NewRequest(http.MethodPost, server.URL+path, strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	response, err := server.Client().Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	var decoded map[string]any
+	if err := json.NewDecoder(response.Body).Decode(&decoded); err != nil {
+		t.Fatalf("POST %s: response is not JSON: %v", path, err)
+	}
+	return response.StatusCode, decoded
+}
+
+// testCSR returns a PEM CSR for dnsName and the key it was signed with.
+func testCSR(t *testing.T, dnsName string, key ed25519.PrivateKey) string {
+	t.Helper()
+	if key == nil {
+		var err error
+		if _, key, err = ed25519.GenerateKey(rand.Reader); err != nil {
+			t.Fatal(err)
+		}
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: dnsName},
+		DNSNames: []string{dnsName},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}))
+}
+
+func TestAPITokenRejection(t *testing.T) {
+	s, server := newTestAPIServer(t, nil)
+	sign := map[string]any{"csr": testCSR(t, "app.example.com", nil)}
+	for _, path := range []string{"/sign", "/revoke"} {
+		for _, token := range []string{"", "wrong-token-0123456789abcdefghij", testAPIToken[:len(testAPIToken)-1]} {
+			if status, body := apiCall(t, server, path, token, sign); status != http.StatusUnauthorized {
+				t.Errorf("POST %s with token %q: status %d (%v), want 401", path, token, status, body)
+			}
+		}
+	}
+	db, err := loadCertDB(s.dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(db.Certificates) != 0 {
+		t.Errorf("rejected requests recorded %d certificates", len(db.Certificates))
+	}
+}
+
+func TestAPISignAndRevoke(t *testing.T) {
+	s, server := newTestAPIServer(t, nil)
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, body := apiCall(t, server, "/sign", testAPIToken, map[string]any{"csr": testCSR(t, "app.example.com", key), "days": 7})
+	if status != http.StatusCreated {
+		t.Fatalf("POST /sign: status %d (%v), want 201", status, body)
+	}
+	block, _ := pem.Decode([]byte(body["certificate"].(string)))
+	if block == nil {
+		t.Fatalf("POST /sign returned no certificate: %v", body)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cert.CheckSignatureFrom(s.issuer.Certificate); err != nil {
+		t.Errorf("issued certificate: %v", err)
+	}
+	if body["serial"] != fmt.Sprintf("%X", cert.SerialNumber) {
+		t.Errorf("serial %v does not match the certificate's %X", body["serial"], cert.SerialNumber)
+	}
+	if status, _ := apiCall(t, server, "/sign", testAPIToken, map[string]any{"csr": testCSR(t, "app.example.com", nil), "days": 31}); status != http.StatusBadRequest {
+		t.Errorf("POST /sign beyond -days: status %d, want 400", status)
+	}
+
+	revoke := map[string]any{"certificate": body["certificate"], "reason": "key-compromise"}
+	if status, body := apiCall(t, server, "/revoke", testAPIToken, revoke); status != http.StatusOK {
+		t.Fatalf("POST /revoke: status %d (%v), want 200", status, body)
+	}
+	db, err := loadCertDB(s.dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record := db.find(fmt.Sprintf("%X", cert.SerialNumber)); record == nil || record.Status != statusRevoked || record.Reason != "key-compromise" {
+		t.Errorf("database record after /revoke = %+v", record)
+	}
+	if status, _ := apiCall(t, server, "/revoke", testAPIToken, map[string]any{"serial": body["serial"]}); status != http.StatusConflict {
+		t.Errorf("POST /revoke again: status %d, want 409", status)
+	}
+	// The compromised key is denied from now on.
+	if status, body := apiCall(t, server, "/sign", testAPIToken, map[string]any{"csr": testCSR(t, "app.example.com", key)}); status != http.StatusUnprocessableEntity {
+		t.Errorf("POST /sign with a compromised key: status %d (%v), want 422", status, body)
+	}
+	if status, _ := apiCall(t, server, "/revoke", testAPIToken, map[string]any{"serial": "ABCDEF"}); status != http.StatusNotFound {
+		t.Errorf("POST /revoke of an unknown serial: status %d, want 404", status)
+	}
+}
+
+func TestAPIPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(path, []byte("clients:\n  deploy-bot:\n    dns: [example.com]\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := loadAPIPolicy(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, server := newTestAPIServer(t, policy)
+
+	if status, body := apiCall(t, server, "/sign", testAPIToken, map[string]any{"csr": testCSR(t, "app.example.com", nil)}); status != http.StatusCreated {
+		t.Errorf("POST /sign inside the policy: status %d (%v), want 201", status, body)
+	}
+	if status, _ := apiCall(t, server, "/sign", testAPIToken, map[string]any{"csr": testCSR(t, "app.example.org", nil)}); status != http.StatusForbidden {
+		t.Errorf("POST /sign outside the policy: status %d, want 403", status)
+	}
+	if status, _ := apiCall(t, server, "/sign", testAPIToken, map[string]any{"csr": testCSR(t, "app.example.com", nil), "profile": "server"}); status != http.StatusForbidden {
+		t.Errorf("POST /sign with an unlisted profile: status %d, want 403", status)
+	}
+}
@@ -0,0 +1,217 @@
+// sign_csr.go
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/prtk1729/certA/pkg/ca"
+)
+
+// extKeyUsageNames maps -eku values to extended key usages.
+var extKeyUsageNames = map[string]x509.ExtKeyUsage{
+	"server": x509.ExtKeyUsageServerAuth,
+	"client": x509.ExtKeyUsageClientAuth,
+	"ocsp":   x509.ExtKeyUsageOCSPSigning,
+}
+
+// runSignCSR implements the sign-csr command: it issues a certificate for an
+// externally generated PKCS#10 request, so the requester's private key never
+// has to touch the CA host.
+func runSignCSR(args []string) {
+	fs := flag.NewFlagSet("sign-csr", flag.ExitOnError)
+	csrFile := fs.String("csr", "", "Required: PKCS#10 certificate request PEM file")
+	caCertFile := fs.String("ca", defaultCertFileName, "Issuing CA certificate PEM file")
+	caKeyFile := fs.String("ca-key", defaultKeyFileName, "Issuing CA private key PEM file")
+	outFile := fs.String("out", "", "Path to write the certificate (default: the CSR path with a .crt extension)")
+	p7bFile := fs.String("p7b", "", "Optional: also write the certificate and the -ca chain as a PKCS#7 bundle (.p7b), e.g. for Windows 'certreq -accept'")
+	validityDays := fs.Int("days", defaultLeafValidityDays, "Validity period in days")
+	eku := fs.String("eku", "server", "Comma-separated extended key usages: server, client, ocsp (delegated OCSP responder); not used with -profile")
+	var profile profileFlags
+	profile.register(fs)
+	commonName := fs.String("cn", "", "Optional: override the CSR's Common Name")
+	organization := fs.String("org", "", "Optional: override the CSR's Organization")
+	var subject subjectFlags
+	subject.register(fs, "Repeatable: replaces the CSR's ")
+	var sans sanFlags
+	sans.register(fs, func(kind string) string {
+		return "Repeatable: " + kind + " replacing the CSR's SANs of that type"
+	})
+	var serials serialFlags
+	serials.register(fs)
+	skidMethod := fs.String("skid-method", ca.SKIDMethodSHA1, "Subject key identifier method: 'sha1' or 'sha256'")
+	var pins pinSetFlags
+	pins.register(fs)
+	var denylist denylistFlags
+	denylist.register(fs)
+	var db certDBFlags
+	db.register(fs)
+	var sanPolicy sanPolicyFlags
+	sanPolicy.register(fs)
+	var caPassphrase passphraseFlags
+	caPassphrase.register(fs, "ca-", "decrypt the issuing CA private key with")
+
+	var cfg configFlags
+	cfg.register(fs)
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s sign-csr -csr server.csr -ca ca.crt -ca-key ca.key [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Signs an externally generated CSR, copying its subject and SANs unless overridden.\n")
+		fmt.Fprintf(os.Stderr, "PEM, DER and Windows certreq .req files are accepted.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+		printProfiles(os.Stderr)
+	}
+	fs.Parse(args)
+	cfg.apply(fs)
+	if err := checkFlags(fs); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	if *csrFile == "" {
+		fs.Usage()
+		log.Fatal("Error: -csr is required.")
+	}
+	extKeyUsages, err := parseExtKeyUsages(*eku)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	selected, err := profile.profile()
+	if err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+	if selected != nil && isFlagSet(fs, "eku") {
+		log.Fatalf("Error: -eku cannot be combined with -profile, which sets the extended key usages.")
+	}
+	if err := serials.check(); err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+	if *outFile == "" {
+		*outFile = strings.TrimSuffix(*csrFile, filepath.Ext(*csrFile)) + ".crt"
+	}
+
+	csr, err := ca.LoadCertificateRequest(*csrFile)
+	if err != nil {
+		log.Fatalf("Error loading CSR: %v", err)
+	}
+
+	opts := ca.IssueOptions{
+		CommonName:   *commonName,
+		Organization: *organization,
+		ValidityDays: *validityDays,
+		SerialBits:   serials.bits,
+		SKIDMethod:   *skidMethod,
+		ExtKeyUsages: extKeyUsages,
+		Profile:      selected,
+	}
+	if err := sans.apply(&opts); err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+	if opts.Subject, err = subject.attributes(); err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+	if opts.SANPolicy, err = sanPolicy.policy(); err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+	if opts.Denylist, err = denylist.load(*caCertFile); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	pinned, pin, err := pins.check(csr.PublicKey, *csrFile)
+	if err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+
+	issuer, err := ca.LoadCA(*caCertFile, *caKeyFile, caPassphrase.source())
+	if err != nil {
+		log.Fatalf("Error loading issuing CA: %v", err)
+	}
+
+	var seq uint64
+	if opts.SerialNumber, seq, err = serials.allocate(db.resolve(*caCertFile), opts.Rand); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	cert, err := issuer.SignCSR(csr, opts)
+	if err != nil {
+		log.Fatalf("Error signing CSR: %v", err)
+	}
+	if err := recordIssued(db.resolve(*caCertFile), cert, *outFile, seq); err != nil {
+		log.Fatalf("Error recording certificate: %v", err)
+	}
+
+	fmt.Println("Signed Certificate Request")
+	fmt.Printf("  Subject: %s\n", cert.Subject)
+	if len(cert.DNSNames) > 0 {
+		fmt.Printf("  DNS Names: %s\n", strings.Join(cert.DNSNames, ", "))
+	}
+	if len(cert.IPAddresses) > 0 {
+		fmt.Printf("  IP Addresses: %s\n", joinIPs(cert.IPAddresses))
+	}
+	if len(cert.EmailAddresses) > 0 {
+		fmt.Printf("  Email Addresses: %s\n", strings.Join(cert.EmailAddresses, ", "))
+	}
+	if len(cert.URIs) > 0 {
+		fmt.Printf("  URIs: %s\n", joinURIs(cert.URIs))
+	}
+	if selected != nil {
+		fmt.Printf("  Profile: %s\n", selected.Name)
+	}
+	fmt.Printf("  Issuer: %s\n", cert.Issuer)
+	fmt.Printf("  Serial: %X\n", cert.SerialNumber)
+	fmt.Printf("  Valid Until: %s\n", cert.NotAfter.Format("2006-01-02 15:04:05 MST"))
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	if err := os.WriteFile(*outFile, certPEM, defaultCertFileMode); err != nil {
+		log.Fatalf("Error writing certificate: %v", err)
+	}
+	fmt.Printf("\nCertificate saved to: %s\n", *outFile)
+
+	if *p7bFile != "" {
+		// The CA file may hold the issuer's own chain after its certificate.
+		chain, err := ca.LoadCertificates(*caCertFile)
+		if err != nil {
+			log.Fatalf("Error loading CA chain: %v", err)
+		}
+		der, err := ca.MarshalPKCS7Certificates(append([]*x509.Certificate{cert}, chain...))
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		p7bPEM := pem.EncodeToMemory(&pem.Block{Type: "PKCS7", Bytes: der})
+		if err := os.WriteFile(*p7bFile, p7bPEM, defaultCertFileMode); err != nil {
+			log.Fatalf("Error writing PKCS#7 bundle: %v", err)
+		}
+		fmt.Printf("PKCS#7 bundle (%d certificates) saved to: %s\n", len(chain)+1, *p7bFile)
+	}
+
+	if pinned != nil {
+		if err := pins.record(pinned, pin, cert); err != nil {
+			log.Fatalf("Error updating pin set: %v", err)
+		}
+	}
+}
+
+// parseExtKeyUsages parses a comma-separated list of -eku names.
+func parseExtKeyUsages(value string) ([]x509.ExtKeyUsage, error) {
+	var usages []x509.ExtKeyUsage
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		usage, ok := extKeyUsageNames[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown extended key usage %q (use server, client, ocsp)", name)
+		}
+		usages = append(usages, usage)
+	}
+	if len(usages) == 0 {
+		return nil, fmt.Errorf("at least one extended key usage is required")
+	}
+	return usages, nil
+}
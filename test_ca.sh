@@ -48,7 +48,7 @@ check_command() {
 # --- Sanity Checks ---
 check_command "openssl"
 if [ ! -x "$GO_CA_GEN_BIN" ]; then
-    error_exit "Go CA generator '$GO_CA_GEN_BIN' not found or not executable. Build it first: go build -o go-ca-gen main.go"
+    error_exit "Go CA generator '$GO_CA_GEN_BIN' not found or not executable. Build it first: go build -o go-ca-gen ."
 fi
 
 # --- Setup ---
@@ -58,7 +58,7 @@ mkdir -p "${CA_DIR}" "${CERTS_DIR}"
 
 # --- 1. Generate Root CA ---
 log "Generating Root CA using ${GO_CA_GEN_BIN}..."
-"$GO_CA_GEN_BIN" \
+"$GO_CA_GEN_BIN" init \
     -cn "$CA_CN" \
     -org "$CA_ORG" \
     -out "$CA_DIR" \
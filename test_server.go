@@ -0,0 +1,103 @@
+// test_server.go
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"html"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prtk1729/certA/pkg/ca"
+)
+
+// runTestServer implements the test-server command: a throwaway HTTPS server
+// that presents the given certificate, so users can confirm that browsers and
+// clients trust the CA after installing it.
+func runTestServer(args []string) {
+	fs := flag.NewFlagSet("test-server", flag.ExitOnError)
+	certFile := fs.String("cert", "", "Required: server certificate PEM file (leaf first, intermediates may follow)")
+	keyFile := fs.String("key", "", "Required: server private key PEM file")
+	host := fs.String("host", "", "Interface to listen on (default: all interfaces)")
+	port := fs.Int("port", 8443, "TCP port to listen on")
+	clientCAFile := fs.String("client-ca", "", "Optional: require client certificates issued by this CA PEM file (mTLS)")
+	var keyPassphrase passphraseFlags
+	keyPassphrase.register(fs, "", "decrypt the server private key with")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s test-server -cert server.crt -key server.key [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Serves a simple HTTPS page using the given certificate.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if err := checkFlags(fs); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	if *certFile == "" || *keyFile == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	keyPair, err := ca.LoadKeyPair(*certFile, *keyFile, keyPassphrase.source())
+	if err != nil {
+		log.Fatalf("Error loading certificate and key: %v", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{keyPair},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if *clientCAFile != "" {
+		clientCAs, err := buildRootPool(*clientCAFile, false)
+		if err != nil {
+			log.Fatalf("Error loading client CA: %v", err)
+		}
+		tlsConfig.ClientCAs = clientCAs
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	addr := net.JoinHostPort(*host, strconv.Itoa(*port))
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           http.HandlerFunc(serveTestPage),
+		ReadHeaderTimeout: 10 * time.Second,
+		TLSConfig:         tlsConfig,
+	}
+
+	fmt.Printf("Serving HTTPS on %s with certificate %s\n", addr, *certFile)
+	if *clientCAFile != "" {
+		fmt.Printf("  Requiring client certificates issued by %s\n", *clientCAFile)
+	}
+	fmt.Printf("  Try: https://localhost:%d/ (press Ctrl+C to stop)\n", *port)
+	if err := serveUntilInterrupted(server, "", ""); err != nil {
+		log.Fatalf("Error running test server: %v", err)
+	}
+	fmt.Println("Test server stopped.")
+}
+
+// serveTestPage reports the negotiated TLS parameters back to the client.
+func serveTestPage(w http.ResponseWriter, r *http.Request) {
+	state := r.TLS
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html><head><title>go-CA test server</title></head><body>\n")
+	b.WriteString("<h1>TLS handshake succeeded</h1>\n<ul>\n")
+	fmt.Fprintf(&b, "<li>Protocol: %s</li>\n", html.EscapeString(tls.VersionName(state.Version)))
+	fmt.Fprintf(&b, "<li>Cipher suite: %s</li>\n", html.EscapeString(tls.CipherSuiteName(state.CipherSuite)))
+	fmt.Fprintf(&b, "<li>Server name (SNI): %s</li>\n", html.EscapeString(state.ServerName))
+	if len(state.PeerCertificates) > 0 {
+		fmt.Fprintf(&b, "<li>Client certificate: %s</li>\n", html.EscapeString(state.PeerCertificates[0].Subject.String()))
+	}
+	b.WriteString("</ul>\n<p>If your browser did not warn about this page, it trusts the issuing CA.</p>\n</body></html>\n")
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, b.String())
+	log.Printf("%s %s %s (%s, %s)", r.RemoteAddr, r.Method, r.URL.Path, tls.VersionName(state.Version), state.ServerName)
+}
@@ -0,0 +1,134 @@
+// validate.go
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/prtk1729/certA/pkg/ca"
+)
+
+const (
+	maxValidityDays = 36525 // 100 years; anything longer is a typo
+	minRSABits      = 1024  // crypto/rsa refuses smaller keys
+	maxRSABits      = 16384
+	maxCTBatchSize  = 10000
+	maxSkipCount    = 255 // Bound for -path-len and policy skip counts; no real hierarchy is this deep
+)
+
+// flagCheck validates the value of one flag, as returned by flag.Getter.
+type flagCheck func(value any) error
+
+// flagChecks holds the checks shared by every command, keyed by flag name, so
+// a flag means the same thing and accepts the same range wherever it appears.
+// checkFlags applies them after flags, presets and configuration files have
+// all been applied.
+var flagChecks = map[string]flagCheck{
+	"days":        intBetween(1, maxValidityDays, " (100 years)"),
+	"bits":        intBetween(minRSABits, maxRSABits, ""),
+	"serial-bits": intBetween(ca.MinSerialBits, ca.MaxSerialBits, ""),
+	"port":        intBetween(1, 65535, ""),
+	"http-port":   intBetween(1, 65535, ""),
+	"batch":       intBetween(1, maxCTBatchSize, ""),
+
+	// -1 omits the constraint; see optionalSkipCerts.
+	"path-len":                intBetween(-1, maxSkipCount, " (-1: unlimited)"),
+	"require-explicit-policy": intBetween(-1, maxSkipCount, " (-1: omit)"),
+	"inhibit-policy-mapping":  intBetween(-1, maxSkipCount, " (-1: omit)"),
+	"inhibit-any-policy":      intBetween(-1, maxSkipCount, " (-1: omit)"),
+
+	"duration":    positiveDuration,
+	"validity":    positiveDuration,
+	"next-update": positiveDuration,
+	"timeout":     positiveDuration,
+
+	"expiring":       nonNegativeDuration,
+	"interval":       nonNegativeDuration,
+	"refresh-before": nonNegativeDuration,
+
+	"cert-name":  fileName,
+	"key-name":   fileName,
+	"chain-name": fileName,
+	"csr-name":   fileName,
+}
+
+// checkFlags validates every flag of fs that has a check and was set, on the
+// command line or by a preset or configuration file, reporting all problems
+// at once. Defaults are not checked, so a default may be a sentinel such as
+// renew's -days 0 ("keep the current length").
+func checkFlags(fs *flag.FlagSet) error {
+	var errs []error
+	fs.Visit(func(f *flag.Flag) {
+		check, ok := flagChecks[f.Name]
+		if !ok {
+			return
+		}
+		getter, ok := f.Value.(flag.Getter)
+		if !ok {
+			return
+		}
+		if err := check(getter.Get()); err != nil {
+			errs = append(errs, fmt.Errorf("-%s %w", f.Name, err))
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// intBetween accepts integers in [min, max]; note is appended to the
+// message, e.g. to explain a sentinel value.
+func intBetween(min, max int, note string) flagCheck {
+	return func(value any) error {
+		n, ok := value.(int)
+		if !ok {
+			return fmt.Errorf("has unexpected type %T", value)
+		}
+		if n < min || n > max {
+			return fmt.Errorf("must be between %d and %d%s, got %d", min, max, note, n)
+		}
+		return nil
+	}
+}
+
+func positiveDuration(value any) error {
+	d, ok := value.(time.Duration)
+	if !ok {
+		return fmt.Errorf("has unexpected type %T", value)
+	}
+	if d <= 0 {
+		return fmt.Errorf("must be positive, got %s", d)
+	}
+	return nil
+}
+
+func nonNegativeDuration(value any) error {
+	d, ok := value.(time.Duration)
+	if !ok {
+		return fmt.Errorf("has unexpected type %T", value)
+	}
+	if d < 0 {
+		return fmt.Errorf("must not be negative, got %s", d)
+	}
+	return nil
+}
+
+// fileName accepts a plain file name, to be joined to an output directory:
+// anything that could point elsewhere is rejected.
+func fileName(value any) error {
+	name, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("has unexpected type %T", value)
+	}
+	switch {
+	case name == "":
+		return errors.New("must not be empty")
+	case name == "." || name == "..":
+		return fmt.Errorf("must be a file name, got %q", name)
+	case strings.ContainsAny(name, `/\`) || filepath.Base(name) != name || filepath.VolumeName(name) != "":
+		return fmt.Errorf("must be a file name without a directory, got %q (use -out for the directory)", name)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+// verify.go
+package main
+
+import (
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/prtk1729/certA/pkg/ca"
+)
+
+// runVerify implements the verify command: it builds and validates the chain
+// from a leaf certificate to a trusted root and exits non-zero on failure, so
+// issued certificates can be sanity-checked in CI pipelines.
+func runVerify(args []string) {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	caFile := fs.String("ca", "", "Trusted root CA PEM file (required unless -system is given)")
+	useSystem := fs.Bool("system", false, "Also trust the operating system root pool")
+	var untrusted stringList
+	fs.Var(&untrusted, "untrusted", "Repeatable: PEM file of intermediate certificates that may be used to build the chain")
+	hostname := fs.String("hostname", "", "Optional: hostname (or IP address) the leaf certificate must be valid for")
+	eku := fs.String("eku", "any", "Comma-separated extended key usages the leaf must allow: server, client, ocsp, any")
+	var at clockFlags
+	at.register(fs, "Evaluate validity as of this RFC 3339 timestamp instead of now")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s verify -ca root.crt [-untrusted intermediate.crt]... [options] <leaf.pem>\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Builds and validates the chain from a leaf certificate to a trusted root, checking\n")
+		fmt.Fprintf(os.Stderr, "validity periods, signatures, key usage, extended key usage and the hostname.\n")
+		fmt.Fprintf(os.Stderr, "Further certificates in the leaf file are used as intermediates.\n")
+		fmt.Fprintf(os.Stderr, "Exits with status 1 if verification fails.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *caFile == "" && !*useSystem {
+		fs.Usage()
+		log.Fatal("Error: -ca or -system is required.")
+	}
+	clock, err := at.clock()
+	if err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+	extKeyUsages := []x509.ExtKeyUsage{x509.ExtKeyUsageAny}
+	if *eku != "any" {
+		if extKeyUsages, err = parseExtKeyUsages(*eku); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	}
+
+	certs, err := ca.LoadCertificates(fs.Arg(0))
+	if err != nil {
+		log.Fatalf("Error loading certificate: %v", err)
+	}
+	leaf := certs[0]
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	for _, path := range untrusted {
+		extra, err := ca.LoadCertificates(path)
+		if err != nil {
+			log.Fatalf("Error loading intermediates: %v", err)
+		}
+		for _, cert := range extra {
+			intermediates.AddCert(cert)
+		}
+	}
+	roots, err := buildRootPool(*caFile, *useSystem)
+	if err != nil {
+		log.Fatalf("Error loading trust roots: %v", err)
+	}
+
+	fmt.Printf("Verifying %s\n", fs.Arg(0))
+	fmt.Printf("  Subject: %s\n", leaf.Subject)
+	fmt.Printf("  Valid: %s to %s\n", leaf.NotBefore.Format(time.RFC3339), leaf.NotAfter.Format(time.RFC3339))
+
+	chains, err := leaf.Verify(x509.VerifyOptions{
+		DNSName:       *hostname,
+		Intermediates: intermediates,
+		Roots:         roots,
+		CurrentTime:   clock.Now(),
+		KeyUsages:     extKeyUsages,
+	})
+	if err != nil {
+		fmt.Printf("\nVerification FAILED:\n  - %v\n", err)
+		os.Exit(1)
+	}
+
+	chain := chains[0]
+	fmt.Println("  Chain:")
+	for i, cert := range chain {
+		fmt.Printf("    [%d] %s (expires %s)\n", i, cert.Subject, cert.NotAfter.Format(time.RFC3339))
+	}
+	// crypto/x509 checks extended key usage but not the key usage bits.
+	if problems := checkChainKeyUsage(chain); len(problems) > 0 {
+		fmt.Println("\nVerification FAILED:")
+		for _, p := range problems {
+			fmt.Printf("  - %s\n", p)
+		}
+		os.Exit(1)
+	}
+	fmt.Println("\nVerification OK.")
+}
+
+// checkChainKeyUsage checks the key usage extension along a verified chain:
+// the leaf must allow digital signatures and every CA must allow certificate
+// signing. Certificates without the extension are unrestricted.
+func checkChainKeyUsage(chain []*x509.Certificate) []string {
+	var problems []string
+	if leaf := chain[0]; leaf.KeyUsage != 0 && leaf.KeyUsage&x509.KeyUsageDigitalSignature == 0 {
+		problems = append(problems, fmt.Sprintf("certificate [0] %q does not allow digital signatures", leaf.Subject.CommonName))
+	}
+	for i, cert := range chain[1:] {
+		if cert.KeyUsage != 0 && cert.KeyUsage&x509.KeyUsageCertSign == 0 {
+			problems = append(problems, fmt.Sprintf("certificate [%d] %q is a CA but does not allow certificate signing", i+1, cert.Subject.CommonName))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,150 @@
+// verify_bundle.go
+package main
+
+import (
+	"bytes"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/prtk1729/certA/pkg/ca"
+)
+
+// runVerifyBundle implements the verify-bundle command: it checks that a
+// certificate bundle (leaf first, then intermediates) is correctly ordered,
+// complete, currently valid, and chains to a trusted root.
+func runVerifyBundle(args []string) {
+	fs := flag.NewFlagSet("verify-bundle", flag.ExitOnError)
+	caFile := fs.String("ca", "", "Optional: trusted root CA PEM file (e.g. the CA generated by this tool)")
+	useSystem := fs.Bool("system", false, "Trust the operating system root pool (default when -ca is not given)")
+	hostname := fs.String("hostname", "", "Optional: hostname the leaf certificate must be valid for")
+	var at clockFlags
+	at.register(fs, "Evaluate validity as of this RFC 3339 timestamp instead of now")
+
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s verify-bundle [options] <fullchain.pem>\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Checks ordering, completeness, and validity of a certificate bundle.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	clock, err := at.clock()
+	if err != nil {
+		log.Fatalf("Error: %v.", err)
+	}
+
+	bundle, err := ca.LoadCertificates(fs.Arg(0))
+	if err != nil {
+		log.Fatalf("Error loading bundle: %v", err)
+	}
+
+	roots, err := buildRootPool(*caFile, *useSystem || *caFile == "")
+	if err != nil {
+		log.Fatalf("Error loading trust roots: %v", err)
+	}
+
+	fmt.Printf("Verifying bundle %s (%d certificates)\n", fs.Arg(0), len(bundle))
+	problems := checkBundle(bundle, roots, *hostname, clock.Now())
+
+	if len(problems) > 0 {
+		fmt.Println("\nBundle verification FAILED:")
+		for _, p := range problems {
+			fmt.Printf("  - %s\n", p)
+		}
+		os.Exit(1)
+	}
+	fmt.Println("\nBundle OK: correctly ordered, complete, and trusted.")
+}
+
+// buildRootPool assembles the trust anchors for verification from an optional
+// CA file and, if requested, the system root pool.
+func buildRootPool(caFile string, useSystem bool) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	if useSystem {
+		systemPool, err := x509.SystemCertPool()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load system root pool: %w", err)
+		}
+		pool = systemPool
+	}
+	if caFile != "" {
+		cas, err := ca.LoadCertificates(caFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, ca := range cas {
+			pool.AddCert(ca)
+		}
+	}
+	return pool, nil
+}
+
+// checkBundle returns a human-readable list of problems found in the bundle.
+// An empty result means the bundle is usable as-is.
+func checkBundle(bundle []*x509.Certificate, roots *x509.CertPool, hostname string, now time.Time) []string {
+	var problems []string
+
+	for i, cert := range bundle {
+		fmt.Printf("  [%d] %s\n", i, cert.Subject)
+		fmt.Printf("      issuer: %s, expires %s\n", cert.Issuer, cert.NotAfter.Format(time.RFC3339))
+
+		if now.Before(cert.NotBefore) {
+			problems = append(problems, fmt.Sprintf("certificate [%d] %q is not valid until %s", i, cert.Subject.CommonName, cert.NotBefore.Format(time.RFC3339)))
+		}
+		if now.After(cert.NotAfter) {
+			problems = append(problems, fmt.Sprintf("certificate [%d] %q expired at %s", i, cert.Subject.CommonName, cert.NotAfter.Format(time.RFC3339)))
+		}
+
+		if i == 0 {
+			continue
+		}
+		// Ordering: each certificate must be issued by the one that follows it.
+		prev := bundle[i-1]
+		if !bytes.Equal(prev.RawIssuer, cert.RawSubject) {
+			problems = append(problems, fmt.Sprintf("certificate [%d] is not the issuer of certificate [%d] (wrong order or unrelated certificate)", i, i-1))
+		} else if err := prev.CheckSignatureFrom(cert); err != nil {
+			problems = append(problems, fmt.Sprintf("certificate [%d] signature does not verify against certificate [%d]: %v", i-1, i, err))
+		}
+	}
+
+	// Completeness and trust: let the standard verifier build a path from the
+	// leaf using only the bundle's own intermediates.
+	intermediates := x509.NewCertPool()
+	for _, cert := range bundle[1:] {
+		intermediates.AddCert(cert)
+	}
+	chains, err := bundle[0].Verify(x509.VerifyOptions{
+		DNSName:       hostname,
+		Intermediates: intermediates,
+		Roots:         roots,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("chain does not verify: %v", err))
+		return problems
+	}
+
+	// Anything in the bundle that did not end up on the verified path is
+	// dead weight at best and a sign of a mis-assembled bundle at worst.
+	used := make(map[string]bool)
+	for _, cert := range chains[0] {
+		used[string(cert.Raw)] = true
+	}
+	for i, cert := range bundle {
+		if !used[string(cert.Raw)] {
+			problems = append(problems, fmt.Sprintf("certificate [%d] %q is not part of the verified chain", i, cert.Subject.CommonName))
+		}
+	}
+	fmt.Printf("  Verified path ends at root: %s\n", chains[0][len(chains[0])-1].Subject)
+
+	return problems
+}